@@ -0,0 +1,49 @@
+package nostr
+
+import (
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Address returns the "<kind>:<pubkey>:<d tag>" coordinates of a
+// parameterized replaceable event, which is what "a" tags point to.
+func (evt *Event) Address() (string, error) {
+	if !IsParameterizedReplaceable(evt.Kind) {
+		return "", fmt.Errorf("kind %d is not parameterized replaceable", evt.Kind)
+	}
+
+	tag := evt.Tags.GetFirst("d")
+	if tag == nil {
+		return "", fmt.Errorf("event has no \"d\" tag")
+	}
+
+	dtag := ""
+	if len(*tag) >= 2 {
+		dtag = (*tag)[1]
+	}
+
+	return strconv.Itoa(evt.Kind) + ":" + evt.PubKey + ":" + dtag, nil
+}
+
+// ParseAddress splits "<kind>:<pubkey>:<d tag>" coordinates.
+func ParseAddress(coord string) (kind int, pubkey string, dtag string, err error) {
+	spl := strings.SplitN(coord, ":", 3)
+	if len(spl) != 3 {
+		return 0, "", "", fmt.Errorf("address '%s' is not '<kind>:<pubkey>:<d tag>'", coord)
+	}
+
+	kind, err = strconv.Atoi(spl[0])
+	if err != nil {
+		return 0, "", "", fmt.Errorf("invalid kind in address '%s': %w", coord, err)
+	}
+	if kind < 0 {
+		return 0, "", "", fmt.Errorf("invalid kind in address '%s'", coord)
+	}
+	if b, err := hex.DecodeString(spl[1]); err != nil || len(b) != 32 {
+		return 0, "", "", fmt.Errorf("invalid pubkey in address '%s'", coord)
+	}
+
+	return kind, spl[1], spl[2], nil
+}
@@ -0,0 +1,193 @@
+package nostr
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// MakeAuthEvent returns an unsigned kind-22242 event answering the AUTH
+// challenge sent by the relay at relayURL.
+func MakeAuthEvent(relayURL string, challenge string) *Event {
+	return NewEvent(KindClientAuthentication, "").
+		WithTag("relay", relayURL).
+		WithTag("challenge", challenge)
+}
+
+// GenerateAuthChallenge returns a random, unguessable challenge for a relay to
+// send in an AUTH message. A new one should be made for each connection and
+// only accepted once, see ValidateAuthResponse.
+func GenerateAuthChallenge() string {
+	random := make([]byte, 16)
+	if _, err := rand.Read(random); err != nil {
+		panic(fmt.Errorf("failed to read random bytes for an auth challenge: %w", err))
+	}
+	return hex.EncodeToString(random)
+}
+
+// ValidateAuthResponse checks, on the relay side, the kind-22242 event a
+// client sent back in answer to expectedChallenge, like evt.ValidateAuth, and
+// returns the pubkey it authenticated as.
+func ValidateAuthResponse(evt *Event, expectedChallenge string, relayURL string, maxAge time.Duration) (pubkey string, err error) {
+	if err := evt.ValidateAuth(expectedChallenge, relayURL, maxAge); err != nil {
+		return "", err
+	}
+	return evt.PubKey, nil
+}
+
+// ValidateAuth checks, on the relay side, that the event is a signed answer to
+// expectedChallenge addressed to expectedRelay and created at most maxAge
+// from now. Relay URLs are compared after normalization.
+func (evt *Event) ValidateAuth(expectedChallenge string, expectedRelay string, maxAge time.Duration) error {
+	if evt.Kind != KindClientAuthentication {
+		return fmt.Errorf("event kind is %d, not %d", evt.Kind, KindClientAuthentication)
+	}
+
+	if expectedChallenge == "" {
+		return fmt.Errorf("no challenge to validate against")
+	}
+	challenge := evt.Tags.GetFirst("challenge")
+	if challenge == nil || len(*challenge) < 2 || (*challenge)[1] != expectedChallenge {
+		return fmt.Errorf("challenge doesn't match")
+	}
+
+	relay := evt.Tags.GetFirst("relay")
+	if relay == nil || len(*relay) < 2 {
+		return fmt.Errorf("event has no relay tag")
+	}
+	if NormalizeURL((*relay)[1]) != NormalizeURL(expectedRelay) {
+		return fmt.Errorf("relay '%s' doesn't match '%s'", (*relay)[1], expectedRelay)
+	}
+
+	if age := time.Since(evt.CreatedAt.Time()); age > maxAge || age < -maxAge {
+		return fmt.Errorf("event created_at is too far from now (%s)", age.Round(time.Second))
+	}
+
+	if ok, err := evt.CheckSignature(); !ok {
+		return fmt.Errorf("invalid signature: %v", err)
+	}
+
+	return nil
+}
+
+// OnAuth sets the callback that answers the NIP-42 AUTH challenges of the
+// relay with a signed kind-22242 event, like one from MakeAuthEvent, see also
+// WithAuthSigner. Once it is set, events rejected with an "auth-required:"
+// reason are published again after authenticating, at most once each.
+func (r *Relay) OnAuth(fn func(challenge string) (*Event, error)) {
+	r.mutex.Lock()
+	r.onAuth = fn
+	r.mutex.Unlock()
+}
+
+// authAttempt is an answer sent to an AUTH challenge, done is closed when the
+// relay accepts or rejects it, after err is set.
+type authAttempt struct {
+	done chan struct{}
+	once sync.Once
+	err  error
+}
+
+func (a *authAttempt) finish(err error) {
+	a.once.Do(func() {
+		a.err = err
+		close(a.done)
+	})
+}
+
+// handleAuthChallenge records a new challenge, answering it right away if
+// there is an OnAuth callback.
+func (r *Relay) handleAuthChallenge(challenge string) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.challenge = challenge
+	r.authAttempt = nil
+	if r.onAuth != nil {
+		r.startAuth()
+	}
+}
+
+// startAuth answers the current challenge, it must be called with the mutex
+// held.
+func (r *Relay) startAuth() *authAttempt {
+	attempt := &authAttempt{done: make(chan struct{})}
+	r.authAttempt = attempt
+
+	go func(onAuth func(string) (*Event, error), challenge string) {
+		evt, err := onAuth(challenge)
+		if err != nil {
+			attempt.finish(fmt.Errorf("failed to make auth event: %w", err))
+			return
+		}
+		if evt == nil {
+			attempt.finish(fmt.Errorf("OnAuth callback returned no auth event"))
+			return
+		}
+
+		r.mutex.Lock()
+		r.okCallbacks[evt.ID] = func(ok bool, reason string) {
+			if ok {
+				attempt.finish(nil)
+			} else {
+				attempt.finish(fmt.Errorf("auth rejected by '%s': %s", r.URL, reason))
+			}
+		}
+		r.mutex.Unlock()
+		defer func() {
+			select {
+			case <-attempt.done:
+			case <-r.Closed:
+			}
+			r.mutex.Lock()
+			delete(r.okCallbacks, evt.ID)
+			r.mutex.Unlock()
+		}()
+
+		if err := r.Connection.WriteJSON(AuthEnvelope{Event: evt}); err != nil {
+			attempt.finish(fmt.Errorf("error sending auth to '%s': %w", r.URL, err))
+		}
+	}(r.onAuth, r.challenge)
+
+	return attempt
+}
+
+// authenticate waits for the answer to the current challenge to be accepted,
+// sending it if that wasn't done yet.
+func (r *Relay) authenticate(ctx context.Context) error {
+	r.mutex.Lock()
+	if r.onAuth == nil {
+		r.mutex.Unlock()
+		return fmt.Errorf("no OnAuth callback to authenticate with '%s'", r.URL)
+	}
+	if r.challenge == "" {
+		r.mutex.Unlock()
+		return fmt.Errorf("'%s' didn't send an AUTH challenge", r.URL)
+	}
+	attempt := r.authAttempt
+	if attempt == nil {
+		attempt = r.startAuth()
+	}
+	r.mutex.Unlock()
+
+	select {
+	case <-attempt.done:
+		return attempt.err
+	case <-r.Closed:
+		return fmt.Errorf("connection to '%s' closed: %w", r.URL, r.ConnectionError)
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// canRetryWithAuth checks if a rejected event could be accepted after
+// authenticating.
+func (r *Relay) canRetryWithAuth(reason string) bool {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return r.onAuth != nil && strings.HasPrefix(reason, "auth-required:")
+}
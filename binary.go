@@ -0,0 +1,168 @@
+package nostr
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+)
+
+// binaryVersion is the first byte of MarshalBinary results, so the layout can
+// change without misreading what was stored before.
+const binaryVersion = 1
+
+var errBinaryTruncated = errors.New("binary event is truncated")
+
+// MarshalBinary encodes a signed event in a compact format meant for local
+// storage, not for exchanging with other software: a version byte, the raw
+// 32-byte id and pubkey, created_at as 8 bytes, the kind as a varint, the
+// tags, the content and the raw 64-byte signature. Numbers of tags and items
+// and the lengths of strings are prefixed as varints.
+func (evt *Event) MarshalBinary() ([]byte, error) {
+	id, err := hex.DecodeString(evt.ID)
+	if err != nil || len(id) != 32 {
+		return nil, fmt.Errorf("id '%s' is not 32 bytes hex", evt.ID)
+	}
+	pubkey, err := hex.DecodeString(evt.PubKey)
+	if err != nil || len(pubkey) != 32 {
+		return nil, fmt.Errorf("pubkey '%s' is not 32 bytes hex", evt.PubKey)
+	}
+	sig, err := hex.DecodeString(evt.Sig)
+	if err != nil || len(sig) != 64 {
+		return nil, fmt.Errorf("sig '%s' is not 64 bytes hex", evt.Sig)
+	}
+
+	size := 1 + 32 + 32 + 8 + binary.MaxVarintLen64*2 + len(evt.Content) + 64
+	for _, tag := range evt.Tags {
+		size += binary.MaxVarintLen64
+		for _, item := range tag {
+			size += binary.MaxVarintLen64 + len(item)
+		}
+	}
+
+	b := make([]byte, 0, size)
+	b = append(b, binaryVersion)
+	b = append(b, id...)
+	b = append(b, pubkey...)
+	b = appendUint64(b, uint64(evt.CreatedAt))
+	b = appendUvarint(b, uint64(evt.Kind))
+	b = appendUvarint(b, uint64(len(evt.Tags)))
+	for _, tag := range evt.Tags {
+		b = appendUvarint(b, uint64(len(tag)))
+		for _, item := range tag {
+			b = appendBinaryString(b, item)
+		}
+	}
+	b = appendBinaryString(b, evt.Content)
+	b = append(b, sig...)
+
+	return b, nil
+}
+
+// UnmarshalBinary decodes an event encoded with MarshalBinary.
+func (evt *Event) UnmarshalBinary(data []byte) error {
+	r := binaryReader{data: data}
+
+	if version := r.bytes(1); version == nil || version[0] != binaryVersion {
+		return fmt.Errorf("unsupported binary event format")
+	}
+	id := r.bytes(32)
+	pubkey := r.bytes(32)
+	createdAt := r.bytes(8)
+	kind := r.uvarint()
+
+	ntags := r.uvarint()
+	if ntags > uint64(len(r.data)) {
+		return errBinaryTruncated
+	}
+	tags := make(Tags, ntags)
+	for i := range tags {
+		nitems := r.uvarint()
+		if nitems > uint64(len(r.data)) {
+			return errBinaryTruncated
+		}
+		tags[i] = make(Tag, nitems)
+		for j := range tags[i] {
+			tags[i][j] = r.string()
+		}
+	}
+
+	content := r.string()
+	sig := r.bytes(64)
+	if r.err != nil {
+		return r.err
+	}
+	if len(r.data) != 0 {
+		return fmt.Errorf("binary event has %d extra bytes", len(r.data))
+	}
+
+	evt.ID = hex.EncodeToString(id)
+	evt.PubKey = hex.EncodeToString(pubkey)
+	evt.CreatedAt = Timestamp(binary.BigEndian.Uint64(createdAt))
+	evt.Kind = int(kind)
+	evt.Tags = tags
+	evt.Content = content
+	evt.Sig = hex.EncodeToString(sig)
+	return nil
+}
+
+func appendUint64(b []byte, v uint64) []byte {
+	var buf [8]byte
+	binary.BigEndian.PutUint64(buf[:], v)
+	return append(b, buf[:]...)
+}
+
+func appendUvarint(b []byte, v uint64) []byte {
+	var buf [binary.MaxVarintLen64]byte
+	n := binary.PutUvarint(buf[:], v)
+	return append(b, buf[:n]...)
+}
+
+func appendBinaryString(b []byte, s string) []byte {
+	b = appendUvarint(b, uint64(len(s)))
+	return append(b, s...)
+}
+
+// binaryReader reads the fields of a binary event, the first failure is kept
+// in err and makes all following reads return zero values.
+type binaryReader struct {
+	data []byte
+	err  error
+}
+
+func (r *binaryReader) bytes(n int) []byte {
+	if r.err != nil {
+		return nil
+	}
+	if len(r.data) < n {
+		r.err = errBinaryTruncated
+		return nil
+	}
+	b := r.data[:n]
+	r.data = r.data[n:]
+	return b
+}
+
+func (r *binaryReader) uvarint() uint64 {
+	if r.err != nil {
+		return 0
+	}
+	v, n := binary.Uvarint(r.data)
+	if n <= 0 {
+		r.err = errBinaryTruncated
+		return 0
+	}
+	r.data = r.data[n:]
+	return v
+}
+
+func (r *binaryReader) string() string {
+	n := r.uvarint()
+	if n > uint64(len(r.data)) {
+		if r.err == nil {
+			r.err = errBinaryTruncated
+		}
+		return ""
+	}
+	return string(r.bytes(int(n)))
+}
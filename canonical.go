@@ -0,0 +1,60 @@
+package nostr
+
+import (
+	"golang.org/x/text/unicode/norm"
+)
+
+// Canonicalize rewrites the event in a canonical form, so that events which
+// only differ in how their tags and content are encoded produce the same
+// serialization, for example to use GetID as a cache key: trailing empty
+// items are trimmed from the tags, keeping their names, and the content and
+// tag items are normalized to Unicode NFC. Nothing else is changed, the id
+// and signature are kept as they were. It returns whether anything changed.
+//
+// A signed event that gets changed no longer matches its id and signature,
+// so only canonicalize events for comparison, never before relaying them.
+func (evt *Event) Canonicalize() bool {
+	changed := false
+
+	if !norm.NFC.IsNormalString(evt.Content) {
+		evt.Content = norm.NFC.String(evt.Content)
+		changed = true
+	}
+
+	// copies of the event share the Tags array and other events may share
+	// the tag arrays, so neither is edited: changed tags go in a new slice
+	var tags Tags
+	for i, tag := range evt.Tags {
+		end := len(tag)
+		for end > 1 && tag[end-1] == "" {
+			end--
+		}
+
+		normalized := end == len(tag)
+		for _, item := range tag[:end] {
+			if !norm.NFC.IsNormalString(item) {
+				normalized = false
+				break
+			}
+		}
+		if normalized {
+			continue
+		}
+
+		if tags == nil {
+			tags = make(Tags, len(evt.Tags))
+			copy(tags, evt.Tags)
+		}
+		canonical := make(Tag, end)
+		for j, item := range tag[:end] {
+			canonical[j] = norm.NFC.String(item)
+		}
+		tags[i] = canonical
+	}
+	if tags != nil {
+		evt.Tags = tags
+		changed = true
+	}
+
+	return changed
+}
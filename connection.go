@@ -1,33 +1,148 @@
 package nostr
 
 import (
-	"github.com/gorilla/websocket"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"io/ioutil"
 	"sync"
+
+	"github.com/gorilla/websocket"
 )
 
+// writeQueueSize is how many messages can wait to be written before senders
+// block.
+const writeQueueSize = 64
+
+var errConnectionClosed = errors.New("connection closed")
+
+// Connection is a websocket on which all messages are written by a single
+// goroutine, in the order they are sent, so concurrent senders never
+// interleave their frames.
 type Connection struct {
 	socket *websocket.Conn
 	mutex  sync.Mutex
+	closed bool
+
+	queue chan outgoingMessage
+	done  chan struct{}
+}
+
+type outgoingMessage struct {
+	messageType int
+	data        []byte
+	result      chan error
 }
 
 func NewConnection(socket *websocket.Conn) *Connection {
-	return &Connection{
+	c := &Connection{
 		socket: socket,
+		queue:  make(chan outgoingMessage, writeQueueSize),
+		done:   make(chan struct{}),
+	}
+	go c.writeLoop()
+	return c
+}
+
+func (c *Connection) writeLoop() {
+	for {
+		select {
+		case msg := <-c.queue:
+			c.mutex.Lock()
+			socket := c.socket
+			c.mutex.Unlock()
+			msg.result <- socket.WriteMessage(msg.messageType, msg.data)
+		case <-c.done:
+			return
+		}
 	}
 }
 
 func (c *Connection) WriteJSON(v interface{}) error {
+	return c.WriteJSONContext(context.Background(), v)
+}
+
+// WriteJSONContext queues v to be written as json and waits until it is, or
+// until ctx is done, in which case it may still be written later.
+func (c *Connection) WriteJSONContext(ctx context.Context, v interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return c.write(ctx, websocket.TextMessage, data)
+}
+
+func (c *Connection) WriteMessage(messageType int, data []byte) error {
+	return c.write(context.Background(), messageType, data)
+}
+
+func (c *Connection) write(ctx context.Context, messageType int, data []byte) error {
+	msg := outgoingMessage{messageType, data, make(chan error, 1)}
+	select {
+	case c.queue <- msg:
+	case <-c.done:
+		return errConnectionClosed
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+
+	select {
+	case err := <-msg.result:
+		return err
+	case <-c.done:
+		return errConnectionClosed
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (c *Connection) Close() error {
 	c.mutex.Lock()
 	defer c.mutex.Unlock()
-	return c.socket.WriteJSON(v)
+	if !c.closed {
+		c.closed = true
+		close(c.done)
+	}
+	return c.socket.Close()
 }
 
-func (c *Connection) WriteMessage(messageType int, data []byte) error {
+// replace swaps the underlying socket after a reconnection, unless the
+// connection was closed meanwhile.
+func (c *Connection) replace(socket *websocket.Conn) bool {
 	c.mutex.Lock()
 	defer c.mutex.Unlock()
-	return c.socket.WriteMessage(messageType, data)
+	if c.closed {
+		socket.Close()
+		return false
+	}
+	c.socket.Close()
+	c.socket = socket
+	return true
 }
 
-func (c *Connection) Close() error {
-	return c.socket.Close()
+// readMessage reads the next message, but only keeps up to limit bytes of it
+// in memory, a bigger one is discarded and makes it return ErrMessageTooLarge
+// with the connection still usable. A limit of 0 keeps everything.
+func (c *Connection) readMessage(limit int64) (int, []byte, error) {
+	typ, reader, err := c.socket.NextReader()
+	if err != nil {
+		return typ, nil, err
+	}
+	if limit <= 0 {
+		message, err := ioutil.ReadAll(reader)
+		return typ, message, err
+	}
+
+	message, err := ioutil.ReadAll(io.LimitReader(reader, limit+1))
+	if err != nil {
+		return typ, nil, err
+	}
+	if int64(len(message)) > limit {
+		if _, err := io.Copy(ioutil.Discard, reader); err != nil {
+			return typ, nil, err
+		}
+		return typ, nil, ErrMessageTooLarge
+	}
+	return typ, message, nil
 }
@@ -0,0 +1,355 @@
+package nostr
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+// Envelope is one of the NIP-01 messages exchanged between clients and relays.
+type Envelope interface {
+	Label() string
+	json.Marshaler
+}
+
+// EventEnvelope is ["EVENT", <subscription_id>, <event>] when sent by a relay
+// and ["EVENT", <event>] when sent by a client, in which case SubscriptionID
+// is empty.
+type EventEnvelope struct {
+	SubscriptionID string
+	Event          *Event
+}
+
+// ReqEnvelope is ["REQ", <subscription_id>, <filter>...].
+type ReqEnvelope struct {
+	SubscriptionID string
+	Filters        Filters
+}
+
+// CloseEnvelope is ["CLOSE", <subscription_id>].
+type CloseEnvelope struct {
+	SubscriptionID string
+}
+
+// EOSEEnvelope is ["EOSE", <subscription_id>].
+type EOSEEnvelope struct {
+	SubscriptionID string
+}
+
+// NoticeEnvelope is ["NOTICE", <message>].
+type NoticeEnvelope struct {
+	Message string
+}
+
+// OKEnvelope is ["OK", <event_id>, <true|false>, <message>].
+type OKEnvelope struct {
+	EventID string
+	OK      bool
+	Reason  string
+}
+
+// CountEnvelope is ["COUNT", <subscription_id>, <filter>...] when sent by a
+// client and ["COUNT", <subscription_id>, {"count": <n>}] when sent by a
+// relay, in which case Count is set instead of Filters.
+type CountEnvelope struct {
+	SubscriptionID string
+	Filters        Filters
+	Count          *int64
+}
+
+// ClosedEnvelope is ["CLOSED", <subscription_id>, <message>], sent by a relay
+// that refused or ended a subscription.
+type ClosedEnvelope struct {
+	SubscriptionID string
+	Reason         string
+}
+
+// AuthEnvelope is ["AUTH", <challenge>] when sent by a relay and
+// ["AUTH", <event>] when sent by a client, in which case Event is set instead
+// of Challenge.
+type AuthEnvelope struct {
+	Challenge string
+	Event     *Event
+}
+
+// NegOpenEnvelope is ["NEG-OPEN", <subscription_id>, <filter>, <message>],
+// sent by a client to start a NIP-77 reconciliation. Message is hex encoded.
+type NegOpenEnvelope struct {
+	SubscriptionID string
+	Filter         Filter
+	Message        string
+}
+
+// NegMsgEnvelope is ["NEG-MSG", <subscription_id>, <message>], exchanged by
+// both sides of a NIP-77 reconciliation.
+type NegMsgEnvelope struct {
+	SubscriptionID string
+	Message        string
+}
+
+// NegErrEnvelope is ["NEG-ERR", <subscription_id>, <reason>], sent by a relay
+// that refused or aborted a NIP-77 reconciliation.
+type NegErrEnvelope struct {
+	SubscriptionID string
+	Reason         string
+}
+
+// NegCloseEnvelope is ["NEG-CLOSE", <subscription_id>].
+type NegCloseEnvelope struct {
+	SubscriptionID string
+}
+
+func (EventEnvelope) Label() string  { return "EVENT" }
+func (ReqEnvelope) Label() string    { return "REQ" }
+func (CloseEnvelope) Label() string  { return "CLOSE" }
+func (EOSEEnvelope) Label() string   { return "EOSE" }
+func (NoticeEnvelope) Label() string { return "NOTICE" }
+func (OKEnvelope) Label() string     { return "OK" }
+func (CountEnvelope) Label() string  { return "COUNT" }
+func (ClosedEnvelope) Label() string { return "CLOSED" }
+func (AuthEnvelope) Label() string   { return "AUTH" }
+
+func (NegOpenEnvelope) Label() string  { return "NEG-OPEN" }
+func (NegMsgEnvelope) Label() string   { return "NEG-MSG" }
+func (NegErrEnvelope) Label() string   { return "NEG-ERR" }
+func (NegCloseEnvelope) Label() string { return "NEG-CLOSE" }
+
+var ErrUnknownEnvelope = errors.New("unknown message label")
+
+// ParseMessage parses a message sent by a relay or a client into the
+// corresponding Envelope.
+func ParseMessage(raw []byte) (Envelope, error) {
+	var items []json.RawMessage
+	if err := json.Unmarshal(raw, &items); err != nil {
+		return nil, fmt.Errorf("message is not a json array: %w", err)
+	}
+	if len(items) < 2 {
+		return nil, fmt.Errorf("message must have at least 2 items, not %d", len(items))
+	}
+
+	var label string
+	if err := json.Unmarshal(items[0], &label); err != nil {
+		return nil, fmt.Errorf("message label is not a string: %w", err)
+	}
+
+	switch label {
+	case "EVENT":
+		var env EventEnvelope
+		rawEvent := items[1]
+		if len(items) >= 3 {
+			if err := json.Unmarshal(items[1], &env.SubscriptionID); err != nil {
+				return nil, fmt.Errorf("invalid subscription id on EVENT: %w", err)
+			}
+			rawEvent = items[2]
+		}
+		env.Event = &Event{}
+		if err := env.Event.UnmarshalJSON(rawEvent); err != nil {
+			return nil, fmt.Errorf("invalid event on EVENT: %w", err)
+		}
+		return env, nil
+	case "REQ":
+		var env ReqEnvelope
+		if err := json.Unmarshal(items[1], &env.SubscriptionID); err != nil {
+			return nil, fmt.Errorf("invalid subscription id on REQ: %w", err)
+		}
+		env.Filters = make(Filters, len(items)-2)
+		for i, rawFilter := range items[2:] {
+			if err := env.Filters[i].UnmarshalJSON(rawFilter); err != nil {
+				return nil, fmt.Errorf("invalid filter on REQ: %w", err)
+			}
+		}
+		return env, nil
+	case "CLOSE":
+		var env CloseEnvelope
+		if err := json.Unmarshal(items[1], &env.SubscriptionID); err != nil {
+			return nil, fmt.Errorf("invalid subscription id on CLOSE: %w", err)
+		}
+		return env, nil
+	case "EOSE":
+		var env EOSEEnvelope
+		if err := json.Unmarshal(items[1], &env.SubscriptionID); err != nil {
+			return nil, fmt.Errorf("invalid subscription id on EOSE: %w", err)
+		}
+		return env, nil
+	case "NOTICE":
+		var env NoticeEnvelope
+		if err := json.Unmarshal(items[1], &env.Message); err != nil {
+			return nil, fmt.Errorf("invalid message on NOTICE: %w", err)
+		}
+		return env, nil
+	case "OK":
+		var env OKEnvelope
+		if len(items) < 3 {
+			return nil, fmt.Errorf("OK must have at least 3 items, not %d", len(items))
+		}
+		if err := json.Unmarshal(items[1], &env.EventID); err != nil {
+			return nil, fmt.Errorf("invalid event id on OK: %w", err)
+		}
+		if err := json.Unmarshal(items[2], &env.OK); err != nil {
+			return nil, fmt.Errorf("invalid status on OK: %w", err)
+		}
+		if len(items) >= 4 {
+			if err := json.Unmarshal(items[3], &env.Reason); err != nil {
+				return nil, fmt.Errorf("invalid message on OK: %w", err)
+			}
+		}
+		return env, nil
+	case "COUNT":
+		var env CountEnvelope
+		if err := json.Unmarshal(items[1], &env.SubscriptionID); err != nil {
+			return nil, fmt.Errorf("invalid subscription id on COUNT: %w", err)
+		}
+		if len(items) == 3 {
+			var result struct {
+				Count *int64 `json:"count"`
+			}
+			if err := json.Unmarshal(items[2], &result); err == nil && result.Count != nil {
+				env.Count = result.Count
+				return env, nil
+			}
+		}
+		env.Filters = make(Filters, len(items)-2)
+		for i, rawFilter := range items[2:] {
+			if err := env.Filters[i].UnmarshalJSON(rawFilter); err != nil {
+				return nil, fmt.Errorf("invalid filter on COUNT: %w", err)
+			}
+		}
+		return env, nil
+	case "CLOSED":
+		var env ClosedEnvelope
+		if err := json.Unmarshal(items[1], &env.SubscriptionID); err != nil {
+			return nil, fmt.Errorf("invalid subscription id on CLOSED: %w", err)
+		}
+		if len(items) >= 3 {
+			if err := json.Unmarshal(items[2], &env.Reason); err != nil {
+				return nil, fmt.Errorf("invalid message on CLOSED: %w", err)
+			}
+		}
+		return env, nil
+	case "AUTH":
+		var env AuthEnvelope
+		if err := json.Unmarshal(items[1], &env.Challenge); err == nil {
+			return env, nil
+		}
+		env.Event = &Event{}
+		if err := env.Event.UnmarshalJSON(items[1]); err != nil {
+			return nil, fmt.Errorf("invalid challenge or event on AUTH: %w", err)
+		}
+		return env, nil
+	case "NEG-OPEN":
+		var env NegOpenEnvelope
+		if len(items) < 4 {
+			return nil, fmt.Errorf("NEG-OPEN must have 4 items, not %d", len(items))
+		}
+		if err := json.Unmarshal(items[1], &env.SubscriptionID); err != nil {
+			return nil, fmt.Errorf("invalid subscription id on NEG-OPEN: %w", err)
+		}
+		if err := env.Filter.UnmarshalJSON(items[2]); err != nil {
+			return nil, fmt.Errorf("invalid filter on NEG-OPEN: %w", err)
+		}
+		if err := json.Unmarshal(items[3], &env.Message); err != nil {
+			return nil, fmt.Errorf("invalid message on NEG-OPEN: %w", err)
+		}
+		return env, nil
+	case "NEG-MSG":
+		var env NegMsgEnvelope
+		if len(items) < 3 {
+			return nil, fmt.Errorf("NEG-MSG must have 3 items, not %d", len(items))
+		}
+		if err := json.Unmarshal(items[1], &env.SubscriptionID); err != nil {
+			return nil, fmt.Errorf("invalid subscription id on NEG-MSG: %w", err)
+		}
+		if err := json.Unmarshal(items[2], &env.Message); err != nil {
+			return nil, fmt.Errorf("invalid message on NEG-MSG: %w", err)
+		}
+		return env, nil
+	case "NEG-ERR":
+		var env NegErrEnvelope
+		if err := json.Unmarshal(items[1], &env.SubscriptionID); err != nil {
+			return nil, fmt.Errorf("invalid subscription id on NEG-ERR: %w", err)
+		}
+		if len(items) >= 3 {
+			if err := json.Unmarshal(items[2], &env.Reason); err != nil {
+				return nil, fmt.Errorf("invalid reason on NEG-ERR: %w", err)
+			}
+		}
+		return env, nil
+	case "NEG-CLOSE":
+		var env NegCloseEnvelope
+		if err := json.Unmarshal(items[1], &env.SubscriptionID); err != nil {
+			return nil, fmt.Errorf("invalid subscription id on NEG-CLOSE: %w", err)
+		}
+		return env, nil
+	default:
+		return nil, fmt.Errorf("%w '%s'", ErrUnknownEnvelope, label)
+	}
+}
+
+func (env EventEnvelope) MarshalJSON() ([]byte, error) {
+	if env.SubscriptionID == "" {
+		return json.Marshal([]interface{}{"EVENT", env.Event})
+	}
+	return json.Marshal([]interface{}{"EVENT", env.SubscriptionID, env.Event})
+}
+
+func (env ReqEnvelope) MarshalJSON() ([]byte, error) {
+	message := []interface{}{"REQ", env.SubscriptionID}
+	for _, filter := range env.Filters {
+		message = append(message, filter)
+	}
+	return json.Marshal(message)
+}
+
+func (env CloseEnvelope) MarshalJSON() ([]byte, error) {
+	return json.Marshal([]interface{}{"CLOSE", env.SubscriptionID})
+}
+
+func (env EOSEEnvelope) MarshalJSON() ([]byte, error) {
+	return json.Marshal([]interface{}{"EOSE", env.SubscriptionID})
+}
+
+func (env NoticeEnvelope) MarshalJSON() ([]byte, error) {
+	return json.Marshal([]interface{}{"NOTICE", env.Message})
+}
+
+func (env OKEnvelope) MarshalJSON() ([]byte, error) {
+	return json.Marshal([]interface{}{"OK", env.EventID, env.OK, env.Reason})
+}
+
+func (env CountEnvelope) MarshalJSON() ([]byte, error) {
+	if env.Count != nil {
+		return json.Marshal([]interface{}{"COUNT", env.SubscriptionID, map[string]int64{"count": *env.Count}})
+	}
+	message := []interface{}{"COUNT", env.SubscriptionID}
+	for _, filter := range env.Filters {
+		message = append(message, filter)
+	}
+	return json.Marshal(message)
+}
+
+func (env ClosedEnvelope) MarshalJSON() ([]byte, error) {
+	return json.Marshal([]interface{}{"CLOSED", env.SubscriptionID, env.Reason})
+}
+
+func (env AuthEnvelope) MarshalJSON() ([]byte, error) {
+	if env.Event != nil {
+		return json.Marshal([]interface{}{"AUTH", env.Event})
+	}
+	return json.Marshal([]interface{}{"AUTH", env.Challenge})
+}
+
+func (env NegOpenEnvelope) MarshalJSON() ([]byte, error) {
+	return json.Marshal([]interface{}{"NEG-OPEN", env.SubscriptionID, env.Filter, env.Message})
+}
+
+func (env NegMsgEnvelope) MarshalJSON() ([]byte, error) {
+	return json.Marshal([]interface{}{"NEG-MSG", env.SubscriptionID, env.Message})
+}
+
+func (env NegErrEnvelope) MarshalJSON() ([]byte, error) {
+	return json.Marshal([]interface{}{"NEG-ERR", env.SubscriptionID, env.Reason})
+}
+
+func (env NegCloseEnvelope) MarshalJSON() ([]byte, error) {
+	return json.Marshal([]interface{}{"NEG-CLOSE", env.SubscriptionID})
+}
@@ -0,0 +1,68 @@
+package nostr
+
+import "testing"
+
+func TestParseMessage(t *testing.T) {
+	env, err := ParseMessage([]byte(`["EVENT","sub1",{"id":"abc","pubkey":"def","created_at":1644271588,"kind":1,"tags":[],"content":"hello","sig":"ghi"}]`))
+	if err != nil {
+		t.Fatalf("failed to parse EVENT: %v", err)
+	}
+	if ev, ok := env.(EventEnvelope); !ok || ev.SubscriptionID != "sub1" || ev.Event.Content != "hello" {
+		t.Errorf("wrong EVENT envelope: %v", env)
+	}
+
+	env, err = ParseMessage([]byte(`["OK","abc",false,"blocked: no"]`))
+	if ok, _ := env.(OKEnvelope); err != nil || ok.EventID != "abc" || ok.OK || ok.Reason != "blocked: no" {
+		t.Errorf("wrong OK envelope: %v %v", env, err)
+	}
+
+	env, err = ParseMessage([]byte(`["REQ","sub2",{"kinds":[1]},{"authors":["abc"]}]`))
+	if req, _ := env.(ReqEnvelope); err != nil || req.SubscriptionID != "sub2" || len(req.Filters) != 2 {
+		t.Errorf("wrong REQ envelope: %v %v", env, err)
+	}
+
+	env, err = ParseMessage([]byte(`["COUNT","sub3",{"count":42}]`))
+	if count, _ := env.(CountEnvelope); err != nil || count.SubscriptionID != "sub3" || count.Count == nil || *count.Count != 42 {
+		t.Errorf("wrong COUNT response envelope: %v %v", env, err)
+	}
+
+	env, err = ParseMessage([]byte(`["COUNT","sub4",{"kinds":[3]}]`))
+	if count, _ := env.(CountEnvelope); err != nil || count.Count != nil || len(count.Filters) != 1 {
+		t.Errorf("wrong COUNT request envelope: %v %v", env, err)
+	}
+
+	env, err = ParseMessage([]byte(`["CLOSED","sub5","unsupported: no"]`))
+	if closed, _ := env.(ClosedEnvelope); err != nil || closed.SubscriptionID != "sub5" || closed.Reason != "unsupported: no" {
+		t.Errorf("wrong CLOSED envelope: %v %v", env, err)
+	}
+
+	env, err = ParseMessage([]byte(`["AUTH","challenge-1"]`))
+	if auth, _ := env.(AuthEnvelope); err != nil || auth.Challenge != "challenge-1" || auth.Event != nil {
+		t.Errorf("wrong AUTH challenge envelope: %v %v", env, err)
+	}
+
+	env, err = ParseMessage([]byte(`["AUTH",{"id":"abc","pubkey":"def","created_at":1644271588,"kind":22242,"tags":[["challenge","challenge-1"]],"content":"","sig":"ghi"}]`))
+	if auth, _ := env.(AuthEnvelope); err != nil || auth.Event == nil || auth.Event.Kind != KindClientAuthentication {
+		t.Errorf("wrong AUTH event envelope: %v %v", env, err)
+	}
+
+	env, err = ParseMessage([]byte(`["NEG-OPEN","sub6",{"kinds":[1]},"6100000200"]`))
+	if open, _ := env.(NegOpenEnvelope); err != nil || open.SubscriptionID != "sub6" || open.Filter.Kinds[0] != 1 || open.Message != "6100000200" {
+		t.Errorf("wrong NEG-OPEN envelope: %v %v", env, err)
+	}
+
+	env, err = ParseMessage([]byte(`["NEG-ERR","sub6","blocked: too many records"]`))
+	if negerr, _ := env.(NegErrEnvelope); err != nil || negerr.Reason != "blocked: too many records" {
+		t.Errorf("wrong NEG-ERR envelope: %v %v", env, err)
+	}
+
+	for _, malformed := range []string{
+		``, `{}`, `[]`, `["EOSE"]`, `[1,"x"]`, `["UNKNOWN","x"]`, `["OK","abc"]`,
+		`["EVENT","sub1",{"kind":"x"}]`, `["REQ","sub",[]]`, `["NOTICE",{}]`,
+		`["NEG-OPEN","sub",{}]`, `["AUTH",1]`, `["NEG-MSG","sub"]`,
+	} {
+		if _, err := ParseMessage([]byte(malformed)); err == nil {
+			t.Errorf("parsed malformed message %s", malformed)
+		}
+	}
+}
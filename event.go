@@ -2,67 +2,200 @@ package nostr
 
 import (
 	"crypto/rand"
-	"crypto/sha256"
 	"encoding/hex"
+	"errors"
 	"fmt"
+	"io"
 	"time"
 
 	"github.com/fiatjaf/bip340"
-	"github.com/valyala/fastjson"
+	"github.com/fiatjaf/go-nostr/nip19"
 )
 
 type Event struct {
 	ID        string
 	PubKey    string
-	CreatedAt time.Time
+	CreatedAt Timestamp
 	Kind      int
 	Tags      Tags
 	Content   string
 	Sig       string
 }
 
-const (
-	KindSetMetadata            int = 0
-	KindTextNote               int = 1
-	KindRecommendServer        int = 2
-	KindContactList            int = 3
-	KindEncryptedDirectMessage int = 4
-	KindDeletion               int = 5
+var (
+	ErrIDMismatch       = errors.New("event id doesn't match its serialized content")
+	ErrInvalidSignature = errors.New("event signature is invalid")
+	ErrFutureEvent      = errors.New("event is dated too far in the future")
 )
 
-// GetID serializes and returns the event ID as a string
-func (evt *Event) GetID() string {
-	h := sha256.Sum256(evt.Serialize())
-	return hex.EncodeToString(h[:])
+// DefaultFutureTolerance is a reasonable tolerance for ValidateWithClock, it
+// allows for small differences between clocks.
+const DefaultFutureTolerance = 5 * time.Minute
+
+// NewEvent creates an unsigned event with the given kind and content,
+// created now.
+func NewEvent(kind int, content string) *Event {
+	return &Event{
+		CreatedAt: Now(),
+		Kind:      kind,
+		Tags:      make(Tags, 0),
+		Content:   content,
+	}
 }
 
-// Serialize outputs a byte array that can be hashed/signed to identify/authenticate
-func (evt *Event) Serialize() []byte {
-	// the serialization process is just putting everything into a JSON array
-	// so the order is kept
-	var arena fastjson.Arena
+// WithTag appends a tag to the event and returns it, for chaining.
+func (evt *Event) WithTag(name string, values ...string) *Event {
+	evt.Tags = append(evt.Tags, append(Tag{name}, values...))
+	return evt
+}
 
-	arr := arena.NewArray()
+// NormalizeTags calls Tags.Normalize, unless the event is already signed, in
+// which case changing the order would invalidate it and it does nothing. It
+// returns whether the tags were normalized.
+func (evt *Event) NormalizeTags() bool {
+	if evt.Sig != "" {
+		return false
+	}
+	evt.Tags.Normalize()
+	return true
+}
+
+// GetTagValue returns the first value of the first tag with the given name,
+// for single-value tags like "d", "title" or "expiration".
+func (evt *Event) GetTagValue(name string) (string, bool) {
+	tag := evt.Tags.GetFirst(name)
+	if tag == nil || len(*tag) < 2 {
+		return "", false
+	}
+	return (*tag)[1], true
+}
+
+// SetTagValue replaces the first tag with the given name by one with only
+// values, dropping any other with that name, or appends it if there is none.
+// The tags are copied to a new slice, so events sharing them with evt aren't
+// affected.
+func (evt *Event) SetTagValue(name string, values ...string) {
+	tag := append(Tag{name}, values...)
+	tags := make(Tags, 0, len(evt.Tags)+1)
+	set := false
+	for _, t := range evt.Tags {
+		if len(t) >= 1 && t[0] == name {
+			if !set {
+				tags = append(tags, tag)
+				set = true
+			}
+			continue
+		}
+		tags = append(tags, t)
+	}
+	if !set {
+		tags = append(tags, tag)
+	}
+	evt.Tags = tags
+}
+
+// Clone returns a deep copy of the event, its tags can be modified without
+// affecting the original.
+func (evt *Event) Clone() *Event {
+	clone := &Event{
+		ID:        evt.ID,
+		PubKey:    evt.PubKey,
+		CreatedAt: evt.CreatedAt,
+		Kind:      evt.Kind,
+		Content:   evt.Content,
+		Sig:       evt.Sig,
+	}
+	if evt.Tags != nil {
+		clone.Tags = make(Tags, len(evt.Tags))
+		for i, tag := range evt.Tags {
+			clone.Tags[i] = append(make(Tag, 0, len(tag)), tag...)
+		}
+	}
+	return clone
+}
+
+// SameID checks if both events have the same id. It is enough to tell apart
+// events that were already validated, like the ones received from relays.
+func (evt *Event) SameID(other *Event) bool {
+	return evt.ID == other.ID
+}
+
+// Equals checks if both events have the same id, serialized content (which
+// includes the tags and their order) and signature. Use it instead of SameID
+// when the events may not have been validated, as an id is easy to spoof.
+func (evt *Event) Equals(other *Event) bool {
+	return evt.ID == other.ID && evt.Sig == other.Sig &&
+		evt.serializedHash() == other.serializedHash()
+}
+
+// SignWith sets the event pubkey to the one derived from privateKey and
+// signs the event with it.
+func (evt *Event) SignWith(privateKey string) (*Event, error) {
+	pubkey, err := GetPublicKey(privateKey)
+	if err != nil {
+		return nil, fmt.Errorf("SignWith called with invalid private key: %w", err)
+	}
+
+	evt.PubKey = pubkey
+	if err := evt.Sign(privateKey); err != nil {
+		return nil, err
+	}
+	return evt, nil
+}
+
+// SignWithBech32 is like SignWith, but takes the private key as a NIP-19
+// "nsec1..." string.
+func (evt *Event) SignWithBech32(nsec string) error {
+	prefix, privateKey, err := nip19.Decode(nsec)
+	if err != nil {
+		return fmt.Errorf("SignWithBech32 called with invalid nsec: %w", err)
+	}
+	if prefix != "nsec" {
+		return fmt.Errorf("SignWithBech32 needs an 'nsec' private key, not an '%s'", prefix)
+	}
 
-	// version: 0
-	arr.SetArrayItem(0, arena.NewNumberInt(0))
+	_, err = evt.SignWith(privateKey)
+	return err
+}
 
-	// pubkey
-	arr.SetArrayItem(1, arena.NewString(evt.PubKey))
+// ToNote encodes the event id as a NIP-19 "note1..." string, which has no
+// relay or author hints, see nip19.EncodeEvent for that.
+func (evt *Event) ToNote() (string, error) {
+	return nip19.EncodeNote(evt.ID)
+}
 
-	// created_at
-	arr.SetArrayItem(2, arena.NewNumberInt(int(evt.CreatedAt.Unix())))
+// DecodeNote returns the hex event id from a NIP-19 "note1..." string.
+func DecodeNote(note string) (id string, err error) {
+	prefix, id, err := nip19.Decode(note)
+	if err != nil {
+		return "", fmt.Errorf("invalid note: %w", err)
+	}
+	if prefix != "note" {
+		return "", fmt.Errorf("expected a 'note', not an '%s'", prefix)
+	}
+	return id, nil
+}
 
-	// kind
-	arr.SetArrayItem(3, arena.NewNumberInt(evt.Kind))
+// CreatedAtTime returns CreatedAt as a time.Time.
+func (evt *Event) CreatedAtTime() time.Time {
+	return evt.CreatedAt.Time()
+}
 
-	// tags
-	arr.SetArrayItem(4, tagsToFastjsonArray(&arena, evt.Tags))
+// GetID serializes and returns the event ID as a string
+func (evt *Event) GetID() string {
+	h := evt.serializedHash()
+	return hex.EncodeToString(h[:])
+}
 
-	// content
-	arr.SetArrayItem(5, arena.NewString(evt.Content))
+// VerifyID checks that the id is the hash of the serialized event, without
+// checking the signature, which is much more expensive.
+func (evt *Event) VerifyID() bool {
+	return evt.GetID() == evt.ID
+}
 
-	return arr.MarshalTo(nil)
+// Serialize outputs a byte array that can be hashed/signed to identify/authenticate
+func (evt *Event) Serialize() []byte {
+	return appendSerialized(make([]byte, 0, 256+len(evt.Content)), evt)
 }
 
 // CheckSignature checks if the signature is valid for the id
@@ -86,21 +219,65 @@ func (evt Event) CheckSignature() (bool, error) {
 	var sig [64]byte
 	copy(sig[:], s)
 
-	hash := sha256.Sum256(evt.Serialize())
-	return bip340.Verify(pubkey, hash, sig)
+	return bip340.Verify(pubkey, evt.serializedHash(), sig)
+}
+
+// Validate checks that the event is trustworthy: its pubkey and signature
+// are well-formed, its id is the hash of its content and its signature is
+// valid for that id. The returned error wraps ErrIDMismatch or
+// ErrInvalidSignature when these checks fail.
+func (evt *Event) Validate() error {
+	// the cheap check first, so tampered events don't get to the signature
+	if !evt.VerifyID() {
+		return fmt.Errorf("%w: expected %s, got %s", ErrIDMismatch, evt.GetID(), evt.ID)
+	}
+
+	if pk, err := hex.DecodeString(evt.PubKey); err != nil || len(pk) != 32 {
+		return fmt.Errorf("pubkey '%s' is not 32 bytes hex", evt.PubKey)
+	}
+
+	if sig, err := hex.DecodeString(evt.Sig); err != nil || len(sig) != 64 {
+		return fmt.Errorf("%w: not 64 bytes hex", ErrInvalidSignature)
+	}
+
+	if ok, err := evt.CheckSignature(); !ok {
+		if err != nil {
+			return fmt.Errorf("%w: %s", ErrInvalidSignature, err)
+		}
+		return ErrInvalidSignature
+	}
+
+	return nil
+}
+
+// ValidateWithClock is like Validate, but also fails with ErrFutureEvent if the
+// event was created more than tolerance after now, see DefaultFutureTolerance.
+func (evt *Event) ValidateWithClock(now time.Time, tolerance time.Duration) error {
+	if max := now.Add(tolerance); evt.CreatedAtTime().After(max) {
+		return fmt.Errorf("%w: created at %d, after %d", ErrFutureEvent, evt.CreatedAt, max.Unix())
+	}
+	return evt.Validate()
 }
 
 // Sign signs an event with a given privateKey
 func (evt *Event) Sign(privateKey string) error {
-	h := sha256.Sum256(evt.Serialize())
+	return evt.SignWithReader(privateKey, rand.Reader)
+}
 
-	s, err := bip340.ParsePrivateKey(privateKey)
+// SignWithReader signs an event with a given privateKey, reading the 32
+// bytes of auxiliary randomness from r. A fixed r gives stable signatures.
+func (evt *Event) SignWithReader(privateKey string, r io.Reader) error {
+	h := evt.serializedHash()
+
+	s, err := parsePrivateKey(privateKey)
 	if err != nil {
 		return fmt.Errorf("Sign called with invalid private key '%s': %w", privateKey, err)
 	}
 
 	aux := make([]byte, 32)
-	rand.Read(aux)
+	if _, err := io.ReadFull(r, aux); err != nil {
+		return fmt.Errorf("failed to read auxiliary randomness: %w", err)
+	}
 	sig, err := bip340.Sign(s, h, aux)
 	if err != nil {
 		return err
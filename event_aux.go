@@ -1,50 +1,151 @@
 package nostr
 
 import (
+	"bufio"
+	"bytes"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
+	"strconv"
 	"time"
+	"unicode/utf8"
 
 	"github.com/valyala/fastjson"
 )
 
-type Tags []StringList
+// MaxEventSize is the maximum number of bytes DecodeEvent reads for a single
+// event before giving up with ErrEventTooLarge. Zero disables the limit.
+var MaxEventSize int64 = 1 << 20
 
-func (t *Tags) Scan(src interface{}) error {
-	var jtags []byte = make([]byte, 0)
+var ErrEventTooLarge = errors.New("event is too large")
 
-	switch v := src.(type) {
-	case []byte:
-		jtags = v
-	case string:
-		jtags = []byte(v)
-	default:
-		return errors.New("couldn't scan tags, it's not a json string")
+// DecodeEvent reads a single event object from r, without reading more than
+// MaxEventSize bytes from it, so a huge payload is rejected before it is held
+// in memory. Use json.Unmarshal for events that are already in a buffer.
+func DecodeEvent(r io.Reader) (*Event, error) {
+	if MaxEventSize > 0 {
+		r = &eventSizeLimiter{r: r, remaining: MaxEventSize}
 	}
 
-	json.Unmarshal(jtags, &t)
-	return nil
+	var evt Event
+	if err := json.NewDecoder(r).Decode(&evt); err != nil {
+		if errors.Is(err, ErrEventTooLarge) {
+			return nil, fmt.Errorf("%w: more than %d bytes", ErrEventTooLarge, MaxEventSize)
+		}
+		return nil, fmt.Errorf("failed to decode event: %w", err)
+	}
+	return &evt, nil
 }
 
-func (tags Tags) ContainsAny(tagName string, values StringList) bool {
-	for _, tag := range tags {
-		if len(tag) < 2 {
-			continue
+// ReadEvents decodes one event per line from r, as in .jsonl backups, until
+// it ends. Lines that aren't valid events, or are longer than MaxEventSize,
+// are reported on errs with their line number and skipped, blank lines are
+// ignored. Both channels are closed at the end and must be read until then, a
+// failure to read from r is the last error.
+func ReadEvents(r io.Reader) (<-chan *Event, <-chan error) {
+	events := make(chan *Event)
+	errs := make(chan error)
+
+	go func() {
+		defer close(events)
+		defer close(errs)
+
+		reader := bufio.NewReader(r)
+		for n := 1; ; n++ {
+			line, err := readLine(reader)
+			switch {
+			case err == ErrEventTooLarge:
+				errs <- fmt.Errorf("line %d: %w: more than %d bytes", n, err, MaxEventSize)
+				continue
+			case err != nil && err != io.EOF:
+				errs <- fmt.Errorf("failed to read line %d: %w", n, err)
+				return
+			}
+
+			if len(bytes.TrimSpace(line)) > 0 {
+				var evt Event
+				if perr := evt.UnmarshalJSON(line); perr != nil {
+					errs <- fmt.Errorf("line %d: %w", n, perr)
+				} else {
+					events <- &evt
+				}
+			}
+			if err == io.EOF {
+				return
+			}
+		}
+	}()
+
+	return events, errs
+}
+
+// readLine reads up to the next newline, which is left out. If the line is
+// longer than MaxEventSize the rest of it is skipped and ErrEventTooLarge is
+// returned.
+func readLine(reader *bufio.Reader) ([]byte, error) {
+	var line []byte
+	tooLarge := false
+	for {
+		chunk, err := reader.ReadSlice('\n')
+		if !tooLarge {
+			line = append(line, chunk...)
+			if MaxEventSize > 0 && int64(len(bytes.TrimSuffix(line, []byte{'\n'}))) > MaxEventSize {
+				tooLarge, line = true, nil
+			}
 		}
 
-		if tag[0] != tagName {
+		switch {
+		case err == bufio.ErrBufferFull:
 			continue
+		case err != nil && err != io.EOF:
+			return nil, err
+		case tooLarge:
+			return nil, ErrEventTooLarge
 		}
+		return bytes.TrimSuffix(line, []byte{'\n'}), err
+	}
+}
 
-		if values.Contains(tag[1]) {
-			return true
+// WriteEvents writes each event from the channel as a line of canonical json,
+// until the channel is closed. It stops at the first write error, after which
+// nobody reads from the channel anymore.
+func WriteEvents(w io.Writer, events <-chan *Event) error {
+	writer := bufio.NewWriter(w)
+	for evt := range events {
+		line, _ := evt.MarshalJSON()
+		line = append(line, '\n')
+		if _, err := writer.Write(line); err != nil {
+			return fmt.Errorf("failed to write event '%s': %w", evt.ID, err)
 		}
 	}
+	if err := writer.Flush(); err != nil {
+		return fmt.Errorf("failed to write events: %w", err)
+	}
+	return nil
+}
 
-	return false
+// eventSizeLimiter is like io.LimitReader, but fails instead of reporting
+// io.EOF when the limit is reached, so a truncated event isn't mistaken for
+// invalid json.
+type eventSizeLimiter struct {
+	r         io.Reader
+	remaining int64
 }
 
+func (l *eventSizeLimiter) Read(p []byte) (int, error) {
+	if l.remaining <= 0 {
+		return 0, ErrEventTooLarge
+	}
+	if int64(len(p)) > l.remaining {
+		p = p[:l.remaining]
+	}
+	n, err := l.r.Read(p)
+	l.remaining -= int64(n)
+	return n, err
+}
+
+// UnmarshalJSON parses the canonical event object, ignoring unknown fields.
 func (evt *Event) UnmarshalJSON(payload []byte) error {
 	var fastjsonParser fastjson.Parser
 	parsed, err := fastjsonParser.ParseBytes(payload)
@@ -57,49 +158,60 @@ func (evt *Event) UnmarshalJSON(payload []byte) error {
 		return fmt.Errorf("event is not an object")
 	}
 
+	// the input comes straight from relays, so keep the first problem found
 	var visiterr error
+	fail := func(err error) {
+		if visiterr == nil {
+			visiterr = err
+		}
+	}
 	obj.Visit(func(k []byte, v *fastjson.Value) {
 		key := string(k)
 		switch key {
 		case "id":
 			id, err := v.StringBytes()
 			if err != nil {
-				visiterr = fmt.Errorf("invalid 'id' field: %w", err)
+				fail(fmt.Errorf("invalid 'id' field: %w", err))
 			}
 			evt.ID = string(id)
 		case "pubkey":
 			id, err := v.StringBytes()
 			if err != nil {
-				visiterr = fmt.Errorf("invalid 'pubkey' field: %w", err)
+				fail(fmt.Errorf("invalid 'pubkey' field: %w", err))
 			}
 			evt.PubKey = string(id)
 		case "created_at":
 			val, err := v.Int64()
 			if err != nil {
-				visiterr = fmt.Errorf("invalid 'created_at' field: %w", err)
+				fail(fmt.Errorf("invalid 'created_at' field: %w", err))
+			} else if val < 0 {
+				fail(fmt.Errorf("invalid 'created_at' field: %d is negative", val))
 			}
-			evt.CreatedAt = time.Unix(val, 0)
+			evt.CreatedAt = Timestamp(val)
 		case "kind":
-			kind, err := v.Int64()
+			kind, err := v.Int()
 			if err != nil {
-				visiterr = fmt.Errorf("invalid 'kind' field: %w", err)
+				fail(fmt.Errorf("invalid 'kind' field: %w", err))
+			} else if kind < 0 {
+				fail(fmt.Errorf("invalid 'kind' field: %d is negative", kind))
 			}
-			evt.Kind = int(kind)
+			evt.Kind = kind
 		case "tags":
-			evt.Tags, err = fastjsonArrayToTags(v)
+			tags, err := fastjsonArrayToTags(v)
 			if err != nil {
-				visiterr = fmt.Errorf("invalid '%s' field: %w", key, err)
+				fail(fmt.Errorf("invalid '%s' field: %w", key, err))
 			}
+			evt.Tags = tags
 		case "content":
 			id, err := v.StringBytes()
 			if err != nil {
-				visiterr = fmt.Errorf("invalid 'content' field: %w", err)
+				fail(fmt.Errorf("invalid 'content' field: %w", err))
 			}
 			evt.Content = string(id)
 		case "sig":
 			id, err := v.StringBytes()
 			if err != nil {
-				visiterr = fmt.Errorf("invalid 'sig' field: %w", err)
+				fail(fmt.Errorf("invalid 'sig' field: %w", err))
 			}
 			evt.Sig = string(id)
 		}
@@ -111,19 +223,26 @@ func (evt *Event) UnmarshalJSON(payload []byte) error {
 	return nil
 }
 
+// MarshalJSON outputs the canonical event object, with created_at as a unix
+// timestamp, as it is exchanged with relays.
 func (evt Event) MarshalJSON() ([]byte, error) {
-	var arena fastjson.Arena
-
-	o := arena.NewObject()
-	o.Set("id", arena.NewString(evt.ID))
-	o.Set("pubkey", arena.NewString(evt.PubKey))
-	o.Set("created_at", arena.NewNumberInt(int(evt.CreatedAt.Unix())))
-	o.Set("kind", arena.NewNumberInt(evt.Kind))
-	o.Set("tags", tagsToFastjsonArray(&arena, evt.Tags))
-	o.Set("content", arena.NewString(evt.Content))
-	o.Set("sig", arena.NewString(evt.Sig))
-
-	return o.MarshalTo(nil), nil
+	dst := make([]byte, 0, 400+len(evt.Content))
+	dst = append(dst, `{"id":`...)
+	dst = appendJSONString(dst, evt.ID)
+	dst = append(dst, `,"pubkey":`...)
+	dst = appendJSONString(dst, evt.PubKey)
+	dst = append(dst, `,"created_at":`...)
+	dst = strconv.AppendInt(dst, int64(evt.CreatedAt), 10)
+	dst = append(dst, `,"kind":`...)
+	dst = strconv.AppendInt(dst, int64(evt.Kind), 10)
+	dst = append(dst, `,"tags":`...)
+	dst = appendTags(dst, evt.Tags)
+	dst = append(dst, `,"content":`...)
+	dst = appendJSONString(dst, evt.Content)
+	dst = append(dst, `,"sig":`...)
+	dst = appendJSONString(dst, evt.Sig)
+	dst = append(dst, '}')
+	return dst, nil
 }
 
 func fastjsonArrayToTags(v *fastjson.Value) (Tags, error) {
@@ -132,35 +251,50 @@ func fastjsonArrayToTags(v *fastjson.Value) (Tags, error) {
 		return nil, err
 	}
 
-	sll := make([]StringList, len(arr))
+	sll := make(Tags, len(arr))
 	for i, v := range arr {
 		subarr, err := v.Array()
 		if err != nil {
-			return nil, err
+			return nil, fmt.Errorf("tag %d is not an array: %w", i, err)
 		}
 
-		sl := make(StringList, len(subarr))
+		sl := make(Tag, len(subarr))
 		for j, subv := range subarr {
 			sb, err := subv.StringBytes()
 			if err != nil {
-				return nil, err
+				return nil, fmt.Errorf("item %d of tag %d is not a string: %w", j, i, err)
 			}
 			sl[j] = string(sb)
 		}
 		sll[i] = sl
 	}
 
-	return Tags(sll), nil
+	return sll, nil
 }
 
-func tagsToFastjsonArray(arena *fastjson.Arena, tags Tags) *fastjson.Value {
-	jtags := arena.NewArray()
-	for i, v := range tags {
-		arr := arena.NewArray()
-		for j, subv := range v {
-			arr.SetArrayItem(j, arena.NewString(subv))
-		}
-		jtags.SetArrayItem(i, arr)
+// String returns a one-line summary of the event for logs, like
+// `kind=1 id=dc90c95f… by 3bf0c63f… at 2022-02-07T22:06:28Z tags=0 "now that…"`,
+// with ids and pubkeys cut to 8 characters and the content to 40.
+func (evt *Event) String() string {
+	if evt == nil {
+		return "<nil>"
+	}
+	return fmt.Sprintf("kind=%d id=%s by %s at %s tags=%d %q", evt.Kind, truncate(evt.ID, 8),
+		truncate(evt.PubKey, 8), evt.CreatedAt.Time().UTC().Format(time.RFC3339), len(evt.Tags),
+		truncate(evt.Content, 40))
+}
+
+// Dump returns the event as indented JSON, for debugging.
+func (evt *Event) Dump() string {
+	j, _ := json.MarshalIndent(evt, "", "  ")
+	return string(j)
+}
+
+// truncate cuts s to max runes, marking it with an ellipsis if it was longer.
+func truncate(s string, max int) string {
+	if utf8.RuneCountInString(s) <= max {
+		return s
 	}
-	return jtags
+	runes := []rune(s)
+	return string(runes[:max]) + "…"
 }
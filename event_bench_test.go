@@ -0,0 +1,129 @@
+package nostr
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func benchmarkEvent() *Event {
+	evt := NewEvent(KindTextNote, "benchmarking the serialization of an event with \"quotes\"\nand ten tags")
+	for i := 0; i < 10; i++ {
+		evt.WithTag("p", "3bf0c63fcb93463407af97a5e5ee64fa883d107ef9e558472c4eb9aaaefa459d", "wss://relay.example.com")
+	}
+	return evt
+}
+
+func BenchmarkSerializeAndHash(b *testing.B) {
+	evt := benchmarkEvent()
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		h := sha256.Sum256(evt.Serialize())
+		hex.EncodeToString(h[:])
+	}
+}
+
+func BenchmarkGetIDMemoized(b *testing.B) {
+	evt := benchmarkEvent()
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		evt.GetID()
+	}
+}
+
+func BenchmarkGetIDUncached(b *testing.B) {
+	evt := benchmarkEvent()
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		// a different event each time, so nothing is memoized
+		evt.CreatedAt++
+		evt.GetID()
+	}
+}
+
+func BenchmarkGetIDUncachedParallel(b *testing.B) {
+	b.ReportAllocs()
+	b.RunParallel(func(pb *testing.PB) {
+		evt := benchmarkEvent()
+		for pb.Next() {
+			evt.CreatedAt++
+			evt.GetID()
+		}
+	})
+}
+
+func BenchmarkDecodeJSON(b *testing.B) {
+	evt := benchmarkEvent()
+	evt.SignWith(GeneratePrivateKey())
+	data, _ := json.Marshal(evt)
+	b.SetBytes(int64(len(data)))
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		var decoded Event
+		decoded.UnmarshalJSON(data)
+	}
+}
+
+func BenchmarkDecodeBinary(b *testing.B) {
+	evt := benchmarkEvent()
+	evt.SignWith(GeneratePrivateKey())
+	data, _ := evt.MarshalBinary()
+	b.SetBytes(int64(len(data)))
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		var decoded Event
+		decoded.UnmarshalBinary(data)
+	}
+}
+
+func BenchmarkTagsGetAll(b *testing.B) {
+	tags := benchmarkContactTags()
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		for _, tag := range tags.GetAll("p") {
+			_ = tag[1]
+		}
+	}
+}
+
+func BenchmarkTagsEach(b *testing.B) {
+	tags := benchmarkContactTags()
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		tags.Each("p", func(tag Tag) bool {
+			_ = tag[1]
+			return true
+		})
+	}
+}
+
+func benchmarkContactTags() Tags {
+	tags := make(Tags, 0, 1000)
+	for i := 0; i < 1000; i++ {
+		tags = append(tags, Tag{"p", strconv.Itoa(i)})
+	}
+	return tags
+}
+
+func BenchmarkMine16(b *testing.B) { benchmarkMine(b, 16) }
+func BenchmarkMine20(b *testing.B) { benchmarkMine(b, 20) }
+
+func benchmarkMine(b *testing.B, difficulty int) {
+	sk := GeneratePrivateKey()
+	var hashes float64
+	start := time.Now()
+	for i := 0; i < b.N; i++ {
+		evt := benchmarkEvent()
+		evt.Content += strconv.Itoa(i)
+		if err := evt.MineThenSign(context.Background(), difficulty, sk); err != nil {
+			b.Fatal(err)
+		}
+		nonce, _ := strconv.ParseFloat((*evt.Tags.GetFirst("nonce"))[1], 64)
+		hashes += nonce + 1
+	}
+	b.ReportMetric(hashes/time.Since(start).Seconds(), "hashes/s")
+}
@@ -0,0 +1,123 @@
+package nostr
+
+import (
+	"crypto/sha256"
+	"sync"
+	"sync/atomic"
+)
+
+// serializeBuffers holds the buffers events are serialized into to be hashed,
+// as the serialization isn't kept, so the same ones are reused across calls
+// and goroutines instead of being allocated and grown every time.
+var serializeBuffers = sync.Pool{
+	New: func() interface{} {
+		buf := make([]byte, 0, 1024)
+		return &buf
+	},
+}
+
+// maxPooledBuffer is the size above which a buffer isn't put back in the pool,
+// so a single huge event doesn't stay in memory.
+const maxPooledBuffer = 64 << 10
+
+// serialization is a memoized hash of Serialize(), along with a copy of the
+// fields it was computed from so it is only reused while none of them changes.
+type serialization struct {
+	pubkey    string
+	createdAt Timestamp
+	kind      int
+	content   string
+	tagSizes  []int
+	tagItems  []string
+
+	hash [32]byte
+}
+
+// serializations are the last hashes computed, kept out of Event so events can
+// still be copied and compared. Each event goes to a slot picked from fields
+// that signing doesn't change, so copies share it and Sign, GetID and
+// CheckSignature don't serialize the same event again; events in the same
+// slot just replace each other.
+var serializations [256]atomic.Value
+
+// maxCachedContent is the size above which the serialization of an event
+// isn't kept, so the cache can't hold on to too much memory.
+const maxCachedContent = 64 << 10
+
+// serializedHash returns the sha256 of Serialize(), reusing the previous
+// result if none of the signed fields has changed since.
+func (evt *Event) serializedHash() [32]byte {
+	slot := &serializations[evt.cacheSlot()]
+	if s, ok := slot.Load().(*serialization); ok && s.matches(evt) {
+		return s.hash
+	}
+
+	s := newSerialization(evt)
+	if len(evt.Content) <= maxCachedContent {
+		slot.Store(s)
+	}
+	return s.hash
+}
+
+// cacheSlot hashes the pubkey, created_at, kind and sizes with FNV-1a.
+func (evt *Event) cacheSlot() uint8 {
+	h := uint32(2166136261)
+	for i := 0; i < len(evt.PubKey); i++ {
+		h = (h ^ uint32(evt.PubKey[i])) * 16777619
+	}
+	for _, v := range [4]int64{int64(evt.CreatedAt), int64(evt.Kind), int64(len(evt.Content)), int64(len(evt.Tags))} {
+		h = (h ^ uint32(v) ^ uint32(v>>32)) * 16777619
+	}
+	return uint8(h ^ h>>8 ^ h>>16 ^ h>>24)
+}
+
+func newSerialization(evt *Event) *serialization {
+	s := &serialization{
+		pubkey:    evt.PubKey,
+		createdAt: evt.CreatedAt,
+		kind:      evt.Kind,
+		content:   evt.Content,
+		tagSizes:  make([]int, len(evt.Tags)),
+	}
+
+	n := 0
+	for i, tag := range evt.Tags {
+		s.tagSizes[i] = len(tag)
+		n += len(tag)
+	}
+	s.tagItems = make([]string, 0, n)
+	for _, tag := range evt.Tags {
+		s.tagItems = append(s.tagItems, tag...)
+	}
+
+	buf := serializeBuffers.Get().(*[]byte)
+	*buf = appendSerialized((*buf)[:0], evt)
+	s.hash = sha256.Sum256(*buf)
+	if cap(*buf) <= maxPooledBuffer {
+		serializeBuffers.Put(buf)
+	}
+	return s
+}
+
+func (s *serialization) matches(evt *Event) bool {
+	if s.pubkey != evt.PubKey || s.createdAt != evt.CreatedAt ||
+		s.kind != evt.Kind || s.content != evt.Content ||
+		len(s.tagSizes) != len(evt.Tags) {
+		return false
+	}
+
+	n := 0
+	for i, tag := range evt.Tags {
+		if s.tagSizes[i] != len(tag) {
+			return false
+		}
+		for _, item := range tag {
+			if s.tagItems[n] != item {
+				return false
+			}
+			n++
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,36 @@
+//go:build go1.18
+// +build go1.18
+
+package nostr
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func FuzzUnmarshalEvent(f *testing.F) {
+	f.Add([]byte(`{"id":"dc90c95f09947507c1044e8f48bcf6350aa6bff1507dd4acfc755b9239b5c962","pubkey":"3bf0c63fcb93463407af97a5e5ee64fa883d107ef9e558472c4eb9aaaefa459d","created_at":1644271588,"kind":1,"tags":[["e","xyz","wss://relay.example.com"]],"content":"hello \"world\"\n","sig":"230e9d8f0ddaf7eb70b5f7741ccfa37e87a455c9a469282e3464e2052d3192cd63a167e196e381ef9d7e69e9ea43af2443b839974dc85d8aaab9efe1d9296524"}`))
+	f.Add([]byte(`{"tags":[[1]],"kind":"1","created_at":-1}`))
+	f.Add([]byte(`{"content":"\ud800"}`))
+	f.Add([]byte(`[[[[[[[[`))
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		var evt Event
+		if err := evt.UnmarshalJSON(data); err != nil {
+			return
+		}
+
+		// whatever is accepted must survive a round trip
+		encoded, err := json.Marshal(evt)
+		if err != nil {
+			t.Fatalf("failed to encode accepted event: %v", err)
+		}
+		var decoded Event
+		if err := decoded.UnmarshalJSON(encoded); err != nil {
+			t.Fatalf("failed to decode re-encoded event %s: %v", encoded, err)
+		}
+		if !decoded.Equals(&evt) {
+			t.Fatalf("round trip changed the event: %v != %v", decoded, evt)
+		}
+	})
+}
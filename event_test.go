@@ -1,8 +1,23 @@
 package nostr
 
 import (
+	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
+
+	"github.com/fiatjaf/go-nostr/nip05"
+	"github.com/fiatjaf/go-nostr/nip11"
+	"github.com/fiatjaf/go-nostr/nip19"
 )
 
 func TestEventParsingAndVerifying(t *testing.T) {
@@ -15,7 +30,7 @@ func TestEventParsingAndVerifying(t *testing.T) {
 		var ev Event
 		err := json.Unmarshal([]byte(raw), &ev)
 		if err != nil {
-			t.Errorf("failed to parse event json: %w", err)
+			t.Errorf("failed to parse event json: %v", err)
 		}
 
 		if ev.GetID() != ev.ID {
@@ -26,9 +41,13 @@ func TestEventParsingAndVerifying(t *testing.T) {
 			t.Error("signature verification failed when it should have succeeded")
 		}
 
+		if err := ev.Validate(); err != nil {
+			t.Errorf("validation failed when it should have succeeded: %v", err)
+		}
+
 		asjson, err := json.Marshal(ev)
 		if err != nil {
-			t.Errorf("failed to re marshal event as json: %w", err)
+			t.Errorf("failed to re marshal event as json: %v", err)
 		}
 
 		if string(asjson) != raw {
@@ -37,3 +56,937 @@ func TestEventParsingAndVerifying(t *testing.T) {
 		}
 	}
 }
+
+func TestEventParsingUnknownFields(t *testing.T) {
+	raw := `{"id":"abc","pubkey":"def","created_at":1644271588,"kind":1,"tags":[["e","xyz"]],"content":"hello","sig":"ghi","seen_on":["wss://relay.example.com"],"extra":{"a":1}}`
+
+	var ev Event
+	if err := json.Unmarshal([]byte(raw), &ev); err != nil {
+		t.Fatalf("failed to parse event json with unknown fields: %v", err)
+	}
+
+	if ev.ID != "abc" || ev.PubKey != "def" || ev.CreatedAt != 1644271588 ||
+		ev.Kind != 1 || len(ev.Tags) != 1 || ev.Content != "hello" || ev.Sig != "ghi" {
+		t.Error("failed to parse event correctly")
+	}
+}
+
+func TestEventParsingHostile(t *testing.T) {
+	valid := `{"id":"abc","pubkey":"def","created_at":1644271588,"kind":1,"tags":[["e","xyz"]],"content":"hello","sig":"ghi"}`
+	hostile := []string{
+		`{"id":123,"pubkey":"def"}`,
+		`{"id":"abc","pubkey":["def"]}`,
+		`{"tags":"e"}`,
+		`{"tags":[["e",1]]}`,
+		`{"tags":[{"e":"xyz"}]}`,
+		`{"created_at":"1644271588"}`,
+		`{"kind":"1"}`,
+		`{"kind":1.5}`,
+		`{"kind":-1}`,
+		`{"created_at":-1}`,
+		`{"created_at":99999999999999999999999}`,
+		`{"created_at":1e30}`,
+		`{"content":null}`,
+		`[]`,
+		`"event"`,
+		`{"tags":` + strings.Repeat("[", 10000) + strings.Repeat("]", 10000) + `}`,
+	}
+	for i := 1; i < len(valid); i += 7 {
+		hostile = append(hostile, valid[:i])
+	}
+
+	for _, raw := range hostile {
+		var evt Event
+		if err := evt.UnmarshalJSON([]byte(raw)); err == nil {
+			t.Errorf("hostile input '%.40s' was accepted", raw)
+		}
+	}
+}
+
+func TestDecodeEvent(t *testing.T) {
+	raw := `{"id":"abc","pubkey":"def","created_at":1644271588,"kind":1,"tags":[["e","xyz"]],"content":"hello","sig":"ghi"}`
+
+	ev, err := DecodeEvent(strings.NewReader(raw + "\n" + raw))
+	if err != nil {
+		t.Fatalf("failed to decode event: %v", err)
+	}
+	if ev.ID != "abc" || ev.Content != "hello" || len(ev.Tags) != 1 {
+		t.Error("failed to decode event correctly")
+	}
+
+	defer func(max int64) { MaxEventSize = max }(MaxEventSize)
+	MaxEventSize = int64(len(raw))
+	if _, err := DecodeEvent(strings.NewReader(raw)); err != nil {
+		t.Errorf("event with exactly MaxEventSize bytes was rejected: %v", err)
+	}
+
+	big := NewEvent(KindArticle, strings.Repeat("a", 2*len(raw)))
+	bigj, _ := json.Marshal(big)
+	if _, err := DecodeEvent(bytes.NewReader(bigj)); !errors.Is(err, ErrEventTooLarge) {
+		t.Errorf("expected ErrEventTooLarge, got %v", err)
+	}
+
+	if _, err := DecodeEvent(strings.NewReader(`{"id":"abc",`)); err == nil || errors.Is(err, ErrEventTooLarge) {
+		t.Errorf("expected a decoding error for a truncated event, got %v", err)
+	}
+}
+
+func TestReadWriteEvents(t *testing.T) {
+	sk := GeneratePrivateKey()
+	var input bytes.Buffer
+	for i, content := range []string{"one", "two", "three"} {
+		evt, _ := NewEvent(KindTextNote, content).SignWith(sk)
+		line, _ := json.Marshal(evt)
+		input.Write(line)
+		input.WriteString("\n")
+		if i == 0 {
+			input.WriteString("{\"kind\":\"broken\"}\n\n")
+		}
+	}
+	input.WriteString(`{"content":"` + strings.Repeat("x", int(MaxEventSize)) + `"}` + "\n")
+	input.WriteString(`{"content":"no newline at the end"}`)
+
+	events, errs := ReadEvents(&input)
+	var read []*Event
+	var lines []string
+	for events != nil || errs != nil {
+		select {
+		case evt, ok := <-events:
+			if !ok {
+				events = nil
+				continue
+			}
+			read = append(read, evt)
+		case err, ok := <-errs:
+			if !ok {
+				errs = nil
+				continue
+			}
+			lines = append(lines, strings.SplitN(err.Error(), ":", 2)[0])
+		}
+	}
+	if len(read) != 4 || read[1].Content != "two" || read[3].Content != "no newline at the end" {
+		t.Errorf("wrong events: %v", read)
+	}
+	if len(lines) != 2 || lines[0] != "line 2" || lines[1] != "line 6" {
+		t.Errorf("wrong errors: %v", lines)
+	}
+
+	out := make(chan *Event, len(read))
+	for _, evt := range read[:3] {
+		out <- evt
+	}
+	close(out)
+	var output bytes.Buffer
+	if err := WriteEvents(&output, out); err != nil {
+		t.Fatalf("failed to write events: %v", err)
+	}
+	written := strings.Split(strings.TrimSuffix(output.String(), "\n"), "\n")
+	if len(written) != 3 {
+		t.Fatalf("expected 3 lines, got %q", written)
+	}
+	for i, line := range written {
+		var evt Event
+		if err := json.Unmarshal([]byte(line), &evt); err != nil || evt.ID != read[i].ID {
+			t.Errorf("line %d doesn't have the event: %s %v", i, line, err)
+		}
+	}
+}
+
+func TestProfile(t *testing.T) {
+	profile := &Profile{Name: "bob", About: "just bob", Picture: "https://example.com/bob.png", Lud16: "bob@example.com"}
+	parsed, err := ParseMetadata(profile.ToEvent())
+	if err != nil || !reflect.DeepEqual(parsed, profile) {
+		t.Errorf("profile didn't roundtrip: %v %v", parsed, err)
+	}
+
+	// custom fields, and the ones with other types, are kept as they were
+	metadata := NewEvent(KindSetMetadata, `{"name":"alice","pronouns":"she/her","bot":true,"nested":{"a":[1,2]}}`)
+	parsed, err = ParseMetadata(metadata)
+	if err != nil || parsed.Name != "alice" || len(parsed.Extra) != 3 || string(parsed.Extra["pronouns"]) != `"she/her"` {
+		t.Fatalf("wrong profile: %v %v", parsed, err)
+	}
+	parsed.About = "hi"
+	roundtrip, err := ParseMetadata(parsed.ToEvent())
+	if err != nil || roundtrip.Name != "alice" || roundtrip.About != "hi" || !reflect.DeepEqual(roundtrip.Extra, parsed.Extra) {
+		t.Errorf("unknown fields didn't survive: %v %v", roundtrip, err)
+	}
+
+	if _, err := ParseMetadata(NewEvent(KindSetMetadata, `{"name":1}`)); err == nil {
+		t.Error("invalid metadata was parsed")
+	}
+	if _, err := ParseMetadata(NewEvent(KindTextNote, `{"name":"bob"}`)); err == nil {
+		t.Error("a text note was parsed as metadata")
+	}
+}
+
+func TestKindRanges(t *testing.T) {
+	for _, c := range []struct {
+		kind                                           int
+		regular, replaceable, ephemeral, parameterized bool
+	}{
+		{0, false, true, false, false},
+		{1, true, false, false, false},
+		{3, false, true, false, false},
+		{9999, true, false, false, false},
+		{10000, false, true, false, false},
+		{19999, false, true, false, false},
+		{20000, false, false, true, false},
+		{29999, false, false, true, false},
+		{30000, false, false, false, true},
+		{39999, false, false, false, true},
+		{40000, true, false, false, false},
+	} {
+		if IsRegular(c.kind) != c.regular || IsReplaceable(c.kind) != c.replaceable ||
+			IsEphemeral(c.kind) != c.ephemeral || IsParameterizedReplaceable(c.kind) != c.parameterized {
+			t.Errorf("wrong range for kind %d", c.kind)
+		}
+	}
+}
+
+func TestKindName(t *testing.T) {
+	for kind, name := range map[int]string{
+		KindSetMetadata:          "Set Metadata",
+		KindTextNote:             "Text Note",
+		KindReaction:             "Reaction",
+		10002:                    "Relay List Metadata",
+		KindClientAuthentication: "Client Authentication",
+		KindArticle:              "Long-form Content",
+		10050:                    "Replaceable (10050)",
+		20001:                    "Ephemeral (20001)",
+		30099:                    "Parameterized Replaceable (30099)",
+		9999:                     "Unknown (9999)",
+		40000:                    "Unknown (40000)",
+	} {
+		if got := KindName(kind); got != name {
+			t.Errorf("kind %d is '%s', not '%s'", kind, got, name)
+		}
+	}
+}
+
+func TestReplaceableKey(t *testing.T) {
+	pk := "3bf0c63fcb93463407af97a5e5ee64fa883d107ef9e558472c4eb9aaaefa459d"
+	for _, c := range []struct {
+		evt *Event
+		key string
+	}{
+		{NewEvent(KindTextNote, ""), ""},
+		{NewEvent(KindSetMetadata, ""), "0:" + pk},
+		{NewEvent(10002, "").WithTag("d", "ignored"), "10002:" + pk},
+		{NewEvent(30023, "").WithTag("d", "article"), "30023:" + pk + ":article"},
+		{NewEvent(30023, "").WithTag("d"), "30023:" + pk + ":"},
+		{NewEvent(30023, ""), "30023:" + pk + ":"},
+	} {
+		c.evt.PubKey = pk
+		if key := c.evt.ReplaceableKey(); key != c.key {
+			t.Errorf("key of kind %d with tags %v is '%s', not '%s'", c.evt.Kind, c.evt.Tags, key, c.key)
+		}
+	}
+}
+
+func TestAddress(t *testing.T) {
+	pk := "3bf0c63fcb93463407af97a5e5ee64fa883d107ef9e558472c4eb9aaaefa459d"
+	article := NewEvent(KindArticle, "").WithTag("d", "my:article")
+	article.PubKey = pk
+	addr, err := article.Address()
+	if err != nil || addr != "30023:"+pk+":my:article" {
+		t.Fatalf("wrong address: %s %v", addr, err)
+	}
+	kind, pubkey, dtag, err := ParseAddress(addr)
+	if err != nil || kind != KindArticle || pubkey != pk || dtag != "my:article" {
+		t.Errorf("address didn't roundtrip: %d %s %s %v", kind, pubkey, dtag, err)
+	}
+
+	// a "d" tag without a value is an empty identifier
+	empty := NewEvent(KindArticle, "").WithTag("d")
+	empty.PubKey = pk
+	if addr, err := empty.Address(); err != nil || addr != "30023:"+pk+":" {
+		t.Errorf("wrong address for an empty d tag: %s %v", addr, err)
+	}
+	if _, err := NewEvent(KindArticle, "").Address(); err == nil {
+		t.Error("event without a d tag has an address")
+	}
+	if _, err := NewEvent(KindTextNote, "").WithTag("d", "x").Address(); err == nil {
+		t.Error("text note has an address")
+	}
+
+	for name, coord := range map[string]string{
+		"bad kind":           "article:" + pk + ":x",
+		"negative kind":      "-1:" + pk + ":x",
+		"short pubkey":       "30023:" + pk[:63] + ":x",
+		"not hex pubkey":     "30023:" + strings.Repeat("z", 64) + ":x",
+		"missing identifier": "30023:" + pk,
+	} {
+		if _, _, _, err := ParseAddress(coord); err == nil {
+			t.Errorf("%s: %s was accepted", name, coord)
+		}
+	}
+}
+
+func TestVerifyAuthorNIP05(t *testing.T) {
+	sk := GeneratePrivateKey()
+	pk, _ := GetPublicKey(sk)
+
+	var requests int32
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		if r.URL.Path != "/.well-known/nostr.json" || r.URL.Query().Get("name") != "bob" {
+			http.NotFound(w, r)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"names": map[string]string{"bob": pk}})
+	}))
+	defer server.Close()
+	identifier := "bob@" + server.Listener.Addr().String()
+
+	ctx := context.Background()
+	profile := &Profile{Name: "bob", NIP05: identifier}
+	metadata := profile.ToEvent()
+	metadata.SignWith(sk)
+	if ok, err := metadata.VerifyAuthorNIP05(ctx, server.Client(), ""); !ok || err != nil {
+		t.Errorf("profile identifier wasn't verified: %v", err)
+	}
+
+	note, _ := NewEvent(KindTextNote, "hello").SignWith(sk)
+	if ok, err := note.VerifyAuthorNIP05(ctx, server.Client(), identifier); !ok || err != nil {
+		t.Errorf("note author wasn't verified: %v", err)
+	}
+	if n := atomic.LoadInt32(&requests); n != 2 {
+		t.Errorf("expected the lookups not to be cached, got %d requests", n)
+	}
+
+	cache := &nip05.Cache{TTL: time.Minute, Client: server.Client()}
+	for i := 0; i < 3; i++ {
+		if ok, err := note.VerifyAuthorNIP05Cached(ctx, cache, identifier); !ok || err != nil {
+			t.Errorf("note author wasn't verified with the cache: %v", err)
+		}
+	}
+	if n := atomic.LoadInt32(&requests); n != 3 {
+		t.Errorf("expected the lookup to be cached, got %d requests", n)
+	}
+
+	other, _ := NewEvent(KindTextNote, "hello").SignWith(GeneratePrivateKey())
+	if ok, _ := other.VerifyAuthorNIP05(ctx, server.Client(), identifier); ok {
+		t.Error("identifier verified for another pubkey")
+	}
+	if _, err := note.VerifyAuthorNIP05(ctx, server.Client(), ""); err == nil {
+		t.Error("verified without an identifier")
+	}
+}
+
+func TestTagsNormalize(t *testing.T) {
+	evt := NewEvent(KindArticle, "").
+		WithTag("e", "first").
+		WithTag("title", "Hello").
+		WithTag("p", "someone").
+		WithTag("d", "hello").
+		WithTag("e", "second").
+		WithTag("published_at", "1700000000")
+
+	if !evt.NormalizeTags() {
+		t.Fatal("tags of an unsigned event weren't normalized")
+	}
+	expected := []string{"d", "title", "published_at", "e", "p", "e"}
+	for i, name := range expected {
+		if evt.Tags[i][0] != name {
+			t.Fatalf("wrong order: %v", evt.Tags)
+		}
+	}
+	if evt.Tags[3][1] != "first" || evt.Tags[5][1] != "second" {
+		t.Errorf("other tags didn't keep their order: %v", evt.Tags)
+	}
+
+	evt.Tags[0], evt.Tags[1] = evt.Tags[1], evt.Tags[0]
+	evt.SignWith(GeneratePrivateKey())
+	if evt.NormalizeTags() || evt.Tags[0][0] != "title" {
+		t.Error("tags of a signed event were reordered")
+	}
+}
+
+func TestTagsEach(t *testing.T) {
+	tags := Tags{{"p", "a"}, {"e", "x"}, {"p", "b"}, {}, {"p", "c"}}
+
+	var seen []string
+	tags.Each("p", func(tag Tag) bool {
+		seen = append(seen, tag[1])
+		return tag[1] != "b"
+	})
+	if !StringList(seen).Equals(StringList{"a", "b"}) {
+		t.Errorf("wrong tags iterated: %v", seen)
+	}
+
+	count := 0
+	if allocs := testing.AllocsPerRun(100, func() {
+		tags.Each("p", func(Tag) bool { count++; return true })
+	}); allocs != 0 {
+		t.Errorf("Each allocated %v times", allocs)
+	}
+}
+
+func TestTagsJSON(t *testing.T) {
+	raw := `[["e","abc","wss://relay.example.com"],[],["p","def"]]`
+
+	var tags Tags
+	if err := json.Unmarshal([]byte(raw), &tags); err != nil {
+		t.Fatalf("failed to parse tags: %v", err)
+	}
+	if len(tags) != 3 || tags[1] == nil || len(tags[1]) != 0 || tags[2][1] != "def" {
+		t.Errorf("failed to parse tags correctly: %v", tags)
+	}
+
+	tagsj, _ := json.Marshal(tags)
+	if string(tagsj) != raw {
+		t.Errorf("tags json was wrong: %s != %s", tagsj, raw)
+	}
+	if tagsj, _ := json.Marshal(Tags(nil)); string(tagsj) != "[]" {
+		t.Errorf("nil tags json was wrong: %s", tagsj)
+	}
+
+	for _, malformed := range []string{`[["e",1]]`, `["e"]`, `{}`, `[["e",null]]`} {
+		if err := json.Unmarshal([]byte(malformed), &tags); err == nil {
+			t.Errorf("parsed malformed tags %s", malformed)
+		}
+	}
+}
+
+func TestEventBinary(t *testing.T) {
+	sk := GeneratePrivateKey()
+	evt, _ := NewEvent(KindArticle, "# hello\n\nwith \"quotes\"").
+		WithTag("d", "hello").WithTag("t").WithTag("e", "", "wss://relay.example.com").SignWith(sk)
+
+	b, err := evt.MarshalBinary()
+	if err != nil {
+		t.Fatalf("failed to marshal binary event: %v", err)
+	}
+	jsonb, _ := json.Marshal(evt)
+	if len(b) >= len(jsonb) {
+		t.Errorf("binary event has %d bytes, json has %d", len(b), len(jsonb))
+	}
+
+	var decoded Event
+	if err := decoded.UnmarshalBinary(b); err != nil {
+		t.Fatalf("failed to unmarshal binary event: %v", err)
+	}
+	if !decoded.Equals(evt) || decoded.CreatedAt != evt.CreatedAt || len(decoded.Tags[1]) != 1 {
+		t.Errorf("binary round-trip changed the event: %v", decoded)
+	}
+	if err := decoded.Validate(); err != nil {
+		t.Errorf("decoded event is invalid: %v", err)
+	}
+
+	for i := 0; i < len(b); i++ {
+		if err := new(Event).UnmarshalBinary(b[:i]); err == nil {
+			t.Fatalf("truncated binary event with %d bytes was decoded", i)
+		}
+	}
+
+	if _, err := NewEvent(KindTextNote, "unsigned").MarshalBinary(); err == nil {
+		t.Error("unsigned event was marshaled")
+	}
+}
+
+func TestEventSigning(t *testing.T) {
+	sk := GeneratePrivateKey()
+	if len(sk) != 64 {
+		t.Fatalf("generated private key has wrong length: %d", len(sk))
+	}
+
+	pk, err := GetPublicKey(sk)
+	if err != nil {
+		t.Fatalf("failed to get public key: %v", err)
+	}
+
+	ev := Event{PubKey: pk, CreatedAt: Timestamp(1644271588), Kind: KindTextNote, Content: "hello"}
+	if err := ev.Sign(sk); err != nil {
+		t.Fatalf("failed to sign event: %v", err)
+	}
+
+	if ok, err := ev.CheckSignature(); !ok {
+		t.Errorf("signature verification failed when it should have succeeded: %v", err)
+	}
+
+	tampered := ev
+	tampered.Content = "bye"
+	if !ev.VerifyID() || tampered.VerifyID() {
+		t.Error("VerifyID should only accept the untampered event")
+	}
+	if err := tampered.Validate(); !errors.Is(err, ErrIDMismatch) {
+		t.Errorf("expected id mismatch, got %v", err)
+	}
+	tampered.ID = tampered.GetID()
+	if err := tampered.Validate(); !errors.Is(err, ErrInvalidSignature) {
+		t.Errorf("expected invalid signature, got %v", err)
+	}
+
+	aux := bytes.Repeat([]byte{7}, 32)
+	if err := ev.SignWithReader(sk, bytes.NewReader(aux)); err != nil {
+		t.Fatalf("failed to sign event with reader: %v", err)
+	}
+	sig := ev.Sig
+	if err := ev.SignWithReader(sk, bytes.NewReader(aux)); err != nil || ev.Sig != sig {
+		t.Error("signing with the same reader should give the same signature")
+	}
+	if err := ev.SignWithReader(sk, bytes.NewReader(aux[:16])); err == nil {
+		t.Error("signing with a short reader should fail")
+	}
+
+	for _, invalid := range []string{"", "abc", sk[1:], sk + "00", "zz" + sk[2:]} {
+		if _, err := GetPublicKey(invalid); err == nil {
+			t.Errorf("invalid private key '%s' was accepted", invalid)
+		}
+	}
+}
+
+func TestSignWithBech32(t *testing.T) {
+	sk := GeneratePrivateKey()
+	pk, _ := GetPublicKey(sk)
+	nsec, _ := nip19.EncodePrivateKey(sk)
+	npub, _ := nip19.EncodePublicKey(pk)
+
+	evt := NewEvent(KindTextNote, "hello")
+	if err := evt.SignWithBech32(nsec); err != nil {
+		t.Fatalf("failed to sign with nsec: %v", err)
+	}
+	if evt.PubKey != pk || evt.Validate() != nil {
+		t.Error("event signed with nsec is invalid")
+	}
+
+	if err := NewEvent(KindTextNote, "hello").SignWithBech32(npub); err == nil || !strings.Contains(err.Error(), "npub") {
+		t.Errorf("signing with an npub should fail clearly, got %v", err)
+	}
+	if err := NewEvent(KindTextNote, "hello").SignWithBech32(sk); err == nil {
+		t.Error("signing with a hex key should fail")
+	}
+}
+
+func TestEventNote(t *testing.T) {
+	evt := &Event{ID: "dc90c95f09947507c1044e8f48bcf6350aa6bff1507dd4acfc755b9239b5c962"}
+	note, err := evt.ToNote()
+	if err != nil || !strings.HasPrefix(note, "note1") {
+		t.Fatalf("failed to encode note: %s %v", note, err)
+	}
+	if id, err := DecodeNote(note); err != nil || id != evt.ID {
+		t.Errorf("failed to decode note: %s %v", id, err)
+	}
+
+	if _, err := (&Event{ID: evt.ID[2:]}).ToNote(); err == nil {
+		t.Error("encoded an id with the wrong length")
+	}
+	npub, _ := nip19.EncodePublicKey(evt.ID)
+	if _, err := DecodeNote(npub); err == nil {
+		t.Error("decoded an npub as a note")
+	}
+}
+
+func TestValidateWithClock(t *testing.T) {
+	sk := GeneratePrivateKey()
+	now := time.Unix(1644271588, 0)
+
+	ev, _ := NewEvent(KindTextNote, "hello").SignWith(sk)
+	ev.CreatedAt = Timestamp(now.Add(DefaultFutureTolerance).Unix())
+	ev.Sign(sk)
+	if err := ev.ValidateWithClock(now, DefaultFutureTolerance); err != nil {
+		t.Errorf("event within the tolerance failed validation: %v", err)
+	}
+	if err := ev.ValidateWithClock(now, time.Minute); !errors.Is(err, ErrFutureEvent) {
+		t.Errorf("expected ErrFutureEvent, got %v", err)
+	}
+
+	ev.CreatedAt = Timestamp(now.Add(-time.Hour).Unix())
+	if err := ev.ValidateWithClock(now, 0); !errors.Is(err, ErrIDMismatch) {
+		t.Errorf("expected ErrIDMismatch for a past event with a stale id, got %v", err)
+	}
+}
+
+func TestCheckLimits(t *testing.T) {
+	evt := NewEvent(KindTextNote, "olá mundo").WithTag("t", "nostr").WithTag("t", "bitcoin")
+
+	if err := evt.CheckLimits(Limits{}); err != nil {
+		t.Errorf("zero limits shouldn't limit anything: %v", err)
+	}
+	if err := evt.CheckLimits(Limits{MaxContentLength: 9, MaxTags: 2, MaxTagValueLength: 7}); err != nil {
+		t.Errorf("event within the limits was rejected: %v", err)
+	}
+
+	for _, l := range []Limits{
+		{MaxContentLength: 8},
+		{MaxTags: 1},
+		{MaxTagValueLength: 6},
+	} {
+		var lerr *LimitError
+		if err := evt.CheckLimits(l); !errors.As(err, &lerr) {
+			t.Errorf("expected a LimitError for %+v, got %v", l, err)
+		}
+	}
+
+	var lerr *LimitError
+	err := evt.CheckLimits(RelayLimits(nip11.RelayLimitation{MaxEventTags: 1, MaxContentLength: 100}))
+	if !errors.As(err, &lerr) || lerr.Limit != "MaxTags" || lerr.Got != 2 {
+		t.Errorf("expected the relay tags limit to be exceeded, got %v", err)
+	}
+}
+
+func TestRelayLimits(t *testing.T) {
+	limits := RelayLimits(nip11.RelayLimitation{MaxContentLength: 5, MaxEventTags: 2, MaxMessageLength: 10})
+	if limits != (Limits{MaxContentLength: 5, MaxTags: 2}) {
+		t.Errorf("wrong limits from relay limitation: %+v", limits)
+	}
+
+	for _, c := range []struct {
+		evt      *Event
+		limit    string
+		max, got int
+	}{
+		{NewEvent(KindTextNote, "ações"), "", 0, 0},
+		{NewEvent(KindTextNote, "ações!"), "MaxContentLength", 5, 6},
+		{NewEvent(KindTextNote, "").WithTag("t", "a").WithTag("t", "b"), "", 0, 0},
+		{NewEvent(KindTextNote, "").WithTag("t", "a").WithTag("t", "b").WithTag("t", "c"), "MaxTags", 2, 3},
+		{NewEvent(KindTextNote, "too long").WithTag("t", "a").WithTag("t", "b").WithTag("t", "c"), "MaxContentLength", 5, 8},
+		{NewEvent(KindTextNote, "").WithTag("t", strings.Repeat("x", 1000)), "", 0, 0},
+	} {
+		err := c.evt.CheckLimits(limits)
+		if c.limit == "" {
+			if err != nil {
+				t.Errorf("event within the relay limits was rejected: %v", err)
+			}
+			continue
+		}
+
+		var lerr *LimitError
+		if !errors.As(err, &lerr) || lerr.Limit != c.limit || lerr.Max != c.max || lerr.Got != c.got {
+			t.Errorf("expected %s to be exceeded with %d, got %v", c.limit, c.got, err)
+		}
+	}
+}
+
+func TestVerifyBatch(t *testing.T) {
+	sk := GeneratePrivateKey()
+
+	events := make([]*Event, 20)
+	for i := range events {
+		events[i], _ = NewEvent(KindTextNote, "hello").WithTag("t", "batch").SignWith(sk)
+		if i%3 == 0 {
+			events[i].Content = "tampered"
+		}
+	}
+
+	for i, err := range VerifyBatch(events, 4) {
+		ok, _ := events[i].CheckSignature()
+		if ok != (err == nil) {
+			t.Errorf("event %d: batch result %v doesn't match serial result %v", i, err, ok)
+		}
+	}
+}
+
+func TestValidateAuth(t *testing.T) {
+	sk := GeneratePrivateKey()
+	evt, _ := MakeAuthEvent("wss://relay.example.com/", "challenge-1").SignWith(sk)
+
+	if err := evt.ValidateAuth("challenge-1", "WSS://relay.example.com", time.Minute); err != nil {
+		t.Errorf("valid auth event was rejected: %v", err)
+	}
+	if err := evt.ValidateAuth("challenge-2", "wss://relay.example.com", time.Minute); err == nil {
+		t.Error("auth event with the wrong challenge was accepted")
+	}
+	if err := evt.ValidateAuth("challenge-1", "wss://other.example.com", time.Minute); err == nil {
+		t.Error("auth event for another relay was accepted")
+	}
+
+	old, _ := MakeAuthEvent("wss://relay.example.com", "challenge-1").SignWith(sk)
+	old.CreatedAt -= 3600
+	old.Sign(sk)
+	if err := old.ValidateAuth("challenge-1", "wss://relay.example.com", time.Minute); err == nil {
+		t.Error("stale auth event was accepted")
+	}
+}
+
+func TestValidateAuthResponse(t *testing.T) {
+	challenge := GenerateAuthChallenge()
+	if len(challenge) != 32 || challenge == GenerateAuthChallenge() {
+		t.Fatalf("bad challenge: %s", challenge)
+	}
+
+	sk := GeneratePrivateKey()
+	pk, _ := GetPublicKey(sk)
+	evt, _ := MakeAuthEvent("wss://relay.example.com", challenge).SignWith(sk)
+	if pubkey, err := ValidateAuthResponse(evt, challenge, "wss://relay.example.com", time.Minute); err != nil || pubkey != pk {
+		t.Errorf("valid auth response was rejected: %s %v", pubkey, err)
+	}
+
+	// a response to another connection's challenge can't be replayed
+	if _, err := ValidateAuthResponse(evt, GenerateAuthChallenge(), "wss://relay.example.com", time.Minute); err == nil {
+		t.Error("auth response for another challenge was accepted")
+	}
+
+	empty, _ := MakeAuthEvent("wss://relay.example.com", "").SignWith(sk)
+	if _, err := ValidateAuthResponse(empty, "", "wss://relay.example.com", time.Minute); err == nil {
+		t.Error("auth response was accepted without a challenge")
+	}
+
+	evt.Tags[0][1] = "wss://other.example.com"
+	if _, err := ValidateAuthResponse(evt, challenge, "wss://other.example.com", time.Minute); err == nil {
+		t.Error("tampered auth response was accepted")
+	}
+}
+
+func TestEventTagValue(t *testing.T) {
+	evt := NewEvent(KindArticle, "").WithTag("d", "post", "extra").WithTag("t", "go")
+
+	if _, ok := evt.GetTagValue("title"); ok {
+		t.Error("got a value for a missing tag")
+	}
+	evt.SetTagValue("title", "Hello")
+	if value, ok := evt.GetTagValue("title"); !ok || value != "Hello" || len(evt.Tags) != 3 {
+		t.Errorf("tag wasn't appended: %v", evt.Tags)
+	}
+
+	shared := *evt
+	evt.SetTagValue("d", "other")
+	if value, _ := evt.GetTagValue("d"); value != "other" || len(evt.Tags) != 3 || len(evt.Tags[0]) != 2 {
+		t.Errorf("tag wasn't replaced: %v", evt.Tags)
+	}
+	if value, _ := shared.GetTagValue("d"); value != "post" {
+		t.Errorf("setting a tag changed a copy of the event: %v", shared.Tags)
+	}
+
+	evt.Tags = append(evt.Tags, Tag{"d", "duplicate"})
+	evt.SetTagValue("d", "only", "extra")
+	if d := evt.Tags.GetAll("d"); len(d) != 1 || len(d[0]) != 3 || evt.Tags[0][1] != "only" {
+		t.Errorf("duplicates weren't dropped: %v", evt.Tags)
+	}
+}
+
+func TestEventCanonicalize(t *testing.T) {
+	sk := GeneratePrivateKey()
+	evt := NewEvent(KindTextNote, "cafe\u0301").
+		WithTag("e", "abc", "", "").
+		WithTag("p", "", "wss://relay.example.com").
+		WithTag("t", "nai\u0308ve").
+		WithTag("").
+		WithTag("client")
+	evt.SignWith(sk)
+	shared := evt.Clone()
+	reused := &Event{Tags: Tags{evt.Tags[2]}}
+	copied := *evt
+
+	if !evt.Canonicalize() {
+		t.Fatal("event should have changed")
+	}
+	if evt.Content != "caf\u00e9" {
+		t.Errorf("content wasn't normalized: %q", evt.Content)
+	}
+	expected := Tags{{"e", "abc"}, {"p", "", "wss://relay.example.com"}, {"t", "na\u00efve"}, {""}, {"client"}}
+	if len(evt.Tags) != len(expected) {
+		t.Fatalf("wrong tags: %q", evt.Tags)
+	}
+	for i, tag := range expected {
+		if !StringList(evt.Tags[i]).Equals(StringList(tag)) {
+			t.Errorf("tag %d should be %q, not %q", i, tag, evt.Tags[i])
+		}
+	}
+	if reused.Tags[0][1] != "nai\u0308ve" {
+		t.Error("canonicalizing edited a tag array shared with another event")
+	}
+	if len(copied.Tags[0]) != 4 || copied.Tags[2][1] != "nai\u0308ve" {
+		t.Error("canonicalizing edited the tags of a copy of the event")
+	}
+	if err := evt.Validate(); !errors.Is(err, ErrIDMismatch) {
+		t.Errorf("a changed signed event should no longer validate: %v", err)
+	}
+
+	if evt.Canonicalize() {
+		t.Error("canonicalizing twice shouldn't change anything")
+	}
+	shared.Canonicalize()
+	if shared.GetID() != evt.GetID() {
+		t.Error("canonical forms of the same event have different ids")
+	}
+}
+
+func TestMineThenSign(t *testing.T) {
+	sk := GeneratePrivateKey()
+	evt := NewEvent(KindTextNote, "mining \"quoted\" content").
+		WithTag("e", "abc").
+		WithTag("nonce", "old", "1").
+		WithTag("t", "pow")
+	copied := *evt
+	if err := evt.MineThenSign(context.Background(), 12, sk); err != nil {
+		t.Fatalf("failed to mine: %v", err)
+	}
+	if copied.Tags[1][1] != "old" {
+		t.Error("mining edited the tags of a copy of the event")
+	}
+	if err := evt.Validate(); err != nil {
+		t.Errorf("mined event doesn't validate: %v", err)
+	}
+	if Difficulty(evt.ID) < 12 {
+		t.Errorf("id %s doesn't meet the difficulty", evt.ID)
+	}
+	if tag := evt.Tags[1]; tag[0] != "nonce" || tag[1] == "old" || tag[2] != "12" || len(evt.Tags) != 3 {
+		t.Errorf("nonce tag wasn't replaced in place: %v", evt.Tags)
+	}
+
+	fresh := NewEvent(KindTextNote, "")
+	if err := fresh.MineThenSign(context.Background(), 8, sk); err != nil || fresh.Validate() != nil {
+		t.Errorf("failed to mine an event without tags: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	cancelled := NewEvent(KindTextNote, "").WithTag("t", "pow")
+	if err := cancelled.MineThenSign(ctx, 256, sk); err != context.Canceled {
+		t.Errorf("expected mining to be cancelled, got %v", err)
+	}
+	if len(cancelled.Tags) != 1 || cancelled.ID != "" {
+		t.Errorf("cancelled mining changed the event: %v %s", cancelled.Tags, cancelled.ID)
+	}
+
+	for _, difficulty := range []int{-1, 257} {
+		if err := NewEvent(KindTextNote, "").Mine(context.Background(), difficulty); err == nil {
+			t.Errorf("mining with difficulty %d didn't fail", difficulty)
+		}
+	}
+}
+
+func TestDifficulty(t *testing.T) {
+	for id, expected := range map[string]int{
+		"":         0,
+		"not hex":  0,
+		"ff":       0,
+		"0f":       4,
+		"0001":     15,
+		"00000000": 32,
+	} {
+		if d := Difficulty(id); d != expected {
+			t.Errorf("difficulty of '%s' is %d, expected %d", id, d, expected)
+		}
+	}
+}
+
+func TestCheckProofOfWork(t *testing.T) {
+	evt := NewEvent(KindTextNote, "work")
+	if err := evt.CheckProofOfWork(0); !errors.Is(err, ErrNoPoWCommitment) {
+		t.Errorf("expected ErrNoPoWCommitment, got %v", err)
+	}
+	if err := evt.GenerateProofOfWork(context.Background(), 10); err != nil {
+		t.Fatalf("failed to mine: %v", err)
+	}
+	if err := evt.CheckProofOfWork(10); err != nil {
+		t.Errorf("mined event failed the check: %v", err)
+	}
+	if err := evt.CheckProofOfWork(11); !errors.Is(err, ErrPoWBelowRequested) {
+		t.Errorf("expected ErrPoWBelowRequested, got %v", err)
+	}
+
+	// claiming more work than was done
+	evt.Tags[0][2] = "40"
+	if err := evt.CheckProofOfWork(20); !errors.Is(err, ErrPoWCommitmentUnmet) {
+		t.Errorf("expected ErrPoWCommitmentUnmet, got %v", err)
+	}
+	evt.Tags[0][2] = "lots"
+	if err := evt.CheckProofOfWork(0); !errors.Is(err, ErrNoPoWCommitment) {
+		t.Errorf("expected ErrNoPoWCommitment for a bad target, got %v", err)
+	}
+}
+
+func TestEventString(t *testing.T) {
+	var evt Event
+	json.Unmarshal([]byte(`{"id":"dc90c95f09947507c1044e8f48bcf6350aa6bff1507dd4acfc755b9239b5c962","pubkey":"3bf0c63fcb93463407af97a5e5ee64fa883d107ef9e558472c4eb9aaaefa459d","created_at":1644271588,"kind":1,"tags":[["t","x"]],"content":"now that https://blueskyweb.org/blog/2-7-2022-overview was announced\nwe can stop?","sig":"230e9d8f0ddaf7eb70b5f7741ccfa37e87a455c9a469282e3464e2052d3192cd63a167e196e381ef9d7e69e9ea43af2443b839974dc85d8aaab9efe1d9296524"}`), &evt)
+
+	expected := `kind=1 id=dc90c95f… by 3bf0c63f… at 2022-02-07T22:06:28Z tags=1 "now that https://blueskyweb.org/blog/2-7…"`
+	if s := evt.String(); s != expected {
+		t.Errorf("wrong summary: %s", s)
+	}
+	if s := (&Event{Content: "\nshört"}).String(); !strings.HasSuffix(s, `tags=0 "\nshört"`) {
+		t.Errorf("wrong summary of a short event: %s", s)
+	}
+
+	dump := evt.Dump()
+	var decoded Event
+	if err := json.Unmarshal([]byte(dump), &decoded); err != nil || !decoded.Equals(&evt) || !strings.Contains(dump, "\n  \"kind\": 1,") {
+		t.Errorf("bad dump: %s %v", dump, err)
+	}
+}
+
+func TestEventSerializationCache(t *testing.T) {
+	sk := GeneratePrivateKey()
+	evt, _ := NewEvent(KindTextNote, "hello").WithTag("t", "x").SignWith(sk)
+	id := evt.ID
+
+	// events stay plain values that can be copied and compared
+	copied := *evt
+	var decoded Event
+	j, _ := json.Marshal(evt)
+	json.Unmarshal(j, &decoded)
+	if !reflect.DeepEqual(&copied, evt) || !reflect.DeepEqual(&decoded, evt) {
+		t.Error("copied or decoded event isn't equal to the original")
+	}
+	if ok, err := (*evt).CheckSignature(); !ok || err != nil {
+		t.Errorf("signature check on a value failed: %v", err)
+	}
+
+	// a changed copy doesn't get the hash of the original, and vice versa
+	copied.Content = "bye"
+	if copied.GetID() == id || evt.GetID() != id {
+		t.Error("cached serialization reused after a change")
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			c := *evt
+			for j := 0; j < 100; j++ {
+				c.Content = strconv.Itoa(i * j)
+				c.GetID()
+				if evt.GetID() != id {
+					t.Error("wrong id while other events are hashed")
+					return
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+}
+
+func TestEventClone(t *testing.T) {
+	sk := GeneratePrivateKey()
+	evt, _ := NewEvent(KindTextNote, "hello").WithTag("e", "abc", "wss://relay.example.com").WithTag("t", "x").SignWith(sk)
+
+	clone := evt.Clone()
+	if clone.GetID() != evt.ID || clone.Sig != evt.Sig {
+		t.Fatal("clone is not the same event")
+	}
+
+	clone.Tags[0][2] = "wss://other.example.com"
+	clone.Tags = clone.Tags[:1]
+	clone.Tags = append(clone.Tags, Tag{"p", "def"})
+	if evt.Tags[0][2] != "wss://relay.example.com" || len(evt.Tags) != 2 || evt.Tags[1][0] != "t" {
+		t.Errorf("mutating the clone changed the original: %v", evt.Tags)
+	}
+	if err := evt.Validate(); err != nil {
+		t.Errorf("original event is no longer valid: %v", err)
+	}
+}
+
+func TestEventEquals(t *testing.T) {
+	sk := GeneratePrivateKey()
+	evt, _ := NewEvent(KindTextNote, "hello").WithTag("t", "a").WithTag("t", "b").SignWith(sk)
+
+	if clone := evt.Clone(); !evt.Equals(clone) || !evt.SameID(clone) {
+		t.Error("event should be equal to its clone")
+	}
+
+	spoofed := evt.Clone()
+	spoofed.Content = "goodbye"
+	if evt.Equals(spoofed) || !evt.SameID(spoofed) {
+		t.Error("events with the same id but different content shouldn't be equal")
+	}
+
+	reordered := evt.Clone()
+	reordered.Tags[0], reordered.Tags[1] = reordered.Tags[1], reordered.Tags[0]
+	if evt.Equals(reordered) {
+		t.Error("events with reordered tags shouldn't be equal")
+	}
+}
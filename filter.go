@@ -13,10 +13,27 @@ type Filter struct {
 	Since   *time.Time
 	Until   *time.Time
 	Tags    TagMap
+	Limit   int
+
+	// LimitZero makes a Limit of 0 mean that no stored events are wanted, only
+	// the new ones, instead of no limit at all. Parsing "limit":0 sets it.
+	LimitZero bool
+
+	// Search is a NIP-50 full-text query, it can only be evaluated by relays
+	// that support it.
+	Search string
 }
 
+// TagMap holds the "#<letter>" tag conditions of a filter, keyed by the tag
+// name without the "#".
 type TagMap map[string]StringList
 
+// WithKinds returns a copy of the filter that also matches the given kinds.
+func (ef Filter) WithKinds(kinds ...int) Filter {
+	ef.Kinds = append(append(IntList(nil), ef.Kinds...), kinds...)
+	return ef
+}
+
 func (eff Filters) Match(event *Event) bool {
 	for _, filter := range eff {
 		if filter.Matches(event) {
@@ -26,6 +43,10 @@ func (eff Filters) Match(event *Event) bool {
 	return false
 }
 
+// Matches checks if the event satisfies all the filter conditions, including
+// the "#<letter>" tag conditions, multi-letter tag names are ignored. Limit is
+// only meaningful for queries and Search is left for the relay to decide, so
+// neither is taken into account here.
 func (ef Filter) Matches(event *Event) bool {
 	if event == nil {
 		return false
@@ -44,16 +65,20 @@ func (ef Filter) Matches(event *Event) bool {
 	}
 
 	for f, v := range ef.Tags {
-		if v != nil && !event.Tags.ContainsAny(f, v) {
+		// relays only index single-letter tags
+		if len(f) != 1 {
+			continue
+		}
+		if v != nil && !event.Tags.ContainsAny(f, v...) {
 			return false
 		}
 	}
 
-	if ef.Since != nil && time.Time(event.CreatedAt).Before(*ef.Since) {
+	if ef.Since != nil && event.CreatedAt.Time().Before(*ef.Since) {
 		return false
 	}
 
-	if ef.Until != nil && time.Time(event.CreatedAt).After(*ef.Until) {
+	if ef.Until != nil && event.CreatedAt.Time().After(*ef.Until) {
 		return false
 	}
 
@@ -95,5 +120,13 @@ func FilterEqual(a Filter, b Filter) bool {
 		return false
 	}
 
+	if a.Limit != b.Limit || a.LimitZero != b.LimitZero {
+		return false
+	}
+
+	if a.Search != b.Search {
+		return false
+	}
+
 	return true
 }
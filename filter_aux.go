@@ -2,6 +2,8 @@ package nostr
 
 import (
 	"fmt"
+	"sort"
+	"strconv"
 	"strings"
 	"time"
 
@@ -55,6 +57,18 @@ func (f *Filter) UnmarshalJSON(payload []byte) error {
 			}
 			tm := time.Unix(val, 0)
 			f.Until = &tm
+		case "limit":
+			f.Limit, err = v.Int()
+			if err != nil {
+				visiterr = fmt.Errorf("invalid 'limit' field: %w", err)
+			}
+			f.LimitZero = f.Limit == 0
+		case "search":
+			sb, err := v.StringBytes()
+			if err != nil {
+				visiterr = fmt.Errorf("invalid 'search' field: %w", err)
+			}
+			f.Search = string(sb)
 		default:
 			if strings.HasPrefix(key, "#") {
 				f.Tags[key[1:]], err = fastjsonArrayToStringList(v)
@@ -71,29 +85,47 @@ func (f *Filter) UnmarshalJSON(payload []byte) error {
 	return nil
 }
 
+// MarshalJSON outputs the filter the way relays expect it: empty lists and
+// unset fields are left out instead of being null or empty, as relays may
+// read those as conditions nothing matches, and "limit" is only there if it
+// is set, or if LimitZero is true.
 func (f Filter) MarshalJSON() ([]byte, error) {
 	var arena fastjson.Arena
 
 	o := arena.NewObject()
 
-	if f.IDs != nil {
+	if len(f.IDs) > 0 {
 		o.Set("ids", stringListToFastjsonArray(&arena, f.IDs))
 	}
-	if f.Kinds != nil {
+	if len(f.Kinds) > 0 {
 		o.Set("kinds", intListToFastjsonArray(&arena, f.Kinds))
 	}
-	if f.Authors != nil {
+	if len(f.Authors) > 0 {
 		o.Set("authors", stringListToFastjsonArray(&arena, f.Authors))
 	}
 	if f.Since != nil {
-		o.Set("since", arena.NewNumberInt(int(f.Since.Unix())))
+		o.Set("since", arena.NewNumberString(strconv.FormatInt(f.Since.Unix(), 10)))
 	}
 	if f.Until != nil {
-		o.Set("until", arena.NewNumberInt(int(f.Until.Unix())))
+		o.Set("until", arena.NewNumberString(strconv.FormatInt(f.Until.Unix(), 10)))
+	}
+	if f.Limit != 0 || f.LimitZero {
+		o.Set("limit", arena.NewNumberInt(f.Limit))
+	}
+	if f.Search != "" {
+		o.Set("search", arena.NewString(f.Search))
 	}
 	if f.Tags != nil {
-		for k, v := range f.Tags {
-			o.Set("#"+k, stringListToFastjsonArray(&arena, v))
+		// sorted so the same filter always produces the same json
+		names := make([]string, 0, len(f.Tags))
+		for k, values := range f.Tags {
+			if len(values) > 0 {
+				names = append(names, k)
+			}
+		}
+		sort.Strings(names)
+		for _, k := range names {
+			o.Set("#"+k, stringListToFastjsonArray(&arena, f.Tags[k]))
 		}
 	}
 
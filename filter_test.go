@@ -7,15 +7,15 @@ import (
 )
 
 func TestFilterUnmarshal(t *testing.T) {
-	raw := `{"ids": ["abc"],"#e":["zzz"],"#something":["nothing","bab"],"since":1644254609}`
+	raw := `{"ids": ["abc"],"#e":["zzz"],"#something":["nothing","bab"],"since":1644254609,"limit":20,"search":"purple"}`
 	var f Filter
 	err := json.Unmarshal([]byte(raw), &f)
 	if err != nil {
-		t.Errorf("failed to parse filter json: %w", err)
+		t.Errorf("failed to parse filter json: %v", err)
 	}
 
 	if f.Since == nil || f.Since.Format("2006-01-02") != "2022-02-07" ||
-		f.Until != nil ||
+		f.Until != nil || f.Limit != 20 || f.Search != "purple" ||
 		f.Tags == nil || len(f.Tags) != 2 || !f.Tags["something"].Contains("bab") {
 		t.Error("failed to parse filter correctly")
 	}
@@ -25,20 +25,52 @@ func TestFilterMarshal(t *testing.T) {
 	tm := time.Unix(12345678, 0)
 
 	filterj, err := json.Marshal(Filter{
-		Kinds: IntList{1, 2, 4},
-		Tags:  TagMap{"fruit": {"banana", "mango"}},
-		Until: &tm,
+		Kinds:  IntList{1, 2, 4},
+		Tags:   TagMap{"fruit": {"banana", "mango"}},
+		Until:  &tm,
+		Limit:  10,
+		Search: "nostr clients",
 	})
 	if err != nil {
-		t.Errorf("failed to marshal filter json: %w", err)
+		t.Errorf("failed to marshal filter json: %v", err)
 	}
 
-	expected := `{"kinds":[1,2,4],"until":12345678,"#fruit":["banana","mango"]}`
+	expected := `{"kinds":[1,2,4],"until":12345678,"limit":10,"search":"nostr clients","#fruit":["banana","mango"]}`
 	if string(filterj) != expected {
 		t.Errorf("filter json was wrong: %s != %s", string(filterj), expected)
 	}
 }
 
+func TestFilterMarshalOmitsEmpty(t *testing.T) {
+	since := time.Unix(1700000000, 0)
+	for _, c := range []struct {
+		filter   Filter
+		expected string
+	}{
+		{Filter{}, `{}`},
+		{Filter{IDs: StringList{"abc", "def"}}, `{"ids":["abc","def"]}`},
+		{Filter{Tags: TagMap{"e": {"abc"}}}, `{"#e":["abc"]}`},
+		{Filter{Kinds: IntList{}, Authors: StringList{}, Tags: TagMap{"p": {}}}, `{}`},
+		{Filter{Kinds: IntList{1}, Since: &since}, `{"kinds":[1],"since":1700000000}`},
+		{Filter{Kinds: IntList{1}, LimitZero: true}, `{"kinds":[1],"limit":0}`},
+		{Filter{Authors: StringList{"abc"}, Limit: 5}, `{"authors":["abc"],"limit":5}`},
+		{Filter{Tags: TagMap{"t": {"b"}, "e": {"a"}}, Search: "x"}, `{"search":"x","#e":["a"],"#t":["b"]}`},
+	} {
+		j, err := json.Marshal(c.filter)
+		if err != nil || string(j) != c.expected {
+			t.Errorf("filter json was wrong: %s != %s (%v)", j, c.expected, err)
+		}
+	}
+
+	var f Filter
+	if err := json.Unmarshal([]byte(`{"kinds":[1],"limit":0}`), &f); err != nil || f.Limit != 0 || !f.LimitZero {
+		t.Errorf("limit 0 wasn't parsed as LimitZero: %v %v", f, err)
+	}
+	if j, _ := json.Marshal(f); string(j) != `{"kinds":[1],"limit":0}` {
+		t.Errorf("limit 0 didn't survive a round trip: %s", j)
+	}
+}
+
 func TestFilterMatching(t *testing.T) {
 	if (Filter{Kinds: IntList{4, 5}}).Matches(&Event{Kind: 6}) {
 		t.Error("matched event that shouldn't have matched")
@@ -48,6 +80,10 @@ func TestFilterMatching(t *testing.T) {
 		t.Error("failed to match event by kind")
 	}
 
+	if !(Filter{Kinds: IntList{1}, Search: "unrelated"}).Matches(&Event{Kind: 1, Content: "hello"}) {
+		t.Error("search should be left for the relay to evaluate")
+	}
+
 	if !(Filter{
 		Kinds: IntList{4, 5},
 		Tags: TagMap{
@@ -63,6 +99,50 @@ func TestFilterMatching(t *testing.T) {
 	}
 }
 
+func TestFilterWithKinds(t *testing.T) {
+	base := Filter{Authors: StringList{"abc"}, Kinds: IntList{KindTextNote}}
+	f := base.WithKinds(KindRepost, KindReaction)
+	if len(base.Kinds) != 1 || !f.Kinds.Equals(IntList{KindTextNote, KindRepost, KindReaction}) {
+		t.Errorf("wrong kinds: %v %v", base.Kinds, f.Kinds)
+	}
+
+	evt := &Event{Kind: KindRepost, PubKey: "abc"}
+	if !f.Matches(evt) || base.Matches(evt) || !evt.IsKind(KindTextNote, KindRepost) || evt.IsKind() {
+		t.Error("failed to match by the added kinds")
+	}
+	if !IsRegular(KindTextNote) || IsRegular(KindContactList) || IsRegular(30023) {
+		t.Error("wrong regular kinds")
+	}
+}
+
+func TestFilterTagMatching(t *testing.T) {
+	var f Filter
+	if err := json.Unmarshal([]byte(`{"kinds":[1],"#t":["nostr","bitcoin"]}`), &f); err != nil {
+		t.Fatalf("failed to parse filter json: %v", err)
+	}
+
+	tagged := NewEvent(KindTextNote, "gm").WithTag("t", "nostr")
+	untagged := NewEvent(KindTextNote, "gm").WithTag("t", "coffee")
+	if !f.Matches(tagged) {
+		t.Error("failed to match event by #t")
+	}
+	if f.Matches(untagged) {
+		t.Error("matched event with other hashtags")
+	}
+	if f.Matches(NewEvent(KindReaction, "+").WithTag("t", "nostr")) {
+		t.Error("matched event of another kind")
+	}
+
+	if !(Filter{Tags: TagMap{"fruit": {"banana"}}}).Matches(untagged) {
+		t.Error("multi-letter tag names shouldn't be used for matching")
+	}
+
+	filterj, _ := json.Marshal(f)
+	if expected := `{"kinds":[1],"#t":["nostr","bitcoin"]}`; string(filterj) != expected {
+		t.Errorf("filter json was wrong: %s != %s", string(filterj), expected)
+	}
+}
+
 func TestFilterEquality(t *testing.T) {
 	if !FilterEqual(
 		Filter{Kinds: IntList{4, 5}},
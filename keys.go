@@ -2,20 +2,40 @@ package nostr
 
 import (
 	"encoding/hex"
+	"fmt"
+	"math/big"
 
 	"github.com/fiatjaf/bip340"
 )
 
+// GeneratePrivateKey returns a new random private key as 64 hex characters.
 func GeneratePrivateKey() string {
-	return hex.EncodeToString(bip340.GeneratePrivateKey().Bytes())
+	return fmt.Sprintf("%064x", bip340.GeneratePrivateKey())
 }
 
+// GetPublicKey derives the x-only public key, as 64 hex characters, that
+// corresponds to the given hex private key.
 func GetPublicKey(sk string) (string, error) {
-	privateKey, err := bip340.ParsePrivateKey(sk)
+	privateKey, err := parsePrivateKey(sk)
 	if err != nil {
 		return "", err
 	}
 
-	publicKey := bip340.GetPublicKey(privateKey)
-	return hex.EncodeToString(publicKey[:]), nil
+	var skb [32]byte
+	x, _ := bip340.Curve.ScalarBaseMult(privateKey.FillBytes(skb[:]))
+
+	var pk [32]byte
+	x.FillBytes(pk[:])
+	return hex.EncodeToString(pk[:]), nil
+}
+
+func parsePrivateKey(sk string) (*big.Int, error) {
+	if len(sk) != 64 {
+		return nil, fmt.Errorf("private key must be 64 hex characters, not %d", len(sk))
+	}
+	if _, err := hex.DecodeString(sk); err != nil {
+		return nil, fmt.Errorf("private key is invalid hex: %w", err)
+	}
+
+	return bip340.ParsePrivateKey(sk)
 }
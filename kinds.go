@@ -0,0 +1,132 @@
+package nostr
+
+import (
+	"fmt"
+	"strconv"
+)
+
+const (
+	KindSetMetadata            int = 0
+	KindTextNote               int = 1
+	KindRecommendServer        int = 2
+	KindContactList            int = 3
+	KindEncryptedDirectMessage int = 4
+	KindDeletion               int = 5
+	KindRepost                 int = 6
+	KindReaction               int = 7
+	KindGenericRepost          int = 16
+	KindFileMetadata           int = 1063
+	KindReporting              int = 1984
+	KindZapRequest             int = 9734
+	KindZap                    int = 9735
+	KindClientAuthentication   int = 22242
+	KindArticle                int = 30023
+)
+
+var kindNames = map[int]string{
+	KindSetMetadata:            "Set Metadata",
+	KindTextNote:               "Text Note",
+	KindRecommendServer:        "Recommend Server",
+	KindContactList:            "Contact List",
+	KindEncryptedDirectMessage: "Encrypted DM",
+	KindDeletion:               "Deletion",
+	KindRepost:                 "Repost",
+	KindReaction:               "Reaction",
+	8:                          "Badge Award",
+	KindGenericRepost:          "Generic Repost",
+	40:                         "Channel Creation",
+	41:                         "Channel Metadata",
+	42:                         "Channel Message",
+	43:                         "Channel Hide Message",
+	44:                         "Channel Mute User",
+	KindFileMetadata:           "File Metadata",
+	KindReporting:              "Report",
+	KindZapRequest:             "Zap Request",
+	KindZap:                    "Zap Receipt",
+	10000:                      "Mute List",
+	10001:                      "Pin List",
+	10002:                      "Relay List Metadata",
+	10003:                      "Bookmark List",
+	KindClientAuthentication:   "Client Authentication",
+	24133:                      "Nostr Connect",
+	27235:                      "HTTP Auth",
+	30000:                      "Follow Set",
+	30002:                      "Relay Set",
+	30003:                      "Bookmark Set",
+	30008:                      "Profile Badges",
+	30009:                      "Badge Definition",
+	KindArticle:                "Long-form Content",
+	30078:                      "Application-specific Data",
+	31989:                      "Handler Recommendation",
+	31990:                      "Handler Information",
+}
+
+// KindName returns a human-readable name for the kind, or its range, like
+// "Replaceable (10050)", for kinds that aren't known.
+func KindName(kind int) string {
+	if name, ok := kindNames[kind]; ok {
+		return name
+	}
+
+	switch {
+	case IsReplaceable(kind):
+		return fmt.Sprintf("Replaceable (%d)", kind)
+	case IsEphemeral(kind):
+		return fmt.Sprintf("Ephemeral (%d)", kind)
+	case IsParameterizedReplaceable(kind):
+		return fmt.Sprintf("Parameterized Replaceable (%d)", kind)
+	default:
+		return fmt.Sprintf("Unknown (%d)", kind)
+	}
+}
+
+// IsReplaceable checks if only the latest event of this kind from each
+// author should be kept: kinds 0, 3 and 10000-19999.
+func IsReplaceable(kind int) bool {
+	return kind == KindSetMetadata || kind == KindContactList || (kind >= 10000 && kind < 20000)
+}
+
+// IsEphemeral checks if events of this kind aren't expected to be stored:
+// kinds 20000-29999.
+func IsEphemeral(kind int) bool {
+	return kind >= 20000 && kind < 30000
+}
+
+// IsParameterizedReplaceable checks if only the latest event of this kind
+// from each author for each "d" tag should be kept: kinds 30000-39999.
+func IsParameterizedReplaceable(kind int) bool {
+	return kind >= 30000 && kind < 40000
+}
+
+// IsRegular checks if all the events of this kind are expected to be stored.
+func IsRegular(kind int) bool {
+	return !IsReplaceable(kind) && !IsEphemeral(kind) && !IsParameterizedReplaceable(kind)
+}
+
+// ReplaceableKey returns the key that identifies the events that replace
+// each other, "<kind>:<pubkey>" for replaceable events and
+// "<kind>:<pubkey>:<d tag>" for parameterized replaceable events, or an empty
+// string for other events.
+func (evt *Event) ReplaceableKey() string {
+	switch {
+	case IsReplaceable(evt.Kind):
+		return strconv.Itoa(evt.Kind) + ":" + evt.PubKey
+	case IsParameterizedReplaceable(evt.Kind):
+		return strconv.Itoa(evt.Kind) + ":" + evt.PubKey + ":" + evt.dTag()
+	default:
+		return ""
+	}
+}
+
+// dTag returns the value of the first "d" tag, or an empty string.
+func (evt *Event) dTag() string {
+	if tag := evt.Tags.GetFirst("d"); tag != nil && len(*tag) >= 2 {
+		return (*tag)[1]
+	}
+	return ""
+}
+
+// IsKind checks if the event kind is any of the given ones.
+func (evt *Event) IsKind(kinds ...int) bool {
+	return IntList(kinds).Contains(evt.Kind)
+}
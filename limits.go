@@ -0,0 +1,63 @@
+package nostr
+
+import (
+	"fmt"
+	"unicode/utf8"
+
+	"github.com/fiatjaf/go-nostr/nip11"
+)
+
+// Limits are the sizes relays accept for events, a zero field means there is
+// no limit for it. Lengths are counted in characters, not bytes.
+type Limits struct {
+	MaxContentLength  int
+	MaxTags           int
+	MaxTagValueLength int
+}
+
+// LimitError is returned by CheckLimits for the first limit an event exceeds.
+type LimitError struct {
+	// Limit is the name of the Limits field that was exceeded.
+	Limit string
+	Max   int
+	Got   int
+}
+
+func (e *LimitError) Error() string {
+	return fmt.Sprintf("event exceeds %s of %d: got %d", e.Limit, e.Max, e.Got)
+}
+
+// RelayLimits returns the Limits advertised by a relay in its NIP-11
+// information document.
+func RelayLimits(l nip11.RelayLimitation) Limits {
+	return Limits{
+		MaxContentLength: l.MaxContentLength,
+		MaxTags:          l.MaxEventTags,
+	}
+}
+
+// CheckLimits returns a *LimitError if the event exceeds any of the limits,
+// so it can be fixed before being rejected by the relay.
+func (evt *Event) CheckLimits(l Limits) error {
+	if l.MaxContentLength > 0 {
+		if n := utf8.RuneCountInString(evt.Content); n > l.MaxContentLength {
+			return &LimitError{Limit: "MaxContentLength", Max: l.MaxContentLength, Got: n}
+		}
+	}
+
+	if l.MaxTags > 0 && len(evt.Tags) > l.MaxTags {
+		return &LimitError{Limit: "MaxTags", Max: l.MaxTags, Got: len(evt.Tags)}
+	}
+
+	if l.MaxTagValueLength > 0 {
+		for _, tag := range evt.Tags {
+			for _, value := range tag {
+				if n := utf8.RuneCountInString(value); n > l.MaxTagValueLength {
+					return &LimitError{Limit: "MaxTagValueLength", Max: l.MaxTagValueLength, Got: n}
+				}
+			}
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,134 @@
+package nostr
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/fiatjaf/go-nostr/nip05"
+)
+
+// Profile is the content of a kind-0 event.
+type Profile struct {
+	Name        string `json:"name,omitempty"`
+	DisplayName string `json:"display_name,omitempty"`
+	About       string `json:"about,omitempty"`
+	Picture     string `json:"picture,omitempty"`
+	Banner      string `json:"banner,omitempty"`
+	Website     string `json:"website,omitempty"`
+	NIP05       string `json:"nip05,omitempty"`
+	Lud06       string `json:"lud06,omitempty"`
+	Lud16       string `json:"lud16,omitempty"`
+
+	// Extra has the fields clients add that aren't above, kept as they were
+	// so they aren't lost when the profile is written back.
+	Extra map[string]json.RawMessage `json:"-"`
+}
+
+// profileFields are the json names of the Profile fields, which don't go in
+// Extra.
+var profileFields = []string{"name", "display_name", "about", "picture", "banner", "website", "nip05", "lud06", "lud16"}
+
+// UnmarshalJSON decodes the profile fields and puts the others in Extra.
+func (p *Profile) UnmarshalJSON(b []byte) error {
+	type profile Profile
+	if err := json.Unmarshal(b, (*profile)(p)); err != nil {
+		return err
+	}
+
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(b, &fields); err != nil {
+		return err
+	}
+	// encoding/json matches the names case-insensitively, so these do too
+	for key := range fields {
+		for _, name := range profileFields {
+			if strings.EqualFold(key, name) {
+				delete(fields, key)
+				break
+			}
+		}
+	}
+	p.Extra = nil
+	if len(fields) > 0 {
+		p.Extra = fields
+	}
+	return nil
+}
+
+// MarshalJSON encodes the profile fields along with the ones in Extra.
+func (p Profile) MarshalJSON() ([]byte, error) {
+	type profile Profile
+	known, err := json.Marshal(profile(p))
+	if err != nil || len(p.Extra) == 0 {
+		return known, err
+	}
+
+	fields := make(map[string]json.RawMessage, len(p.Extra)+len(profileFields))
+	for key, value := range p.Extra {
+		fields[key] = value
+	}
+	// the profile fields take precedence over the same names in Extra
+	if err := json.Unmarshal(known, &fields); err != nil {
+		return nil, err
+	}
+	return json.Marshal(fields)
+}
+
+// ParseMetadata decodes the content of a kind-0 event. Unknown fields don't
+// make it fail, they are kept in Extra.
+func ParseMetadata(evt *Event) (*Profile, error) {
+	if evt.Kind != KindSetMetadata {
+		return nil, fmt.Errorf("event kind is %d, not %d", evt.Kind, KindSetMetadata)
+	}
+
+	var profile Profile
+	if err := json.Unmarshal([]byte(evt.Content), &profile); err != nil {
+		return nil, fmt.Errorf("failed to parse metadata: %w", err)
+	}
+	return &profile, nil
+}
+
+// ToEvent returns an unsigned kind-0 event with the profile as content.
+func (p *Profile) ToEvent() *Event {
+	content, _ := json.Marshal(p)
+	return NewEvent(KindSetMetadata, string(content))
+}
+
+// VerifyAuthorNIP05 checks that the NIP-05 identifier, like "bob@example.com",
+// points to the author of the event, with a request made by client, which is
+// a default one if nil. The identifier is usually the one in the author
+// profile; for kind-0 events it can be empty to use the one in the content.
+// To avoid querying the domain for each event see VerifyAuthorNIP05Cached.
+func (evt *Event) VerifyAuthorNIP05(ctx context.Context, client *http.Client, identifier string) (bool, error) {
+	return evt.verifyAuthorNIP05(identifier, func(identifier string) (string, []string, error) {
+		return nip05.QueryIdentifier(ctx, client, identifier)
+	})
+}
+
+// VerifyAuthorNIP05Cached is like VerifyAuthorNIP05, but makes the lookup
+// through cache, which reuses its results.
+func (evt *Event) VerifyAuthorNIP05Cached(ctx context.Context, cache *nip05.Cache, identifier string) (bool, error) {
+	return evt.verifyAuthorNIP05(identifier, func(identifier string) (string, []string, error) {
+		return cache.QueryIdentifier(ctx, identifier)
+	})
+}
+
+func (evt *Event) verifyAuthorNIP05(identifier string, query func(string) (string, []string, error)) (bool, error) {
+	if identifier == "" && evt.Kind == KindSetMetadata {
+		if profile, err := ParseMetadata(evt); err == nil {
+			identifier = profile.NIP05
+		}
+	}
+	if identifier == "" {
+		return false, fmt.Errorf("no NIP-05 identifier to verify")
+	}
+
+	pubkey, _, err := query(identifier)
+	if err != nil {
+		return false, err
+	}
+	return strings.EqualFold(pubkey, evt.PubKey), nil
+}
@@ -0,0 +1,384 @@
+package nostr
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+)
+
+// EventID identifies an event for set reconciliation, negentropy orders the
+// events by their creation time, so it is needed along with the id.
+type EventID struct {
+	ID        string
+	CreatedAt Timestamp
+}
+
+const (
+	negentropyVersion         = 0x61
+	negentropyBuckets         = 16
+	negentropyFingerprintSize = 16
+
+	negentropyModeSkip        = 0
+	negentropyModeFingerprint = 1
+	negentropyModeIDList      = 2
+
+	negentropyInfinity = math.MaxUint64
+)
+
+var errNegentropyTruncated = errors.New("negentropy message is truncated")
+
+// Negentropy is one side of a NIP-77 range-based set reconciliation, using
+// version 1 of the negentropy protocol. The initiator calls Initiate once and
+// then Reconcile with each message it gets back, until there is no next
+// message to send, while the other side only calls Reconcile.
+type Negentropy struct {
+	items     []negentropyItem
+	initiator bool
+
+	lastTimestampIn  uint64
+	lastTimestampOut uint64
+}
+
+type negentropyItem struct {
+	timestamp uint64
+	id        [32]byte
+}
+
+// negentropyBound is the exclusive upper end of a range: the items that come
+// before the timestamp, or before the id prefix among those with the same
+// timestamp.
+type negentropyBound struct {
+	timestamp uint64
+	prefix    []byte
+}
+
+// NewNegentropy creates one side of a reconciliation with the events it has.
+func NewNegentropy(events []EventID) (*Negentropy, error) {
+	items := make([]negentropyItem, len(events))
+	for i, evt := range events {
+		id, err := hex.DecodeString(evt.ID)
+		if err != nil || len(id) != 32 {
+			return nil, fmt.Errorf("event id '%s' is not 32 bytes hex", evt.ID)
+		}
+		items[i].timestamp = uint64(evt.CreatedAt)
+		copy(items[i].id[:], id)
+	}
+	sort.Slice(items, func(i, j int) bool {
+		if items[i].timestamp != items[j].timestamp {
+			return items[i].timestamp < items[j].timestamp
+		}
+		return bytes.Compare(items[i].id[:], items[j].id[:]) < 0
+	})
+
+	return &Negentropy{items: items}, nil
+}
+
+// Initiate returns the first message of the reconciliation, which makes this
+// side the initiator.
+func (n *Negentropy) Initiate() []byte {
+	n.initiator = true
+	n.lastTimestampOut = 0
+
+	out := []byte{negentropyVersion}
+	return n.splitRange(out, 0, len(n.items), negentropyBound{timestamp: negentropyInfinity})
+}
+
+// Reconcile processes a message from the other side and returns the message
+// to answer with. For the initiator it also returns the ids it has that the
+// other side doesn't and the ids it needs from the other side, and next is
+// nil once the reconciliation is complete.
+func (n *Negentropy) Reconcile(query []byte) (next []byte, have []string, need []string, err error) {
+	n.lastTimestampIn, n.lastTimestampOut = 0, 0
+	in := &negentropyReader{buf: query}
+	out := []byte{negentropyVersion}
+
+	version, err := in.readByte()
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	if version < 0x60 || version > 0x6f {
+		return nil, nil, nil, fmt.Errorf("invalid negentropy protocol version 0x%x", version)
+	}
+	if version != negentropyVersion {
+		if n.initiator {
+			return nil, nil, nil, fmt.Errorf("unsupported negentropy protocol version 0x%x", version)
+		}
+		// the initiator gets our version and may retry with it
+		return out, nil, nil, nil
+	}
+
+	prevBound := negentropyBound{}
+	prevIndex := 0
+	skip := false
+	for len(in.buf) > 0 {
+		var o []byte
+		doSkip := func() {
+			if skip {
+				skip = false
+				o = n.appendBound(o, prevBound)
+				o = appendVarInt(o, negentropyModeSkip)
+			}
+		}
+
+		currBound, err := n.readBound(in)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		mode, err := in.readVarInt()
+		if err != nil {
+			return nil, nil, nil, err
+		}
+
+		lower := prevIndex
+		upper := n.findLowerBound(prevIndex, currBound)
+
+		switch mode {
+		case negentropyModeSkip:
+			skip = true
+		case negentropyModeFingerprint:
+			theirs, err := in.readBytes(negentropyFingerprintSize)
+			if err != nil {
+				return nil, nil, nil, err
+			}
+			if ours := n.fingerprint(lower, upper); bytes.Equal(theirs, ours[:]) {
+				skip = true
+			} else {
+				doSkip()
+				o = n.splitRange(o, lower, upper, currBound)
+			}
+		case negentropyModeIDList:
+			count, err := in.readVarInt()
+			if err != nil {
+				return nil, nil, nil, err
+			}
+			// the count comes from the relay, so it can't be trusted to
+			// size the allocations until the message is known to hold it
+			if count > uint64(len(in.buf)/32) {
+				return nil, nil, nil, errNegentropyTruncated
+			}
+			theirs := make(map[[32]byte]bool, count)
+			order := make([][32]byte, 0, count)
+			for i := uint64(0); i < count; i++ {
+				b, err := in.readBytes(32)
+				if err != nil {
+					return nil, nil, nil, err
+				}
+				var id [32]byte
+				copy(id[:], b)
+				theirs[id] = true
+				order = append(order, id)
+			}
+
+			for _, item := range n.items[lower:upper] {
+				if theirs[item.id] {
+					delete(theirs, item.id)
+				} else if n.initiator {
+					have = append(have, hex.EncodeToString(item.id[:]))
+				}
+			}
+
+			if n.initiator {
+				skip = true
+				for _, id := range order {
+					if theirs[id] {
+						need = append(need, hex.EncodeToString(id[:]))
+					}
+				}
+			} else {
+				doSkip()
+				o = n.appendBound(o, currBound)
+				o = appendVarInt(o, negentropyModeIDList)
+				o = appendVarInt(o, uint64(upper-lower))
+				for _, item := range n.items[lower:upper] {
+					o = append(o, item.id[:]...)
+				}
+			}
+		default:
+			return nil, nil, nil, fmt.Errorf("unexpected negentropy mode %d", mode)
+		}
+
+		out = append(out, o...)
+		prevIndex = upper
+		prevBound = currBound
+	}
+
+	if n.initiator && len(out) == 1 {
+		return nil, have, need, nil
+	}
+	return out, have, need, nil
+}
+
+// splitRange appends the items between lower and upper as a list of ids, if
+// there are few of them, or as the fingerprints of buckets of them.
+func (n *Negentropy) splitRange(o []byte, lower, upper int, upperBound negentropyBound) []byte {
+	count := upper - lower
+	if count < negentropyBuckets*2 {
+		o = n.appendBound(o, upperBound)
+		o = appendVarInt(o, negentropyModeIDList)
+		o = appendVarInt(o, uint64(count))
+		for _, item := range n.items[lower:upper] {
+			o = append(o, item.id[:]...)
+		}
+		return o
+	}
+
+	perBucket := count / negentropyBuckets
+	withExtra := count % negentropyBuckets
+	curr := lower
+	for i := 0; i < negentropyBuckets; i++ {
+		size := perBucket
+		if i < withExtra {
+			size++
+		}
+		fingerprint := n.fingerprint(curr, curr+size)
+		curr += size
+
+		bound := upperBound
+		if curr != upper {
+			bound = minimalBound(n.items[curr-1], n.items[curr])
+		}
+		o = n.appendBound(o, bound)
+		o = appendVarInt(o, negentropyModeFingerprint)
+		o = append(o, fingerprint[:]...)
+	}
+	return o
+}
+
+// minimalBound returns the shortest bound that has prev before it and curr
+// after it.
+func minimalBound(prev, curr negentropyItem) negentropyBound {
+	if curr.timestamp != prev.timestamp {
+		return negentropyBound{timestamp: curr.timestamp}
+	}
+
+	shared := 0
+	for shared < 32 && curr.id[shared] == prev.id[shared] {
+		shared++
+	}
+	return negentropyBound{timestamp: curr.timestamp, prefix: curr.id[:shared+1]}
+}
+
+// findLowerBound returns the index of the first item from begin that isn't
+// before the bound.
+func (n *Negentropy) findLowerBound(begin int, bound negentropyBound) int {
+	return begin + sort.Search(len(n.items)-begin, func(i int) bool {
+		item := n.items[begin+i]
+		if item.timestamp != bound.timestamp {
+			return item.timestamp > bound.timestamp
+		}
+		return bytes.Compare(item.id[:], bound.prefix) >= 0
+	})
+}
+
+// fingerprint returns the first bytes of the sha256 of the sum of the ids
+// between lower and upper, as little-endian 256-bit numbers, followed by
+// their count.
+func (n *Negentropy) fingerprint(lower, upper int) [negentropyFingerprintSize]byte {
+	var sum [32]byte
+	for _, item := range n.items[lower:upper] {
+		var carry uint16
+		for i := 0; i < 32; i++ {
+			s := uint16(sum[i]) + uint16(item.id[i]) + carry
+			sum[i] = byte(s)
+			carry = s >> 8
+		}
+	}
+
+	h := sha256.Sum256(appendVarInt(sum[:], uint64(upper-lower)))
+	var fingerprint [negentropyFingerprintSize]byte
+	copy(fingerprint[:], h[:])
+	return fingerprint
+}
+
+// appendBound appends the bound, with its timestamp relative to the previous
+// one in the message.
+func (n *Negentropy) appendBound(o []byte, bound negentropyBound) []byte {
+	if bound.timestamp == negentropyInfinity {
+		n.lastTimestampOut = negentropyInfinity
+		o = appendVarInt(o, 0)
+	} else {
+		o = appendVarInt(o, bound.timestamp-n.lastTimestampOut+1)
+		n.lastTimestampOut = bound.timestamp
+	}
+	o = appendVarInt(o, uint64(len(bound.prefix)))
+	return append(o, bound.prefix...)
+}
+
+func (n *Negentropy) readBound(in *negentropyReader) (negentropyBound, error) {
+	var bound negentropyBound
+
+	timestamp, err := in.readVarInt()
+	if err != nil {
+		return bound, err
+	}
+	if timestamp == 0 || n.lastTimestampIn == negentropyInfinity {
+		n.lastTimestampIn = negentropyInfinity
+	} else {
+		n.lastTimestampIn += timestamp - 1
+	}
+	bound.timestamp = n.lastTimestampIn
+
+	size, err := in.readVarInt()
+	if err != nil {
+		return bound, err
+	}
+	if size > 32 {
+		return bound, fmt.Errorf("negentropy bound prefix has %d bytes", size)
+	}
+	bound.prefix, err = in.readBytes(int(size))
+	return bound, err
+}
+
+// appendVarInt appends n in base 128, most significant digits first, with the
+// high bit set on all bytes but the last.
+func appendVarInt(o []byte, n uint64) []byte {
+	var digits [10]byte
+	i := len(digits) - 1
+	digits[i] = byte(n & 0x7f)
+	for n >>= 7; n > 0; n >>= 7 {
+		i--
+		digits[i] = byte(n&0x7f) | 0x80
+	}
+	return append(o, digits[i:]...)
+}
+
+type negentropyReader struct {
+	buf []byte
+}
+
+func (r *negentropyReader) readByte() (byte, error) {
+	if len(r.buf) == 0 {
+		return 0, errNegentropyTruncated
+	}
+	b := r.buf[0]
+	r.buf = r.buf[1:]
+	return b, nil
+}
+
+func (r *negentropyReader) readBytes(size int) ([]byte, error) {
+	if len(r.buf) < size {
+		return nil, errNegentropyTruncated
+	}
+	b := r.buf[:size]
+	r.buf = r.buf[size:]
+	return b, nil
+}
+
+func (r *negentropyReader) readVarInt() (uint64, error) {
+	var n uint64
+	for i := 0; i < 10; i++ {
+		b, err := r.readByte()
+		if err != nil {
+			return 0, err
+		}
+		n = n<<7 | uint64(b&0x7f)
+		if b&0x80 == 0 {
+			return n, nil
+		}
+	}
+	return 0, fmt.Errorf("negentropy varint is too long")
+}
@@ -0,0 +1,103 @@
+package nostr
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestNegentropyEmptyMessage(t *testing.T) {
+	neg, _ := NewNegentropy(nil)
+	if msg := hex.EncodeToString(neg.Initiate()); msg != "6100000200" {
+		t.Errorf("wrong initial message for an empty set: %s", msg)
+	}
+}
+
+func TestNegentropyMalformedMessage(t *testing.T) {
+	for name, msg := range map[string]string{
+		"truncated bound": "610000",
+		"missing ids":     "6100000203",
+		"short id list":   "610000020a" + strings.Repeat("00", 32*9),
+		"huge count":      "61000002ffffffffffffffff7f",
+	} {
+		neg, _ := NewNegentropy(nil)
+		raw, _ := hex.DecodeString(msg)
+		if _, _, _, err := neg.Reconcile(raw); err == nil {
+			t.Errorf("%s: malformed message was accepted", name)
+		}
+	}
+}
+
+func TestNegentropyReconcile(t *testing.T) {
+	for _, sizes := range [][3]int{{0, 0, 0}, {10, 5, 3}, {1000, 40, 70}, {20000, 1, 0}} {
+		common, onlyClient, onlyRelay := sizes[0], sizes[1], sizes[2]
+		t.Run(strconv.Itoa(common), func(t *testing.T) {
+			var wantHave, wantNeed []string
+			var clientEvents, relayEvents []EventID
+			for i := 0; i < common+onlyClient+onlyRelay; i++ {
+				h := sha256.Sum256([]byte(strconv.Itoa(i)))
+				// many events share the same timestamp, so ids are compared too
+				evt := EventID{ID: hex.EncodeToString(h[:]), CreatedAt: Timestamp(1700000000 + i/7)}
+				switch {
+				case i < common:
+					clientEvents = append(clientEvents, evt)
+					relayEvents = append(relayEvents, evt)
+				case i < common+onlyClient:
+					clientEvents = append(clientEvents, evt)
+					wantHave = append(wantHave, evt.ID)
+				default:
+					relayEvents = append(relayEvents, evt)
+					wantNeed = append(wantNeed, evt.ID)
+				}
+			}
+
+			client, _ := NewNegentropy(clientEvents)
+			relay, _ := NewNegentropy(relayEvents)
+
+			var have, need []string
+			msg := client.Initiate()
+			for rounds := 0; msg != nil; rounds++ {
+				if rounds > 20 {
+					t.Fatal("reconciliation didn't finish")
+				}
+				answer, _, _, err := relay.Reconcile(msg)
+				if err != nil {
+					t.Fatalf("relay failed to reconcile: %v", err)
+				}
+				var h, n []string
+				msg, h, n, err = client.Reconcile(answer)
+				if err != nil {
+					t.Fatalf("client failed to reconcile: %v", err)
+				}
+				have = append(have, h...)
+				need = append(need, n...)
+			}
+
+			if !sameIDs(have, wantHave) {
+				t.Errorf("wrong have ids: got %d, expected %d", len(have), len(wantHave))
+			}
+			if !sameIDs(need, wantNeed) {
+				t.Errorf("wrong need ids: got %d, expected %d", len(need), len(wantNeed))
+			}
+		})
+	}
+}
+
+func sameIDs(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	a = append([]string(nil), a...)
+	b = append([]string(nil), b...)
+	sort.Strings(a)
+	sort.Strings(b)
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,80 @@
+package nip02
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/fiatjaf/go-nostr"
+)
+
+// Contact is one of the "p" tags of a kind-3 contact list.
+type Contact struct {
+	PubKey  string
+	Relay   string
+	Petname string
+}
+
+// RelayPermissions is how some clients store their relays in the content of
+// kind-3 events.
+type RelayPermissions struct {
+	Read  bool `json:"read"`
+	Write bool `json:"write"`
+}
+
+// ParseContactList returns the contacts in the "p" tags of a kind-3 event.
+func ParseContactList(evt *nostr.Event) ([]Contact, error) {
+	if evt.Kind != nostr.KindContactList {
+		return nil, fmt.Errorf("event kind is %d, not %d", evt.Kind, nostr.KindContactList)
+	}
+
+	contacts := make([]Contact, 0, len(evt.Tags))
+	for _, tag := range evt.Tags.GetAll("p") {
+		if len(tag) < 2 {
+			continue
+		}
+
+		contact := Contact{PubKey: tag[1]}
+		if len(tag) >= 3 {
+			contact.Relay = tag[2]
+		}
+		if len(tag) >= 4 {
+			contact.Petname = tag[3]
+		}
+		contacts = append(contacts, contact)
+	}
+
+	return contacts, nil
+}
+
+// ParseRelays decodes the relay map that some clients put in the content of
+// kind-3 events. Returns nil if the content is empty.
+func ParseRelays(evt *nostr.Event) (map[string]RelayPermissions, error) {
+	if evt.Kind != nostr.KindContactList {
+		return nil, fmt.Errorf("event kind is %d, not %d", evt.Kind, nostr.KindContactList)
+	}
+	if evt.Content == "" {
+		return nil, nil
+	}
+
+	var relays map[string]RelayPermissions
+	if err := json.Unmarshal([]byte(evt.Content), &relays); err != nil {
+		return nil, fmt.Errorf("failed to parse relays from content: %w", err)
+	}
+	return relays, nil
+}
+
+// MakeContactList returns an unsigned kind-3 event with the given contacts.
+func MakeContactList(contacts []Contact) *nostr.Event {
+	evt := nostr.NewEvent(nostr.KindContactList, "")
+	for _, contact := range contacts {
+		tag := nostr.Tag{"p", contact.PubKey}
+		if contact.Relay != "" || contact.Petname != "" {
+			tag = append(tag, contact.Relay)
+		}
+		if contact.Petname != "" {
+			tag = append(tag, contact.Petname)
+		}
+		evt.Tags = append(evt.Tags, tag)
+	}
+	return evt
+}
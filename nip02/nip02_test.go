@@ -0,0 +1,48 @@
+package nip02
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/fiatjaf/go-nostr"
+)
+
+func TestContactList(t *testing.T) {
+	contacts := []Contact{
+		{PubKey: "alice"},
+		{PubKey: "bob", Relay: "wss://relay.example.com"},
+		{PubKey: "carol", Petname: "c"},
+		{PubKey: "dave", Relay: "wss://relay.example.com", Petname: "d"},
+	}
+	evt := MakeContactList(contacts)
+	if len(evt.Tags[0]) != 2 || len(evt.Tags[1]) != 3 || len(evt.Tags[2]) != 4 || evt.Tags[2][2] != "" {
+		t.Errorf("wrong tags: %v", evt.Tags)
+	}
+
+	parsed, err := ParseContactList(evt)
+	if err != nil || !reflect.DeepEqual(parsed, contacts) {
+		t.Errorf("contacts didn't roundtrip: %v %v", parsed, err)
+	}
+
+	if _, err := ParseContactList(nostr.NewEvent(nostr.KindTextNote, "")); err == nil {
+		t.Error("a text note was parsed as a contact list")
+	}
+}
+
+func TestParseRelays(t *testing.T) {
+	evt := MakeContactList(nil)
+	if relays, err := ParseRelays(evt); err != nil || relays != nil {
+		t.Errorf("empty content should have no relays: %v %v", relays, err)
+	}
+
+	evt.Content = `{"wss://a.example.com":{"read":true,"write":false},"wss://b.example.com":{"read":true,"write":true}}`
+	relays, err := ParseRelays(evt)
+	if err != nil || len(relays) != 2 || relays["wss://a.example.com"].Write || !relays["wss://b.example.com"].Write {
+		t.Errorf("wrong relays: %v %v", relays, err)
+	}
+
+	evt.Content = "not json"
+	if _, err := ParseRelays(evt); err == nil {
+		t.Error("invalid content was parsed")
+	}
+}
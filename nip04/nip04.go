@@ -11,6 +11,7 @@ import (
 	"strings"
 
 	"github.com/btcsuite/btcd/btcec"
+	"github.com/fiatjaf/go-nostr"
 )
 
 // ECDH
@@ -68,6 +69,9 @@ func Decrypt(content string, key []byte) (string, error) {
 	if len(parts) < 2 {
 		return "", fmt.Errorf("Error parsing encrypted message: no initilization vector. \n")
 	}
+	if len(parts) > 2 {
+		return "", fmt.Errorf("Error parsing encrypted message: more than one initialization vector. \n")
+	}
 
 	ciphertext, err := base64.StdEncoding.DecodeString(parts[0])
 	if err != nil {
@@ -83,9 +87,57 @@ func Decrypt(content string, key []byte) (string, error) {
 	if err != nil {
 		return "", fmt.Errorf("Error creating block cipher: %s. \n", err.Error())
 	}
+	if len(iv) != block.BlockSize() {
+		return "", fmt.Errorf("Error parsing encrypted message: iv must be %d bytes, not %d. \n", block.BlockSize(), len(iv))
+	}
+	if len(ciphertext) == 0 || len(ciphertext)%block.BlockSize() != 0 {
+		return "", fmt.Errorf("Error parsing encrypted message: ciphertext is not a multiple of the block size. \n")
+	}
+
 	mode := cipher.NewCBCDecrypter(block, iv)
 	plaintext := make([]byte, len(ciphertext))
 	mode.CryptBlocks(plaintext, ciphertext)
 
-	return string(plaintext[:]), nil
+	// remove PKCS5 padding
+	padding := int(plaintext[len(plaintext)-1])
+	if padding == 0 || padding > block.BlockSize() {
+		return "", fmt.Errorf("Error decrypting message: invalid padding. \n")
+	}
+	for _, b := range plaintext[len(plaintext)-padding:] {
+		if int(b) != padding {
+			return "", fmt.Errorf("Error decrypting message: invalid padding. \n")
+		}
+	}
+
+	return string(plaintext[:len(plaintext)-padding]), nil
+}
+
+// DecryptEvent decrypts the content of a kind-4 event, sent either by us or to us,
+// using our private key.
+func DecryptEvent(evt *nostr.Event, privateKey string) (string, error) {
+	if evt.Kind != nostr.KindEncryptedDirectMessage {
+		return "", fmt.Errorf("Error decrypting event: kind %d is not an encrypted direct message. \n", evt.Kind)
+	}
+
+	pubkey, err := nostr.GetPublicKey(privateKey)
+	if err != nil {
+		return "", fmt.Errorf("Error deriving public key: %s. \n", err)
+	}
+
+	// if we're the sender the counterparty is in the "p" tag
+	counterparty := evt.PubKey
+	if counterparty == pubkey {
+		ptag := evt.Tags.GetFirst("p")
+		if ptag == nil || len(*ptag) < 2 {
+			return "", fmt.Errorf("Error decrypting event: no \"p\" tag. \n")
+		}
+		counterparty = (*ptag)[1]
+	}
+
+	sharedSecret, err := ComputeSharedSecret(privateKey, counterparty)
+	if err != nil {
+		return "", err
+	}
+
+	return Decrypt(evt.Content, sharedSecret)
 }
@@ -0,0 +1,106 @@
+package nip04
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"encoding/base64"
+	"strings"
+	"testing"
+
+	"github.com/fiatjaf/go-nostr"
+)
+
+func sharedSecrets(t *testing.T) (alice string, bob string, aliceKey []byte, bobKey []byte) {
+	alice, bob = nostr.GeneratePrivateKey(), nostr.GeneratePrivateKey()
+	alicePK, _ := nostr.GetPublicKey(alice)
+	bobPK, _ := nostr.GetPublicKey(bob)
+
+	aliceKey, err := ComputeSharedSecret(alice, bobPK)
+	if err != nil {
+		t.Fatalf("failed to compute shared secret: %v", err)
+	}
+	bobKey, err = ComputeSharedSecret(bob, alicePK)
+	if err != nil {
+		t.Fatalf("failed to compute shared secret: %v", err)
+	}
+	return alice, bob, aliceKey, bobKey
+}
+
+func TestEncryptDecrypt(t *testing.T) {
+	_, _, aliceKey, bobKey := sharedSecrets(t)
+
+	// lengths around the block size, 16 bytes of padding for the full ones
+	for _, message := range []string{"", "hello", strings.Repeat("a", 15), strings.Repeat("b", 16), strings.Repeat("c", 33), "çå∂ 🤙"} {
+		ciphertext, err := Encrypt(message, aliceKey)
+		if err != nil {
+			t.Fatalf("failed to encrypt: %v", err)
+		}
+		plaintext, err := Decrypt(ciphertext, bobKey)
+		if err != nil || plaintext != message {
+			t.Errorf("'%s' didn't roundtrip: '%s' %v", message, plaintext, err)
+		}
+	}
+}
+
+// encryptRaw encrypts plaintext, which must already be a multiple of the
+// block size, without adding any padding.
+func encryptRaw(t *testing.T, plaintext []byte, key []byte) string {
+	iv := make([]byte, 16)
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		t.Fatalf("failed to create cipher: %v", err)
+	}
+	ciphertext := make([]byte, len(plaintext))
+	cipher.NewCBCEncrypter(block, iv).CryptBlocks(ciphertext, plaintext)
+	return base64.StdEncoding.EncodeToString(ciphertext) + "?iv=" + base64.StdEncoding.EncodeToString(iv)
+}
+
+func TestDecryptMalformed(t *testing.T) {
+	_, _, key, _ := sharedSecrets(t)
+	valid, _ := Encrypt("hello", key)
+	ciphertext, iv := valid[:strings.Index(valid, "?iv=")], valid[strings.Index(valid, "?iv=")+4:]
+
+	for name, content := range map[string]string{
+		"no iv":            ciphertext,
+		"two ivs":          valid + "?iv=" + iv,
+		"bad ciphertext":   "!!!?iv=" + iv,
+		"bad iv":           ciphertext + "?iv=!!!",
+		"short iv":         ciphertext + "?iv=" + base64.StdEncoding.EncodeToString([]byte("short")),
+		"empty ciphertext": "?iv=" + iv,
+		"partial block":    base64.StdEncoding.EncodeToString([]byte("not a block")) + "?iv=" + iv,
+		"zero padding":     encryptRaw(t, append([]byte("hello world abc"), 0), key),
+		"big padding":      encryptRaw(t, append([]byte("hello world abc"), 17), key),
+		"uneven padding":   encryptRaw(t, append([]byte("hello world a"), 1, 3, 3), key),
+	} {
+		if _, err := Decrypt(content, key); err == nil {
+			t.Errorf("%s: malformed content was decrypted", name)
+		}
+	}
+}
+
+func TestDecryptEvent(t *testing.T) {
+	alice, bob, aliceKey, _ := sharedSecrets(t)
+	bobPK, _ := nostr.GetPublicKey(bob)
+
+	content, _ := Encrypt("hi bob", aliceKey)
+	evt, _ := nostr.NewEvent(nostr.KindEncryptedDirectMessage, content).WithTag("p", bobPK).SignWith(alice)
+
+	// both the recipient and the sender can read it
+	for _, sk := range []string{bob, alice} {
+		if plaintext, err := DecryptEvent(evt, sk); err != nil || plaintext != "hi bob" {
+			t.Errorf("failed to decrypt: '%s' %v", plaintext, err)
+		}
+	}
+	if plaintext, _ := DecryptEvent(evt, nostr.GeneratePrivateKey()); plaintext == "hi bob" {
+		t.Error("a third party decrypted the message")
+	}
+
+	noRecipient, _ := nostr.NewEvent(nostr.KindEncryptedDirectMessage, content).SignWith(alice)
+	if _, err := DecryptEvent(noRecipient, alice); err == nil {
+		t.Error("sender decrypted a message without a recipient")
+	}
+	note, _ := nostr.NewEvent(nostr.KindTextNote, content).WithTag("p", bobPK).SignWith(alice)
+	if _, err := DecryptEvent(note, bob); err == nil {
+		t.Error("a text note was decrypted")
+	}
+}
@@ -0,0 +1,202 @@
+package nip05
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// defaultClient is used when no client is given.
+var defaultClient = &http.Client{Timeout: 10 * time.Second, CheckRedirect: noRedirects}
+
+// noRedirects makes a client stop at the first response, NIP-05 forbids
+// following redirects from .well-known/nostr.json.
+func noRedirects(*http.Request, []*http.Request) error {
+	return http.ErrUseLastResponse
+}
+
+type WellKnownResponse struct {
+	Names  map[string]string   `json:"names"`
+	Relays map[string][]string `json:"relays"`
+}
+
+// QueryIdentifier fetches the pubkey and relay hints that a "name@domain"
+// identifier points to, a bare "domain" is the same as "_@domain". The request
+// is made with client, or with one that has a 10 seconds timeout if nil, and
+// redirects aren't followed in any case.
+func QueryIdentifier(ctx context.Context, client *http.Client, fullIdentifier string) (pubkey string, relays []string, err error) {
+	name, domain := ParseIdentifier(fullIdentifier)
+	if !validName(name) || !validDomain(domain) {
+		return "", nil, fmt.Errorf("invalid identifier '%s'", fullIdentifier)
+	}
+	if client == nil {
+		client = defaultClient
+	} else {
+		c := *client
+		c.CheckRedirect = noRedirects
+		client = &c
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "GET", fmt.Sprintf("https://%s/.well-known/nostr.json?name=%s",
+		domain, url.QueryEscape(name)), nil)
+	if err != nil {
+		return "", nil, fmt.Errorf("invalid domain '%s': %w", domain, err)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to fetch .well-known/nostr.json from %s: %w", domain, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != 200 {
+		return "", nil, fmt.Errorf("%s returned status %d", domain, resp.StatusCode)
+	}
+
+	var result WellKnownResponse
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return "", nil, fmt.Errorf("failed to decode json response from %s: %w", domain, err)
+	}
+
+	pubkey, ok := result.Names[name]
+	if !ok {
+		return "", nil, fmt.Errorf("name '%s' not found on %s", name, domain)
+	}
+	return pubkey, result.Relays[pubkey], nil
+}
+
+// VerifyNIP05 checks if the identifier points to the expected hex pubkey, see
+// QueryIdentifier.
+func VerifyNIP05(ctx context.Context, client *http.Client, fullIdentifier string, expectedPubkey string) (bool, error) {
+	pubkey, _, err := QueryIdentifier(ctx, client, fullIdentifier)
+	if err != nil {
+		return false, err
+	}
+
+	return strings.EqualFold(pubkey, expectedPubkey), nil
+}
+
+// Cache reuses the results of QueryIdentifier for TTL, so the domains aren't
+// queried again for each event. Each one has its own entries, made with its
+// own Client, and it is safe to use from multiple goroutines.
+type Cache struct {
+	// TTL is how long successful lookups are reused for, zero disables the
+	// cache.
+	TTL time.Duration
+
+	// Client makes the requests, see QueryIdentifier.
+	Client *http.Client
+
+	mutex     sync.Mutex
+	entries   map[string]cachedIdentifier
+	nextPrune int
+}
+
+type cachedIdentifier struct {
+	pubkey  string
+	relays  []string
+	expires time.Time
+}
+
+// QueryIdentifier is like the QueryIdentifier function, but returns the
+// cached result if it is still fresh.
+func (c *Cache) QueryIdentifier(ctx context.Context, fullIdentifier string) (pubkey string, relays []string, err error) {
+	name, domain := ParseIdentifier(fullIdentifier)
+	key := name + "@" + domain
+
+	c.mutex.Lock()
+	cached, ok := c.entries[key]
+	c.mutex.Unlock()
+	if ok && time.Now().Before(cached.expires) {
+		return cached.pubkey, cached.relays, nil
+	}
+
+	pubkey, relays, err = QueryIdentifier(ctx, c.Client, fullIdentifier)
+	if err != nil || c.TTL <= 0 {
+		return pubkey, relays, err
+	}
+
+	now := time.Now()
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if c.entries == nil {
+		c.entries = make(map[string]cachedIdentifier)
+	}
+	// expired entries are only dropped when the map has doubled since the
+	// last time, so misses don't go through all of them
+	if len(c.entries) >= c.nextPrune {
+		for k, e := range c.entries {
+			if now.After(e.expires) {
+				delete(c.entries, k)
+			}
+		}
+		c.nextPrune = 2*len(c.entries) + 64
+	}
+	c.entries[key] = cachedIdentifier{pubkey, relays, now.Add(c.TTL)}
+
+	return pubkey, relays, nil
+}
+
+// VerifyNIP05 is like the VerifyNIP05 function, but uses the cache.
+func (c *Cache) VerifyNIP05(ctx context.Context, fullIdentifier string, expectedPubkey string) (bool, error) {
+	pubkey, _, err := c.QueryIdentifier(ctx, fullIdentifier)
+	if err != nil {
+		return false, err
+	}
+
+	return strings.EqualFold(pubkey, expectedPubkey), nil
+}
+
+// ParseIdentifier splits "name@domain" into its lowercased parts, using "_"
+// as the name when there is no "@".
+func ParseIdentifier(fullIdentifier string) (name string, domain string) {
+	spl := strings.Split(strings.ToLower(strings.TrimSpace(fullIdentifier)), "@")
+	switch len(spl) {
+	case 1:
+		return "_", spl[0]
+	case 2:
+		return spl[0], spl[1]
+	default:
+		return "", ""
+	}
+}
+
+// validName checks that the local part only has the characters NIP-05 allows,
+// a-z, 0-9, "-", "_" and ".".
+func validName(name string) bool {
+	if name == "" {
+		return false
+	}
+	for _, c := range name {
+		if (c < 'a' || c > 'z') && (c < '0' || c > '9') && c != '-' && c != '_' && c != '.' {
+			return false
+		}
+	}
+	return true
+}
+
+// validDomain checks that the domain is a plain host name or IPv4 address,
+// optionally followed by a port, so nothing else ends up in the URL.
+func validDomain(domain string) bool {
+	host := domain
+	if i := strings.LastIndexByte(domain, ':'); i >= 0 {
+		host = domain[:i]
+		port := domain[i+1:]
+		if port == "" || len(port) > 5 || strings.Trim(port, "0123456789") != "" {
+			return false
+		}
+	}
+	if host == "" || len(host) > 253 || strings.HasPrefix(host, ".") || strings.HasPrefix(host, "-") {
+		return false
+	}
+	for _, c := range host {
+		if (c < 'a' || c > 'z') && (c < '0' || c > '9') && c != '-' && c != '.' {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,125 @@
+package nip05
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+const bobPubkey = "b0635d6a9851d3aed0cd6c495b282167acf761729078d975fc341b22650b07b9"
+
+func newWellKnownServer(t *testing.T) *httptest.Server {
+	return httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Query().Get("name") {
+		case "bob", "_":
+			json.NewEncoder(w).Encode(WellKnownResponse{
+				Names:  map[string]string{"bob": bobPubkey, "_": bobPubkey},
+				Relays: map[string][]string{bobPubkey: {"wss://relay.example.com"}},
+			})
+		case "moved":
+			http.Redirect(w, r, "/.well-known/nostr.json?name=bob", http.StatusFound)
+		case "broken":
+			w.Write([]byte("{not json"))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+}
+
+func TestQueryIdentifier(t *testing.T) {
+	server := newWellKnownServer(t)
+	defer server.Close()
+	domain := server.Listener.Addr().String()
+	ctx := context.Background()
+
+	pubkey, relays, err := QueryIdentifier(ctx, server.Client(), "Bob@"+domain)
+	if err != nil || pubkey != bobPubkey || len(relays) != 1 || relays[0] != "wss://relay.example.com" {
+		t.Errorf("wrong result: %s %v %v", pubkey, relays, err)
+	}
+	if ok, err := VerifyNIP05(ctx, server.Client(), domain, bobPubkey); !ok || err != nil {
+		t.Errorf("bare domain wasn't verified: %v", err)
+	}
+
+	for _, identifier := range []string{
+		"moved@" + domain,
+		"broken@" + domain,
+		"nobody@" + domain,
+	} {
+		if _, _, err := QueryIdentifier(ctx, server.Client(), identifier); err == nil {
+			t.Errorf("%s was resolved", identifier)
+		}
+	}
+}
+
+func TestParseIdentifierValidation(t *testing.T) {
+	for _, identifier := range []string{
+		"bob@example.com/evil",
+		"bob@example.com?x=y",
+		"bob@user:pass@example.com",
+		"bob@exa mple.com",
+		"b%20b@example.com",
+		"bob@.example.com",
+		"bob@example.com:",
+		"bob@",
+		"@example.com",
+	} {
+		name, domain := ParseIdentifier(identifier)
+		if validName(name) && validDomain(domain) {
+			t.Errorf("%s was accepted", identifier)
+		}
+		if _, _, err := QueryIdentifier(context.Background(), nil, identifier); err == nil {
+			t.Errorf("%s was queried", identifier)
+		}
+	}
+
+	for _, identifier := range []string{"bob@example.com", "a-b_c.d@sub.example.com", "example.com", "bob@127.0.0.1:8080"} {
+		name, domain := ParseIdentifier(identifier)
+		if !validName(name) || !validDomain(domain) {
+			t.Errorf("%s was rejected", identifier)
+		}
+	}
+}
+
+func TestCache(t *testing.T) {
+	var requests int32
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		json.NewEncoder(w).Encode(WellKnownResponse{Names: map[string]string{"bob": bobPubkey}})
+	}))
+	defer server.Close()
+	identifier := "bob@" + server.Listener.Addr().String()
+	ctx := context.Background()
+
+	cache := &Cache{TTL: time.Minute, Client: server.Client()}
+	for i := 0; i < 3; i++ {
+		if ok, err := cache.VerifyNIP05(ctx, identifier, bobPubkey); !ok || err != nil {
+			t.Fatalf("failed to verify: %v", err)
+		}
+	}
+	if n := atomic.LoadInt32(&requests); n != 1 {
+		t.Errorf("expected 1 request, got %d", n)
+	}
+
+	// caches don't share entries and the function isn't cached
+	other := &Cache{TTL: time.Minute, Client: server.Client()}
+	other.QueryIdentifier(ctx, identifier)
+	QueryIdentifier(ctx, server.Client(), identifier)
+	if n := atomic.LoadInt32(&requests); n != 3 {
+		t.Errorf("expected 3 requests, got %d", n)
+	}
+
+	expiring := &Cache{TTL: time.Nanosecond, Client: server.Client()}
+	expiring.QueryIdentifier(ctx, identifier)
+	time.Sleep(time.Millisecond)
+	expiring.QueryIdentifier(ctx, identifier)
+	if n := atomic.LoadInt32(&requests); n != 5 {
+		t.Errorf("expected the entry to expire, got %d requests", n)
+	}
+	if _, _, err := expiring.QueryIdentifier(ctx, "nobody@127.0.0.1:1"); err == nil {
+		t.Error("failed lookup returned no error")
+	}
+}
@@ -0,0 +1,42 @@
+package nip09
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/fiatjaf/go-nostr"
+)
+
+// MakeDeletion returns an unsigned kind-5 event requesting the deletion of the
+// given events, with the reason as content. All targets must have the same
+// author, since only the author can delete their events.
+func MakeDeletion(targets []*nostr.Event, reason string) (*nostr.Event, error) {
+	if len(targets) == 0 {
+		return nil, errors.New("no events to delete")
+	}
+
+	evt := nostr.NewEvent(nostr.KindDeletion, reason)
+	for _, target := range targets {
+		if target.PubKey != targets[0].PubKey {
+			return nil, fmt.Errorf("event %s is from %s, not %s", target.ID, target.PubKey, targets[0].PubKey)
+		}
+		evt.WithTag("e", target.ID)
+	}
+
+	return evt, nil
+}
+
+// DeletionTargets returns the ids of the events a deletion refers to.
+func DeletionTargets(evt *nostr.Event) []string {
+	if evt.Kind != nostr.KindDeletion {
+		return nil
+	}
+
+	ids := make([]string, 0, len(evt.Tags))
+	for _, tag := range evt.Tags.GetAll("e") {
+		if len(tag) >= 2 {
+			ids = append(ids, tag[1])
+		}
+	}
+	return ids
+}
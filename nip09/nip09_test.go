@@ -0,0 +1,38 @@
+package nip09
+
+import (
+	"testing"
+
+	"github.com/fiatjaf/go-nostr"
+)
+
+func TestDeletion(t *testing.T) {
+	sk := nostr.GeneratePrivateKey()
+	first, _ := nostr.NewEvent(nostr.KindTextNote, "first").SignWith(sk)
+	second, _ := nostr.NewEvent(nostr.KindTextNote, "second").SignWith(sk)
+
+	deletion, err := MakeDeletion([]*nostr.Event{first, second}, "oops")
+	if err != nil {
+		t.Fatalf("failed to make deletion: %v", err)
+	}
+	if deletion.Kind != nostr.KindDeletion || deletion.Content != "oops" {
+		t.Errorf("wrong deletion: %v", deletion)
+	}
+	if ids := DeletionTargets(deletion); len(ids) != 2 || ids[0] != first.ID || ids[1] != second.ID {
+		t.Errorf("wrong targets: %v", ids)
+	}
+
+	// only the author can delete their events
+	other, _ := nostr.NewEvent(nostr.KindTextNote, "other").SignWith(nostr.GeneratePrivateKey())
+	if _, err := MakeDeletion([]*nostr.Event{first, other}, ""); err == nil {
+		t.Error("deletion of another author's event was made")
+	}
+	if _, err := MakeDeletion(nil, ""); err == nil {
+		t.Error("deletion without targets was made")
+	}
+
+	note := nostr.NewEvent(nostr.KindTextNote, "").WithTag("e", first.ID)
+	if ids := DeletionTargets(note); ids != nil {
+		t.Errorf("a text note has deletion targets: %v", ids)
+	}
+}
@@ -0,0 +1,67 @@
+package nip10
+
+import (
+	"github.com/fiatjaf/go-nostr"
+)
+
+// SetReply adds the NIP-10 marked "e" tags that make evt a reply to parent,
+// in the thread started by root, plus a "p" tag for the parent author. If
+// root is nil or the same as parent, evt is a direct reply to the root.
+func SetReply(evt *nostr.Event, parent *nostr.Event, root *nostr.Event) {
+	if root == nil || root.ID == parent.ID {
+		evt.Tags = append(evt.Tags, nostr.Tag{"e", parent.ID, "", "root"})
+	} else {
+		evt.Tags = append(evt.Tags,
+			nostr.Tag{"e", root.ID, "", "root"},
+			nostr.Tag{"e", parent.ID, "", "reply"},
+		)
+	}
+
+	if !evt.Tags.ContainsAny("p", parent.PubKey) {
+		evt.Tags = append(evt.Tags, nostr.Tag{"p", parent.PubKey})
+	}
+}
+
+// GetThread returns the ids of the thread root and of the event evt directly
+// replies to, which are the same for direct replies to the root. Both are nil
+// if evt isn't a reply. Marked "e" tags are preferred, otherwise the
+// deprecated positional scheme is used: the first "e" tag is the root and the
+// last is the reply.
+func GetThread(evt *nostr.Event) (root *string, reply *string) {
+	var first, last *string
+	for _, tag := range evt.Tags {
+		if len(tag) < 2 || tag[0] != "e" {
+			continue
+		}
+
+		id := tag[1]
+		if len(tag) >= 4 {
+			switch tag[3] {
+			case "root":
+				root = &id
+				continue
+			case "reply":
+				reply = &id
+				continue
+			case "mention":
+				continue
+			}
+		}
+
+		if first == nil {
+			first = &id
+		}
+		last = &id
+	}
+
+	if root != nil || reply != nil {
+		if reply == nil {
+			reply = root
+		} else if root == nil {
+			root = reply
+		}
+		return root, reply
+	}
+
+	return first, last
+}
@@ -0,0 +1,61 @@
+package nip10
+
+import (
+	"testing"
+
+	"github.com/fiatjaf/go-nostr"
+)
+
+func note(id string, pubkey string) *nostr.Event {
+	evt := nostr.NewEvent(nostr.KindTextNote, "")
+	evt.ID, evt.PubKey = id, pubkey
+	return evt
+}
+
+func TestSetReply(t *testing.T) {
+	root, parent := note("root", "alice"), note("parent", "bob")
+
+	direct := nostr.NewEvent(nostr.KindTextNote, "")
+	SetReply(direct, root, nil)
+	if r, p := GetThread(direct); r == nil || *r != "root" || p == nil || *p != "root" {
+		t.Errorf("wrong thread for a direct reply: %v %v", r, p)
+	}
+	if len(direct.Tags) != 2 || direct.Tags[0][3] != "root" || !direct.Tags.ContainsAny("p", "alice") {
+		t.Errorf("wrong tags for a direct reply: %v", direct.Tags)
+	}
+
+	nested := nostr.NewEvent(nostr.KindTextNote, "").WithTag("p", "bob")
+	SetReply(nested, parent, root)
+	if r, p := GetThread(nested); r == nil || *r != "root" || p == nil || *p != "parent" {
+		t.Errorf("wrong thread for a nested reply: %v %v", r, p)
+	}
+	if len(nested.Tags.GetAll("p")) != 1 {
+		t.Errorf("parent author was tagged twice: %v", nested.Tags)
+	}
+}
+
+func TestGetThread(t *testing.T) {
+	for _, c := range []struct {
+		name  string
+		tags  nostr.Tags
+		root  string
+		reply string
+	}{
+		{"marked", nostr.Tags{{"e", "m", "", "mention"}, {"e", "r", "", "root"}, {"e", "p", "", "reply"}}, "r", "p"},
+		{"only root marked", nostr.Tags{{"e", "r", "", "root"}, {"e", "x"}}, "r", "r"},
+		{"only reply marked", nostr.Tags{{"e", "p", "wss://relay.example.com", "reply"}}, "p", "p"},
+		{"positional", nostr.Tags{{"e", "r"}, {"e", "m"}, {"e", "p"}}, "r", "p"},
+		{"positional single", nostr.Tags{{"e", "r"}, {"p", "alice"}}, "r", "r"},
+	} {
+		evt := nostr.NewEvent(nostr.KindTextNote, "")
+		evt.Tags = c.tags
+		root, reply := GetThread(evt)
+		if root == nil || reply == nil || *root != c.root || *reply != c.reply {
+			t.Errorf("%s: wrong thread %v %v", c.name, root, reply)
+		}
+	}
+
+	if root, reply := GetThread(nostr.NewEvent(nostr.KindTextNote, "").WithTag("p", "alice")); root != nil || reply != nil {
+		t.Error("a note that isn't a reply has a thread")
+	}
+}
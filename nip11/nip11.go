@@ -0,0 +1,85 @@
+package nip11
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// HTTPClient is used for all relay information requests, replace it to
+// change timeouts or transport settings.
+var HTTPClient = &http.Client{Timeout: 10 * time.Second}
+
+type RelayInformation struct {
+	Name          string `json:"name"`
+	Description   string `json:"description"`
+	PubKey        string `json:"pubkey"`
+	Contact       string `json:"contact"`
+	SupportedNIPs []int  `json:"supported_nips"`
+	Software      string `json:"software"`
+	Version       string `json:"version"`
+
+	Limitation RelayLimitation `json:"limitation"`
+}
+
+type RelayLimitation struct {
+	MaxMessageLength int  `json:"max_message_length,omitempty"`
+	MaxSubscriptions int  `json:"max_subscriptions,omitempty"`
+	MaxEventTags     int  `json:"max_event_tags,omitempty"`
+	MaxContentLength int  `json:"max_content_length,omitempty"`
+	AuthRequired     bool `json:"auth_required"`
+	PaymentRequired  bool `json:"payment_required"`
+}
+
+// FetchRelayInfo gets the information document of a relay. The relay URL
+// can use either the ws(s):// or the http(s):// scheme.
+func FetchRelayInfo(ctx context.Context, relayURL string) (*RelayInformation, error) {
+	return FetchRelayInfoWithClient(ctx, HTTPClient, relayURL, nil)
+}
+
+// FetchRelayInfoWithClient is like FetchRelayInfo, but makes the request with
+// client, which is HTTPClient if nil, adding the given headers.
+func FetchRelayInfoWithClient(ctx context.Context, client *http.Client, relayURL string, header http.Header) (*RelayInformation, error) {
+	if client == nil {
+		client = HTTPClient
+	}
+
+	u := relayURL
+	switch {
+	case strings.HasPrefix(strings.ToLower(u), "ws://"):
+		u = "http://" + u[5:]
+	case strings.HasPrefix(strings.ToLower(u), "wss://"):
+		u = "https://" + u[6:]
+	case !strings.HasPrefix(strings.ToLower(u), "http"):
+		u = "https://" + u
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "GET", u, nil)
+	if err != nil {
+		return nil, fmt.Errorf("invalid relay URL '%s': %w", relayURL, err)
+	}
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	req.Header.Set("Accept", "application/nostr+json")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch relay information from %s: %w", u, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != 200 {
+		return nil, fmt.Errorf("%s returned status %d", u, resp.StatusCode)
+	}
+
+	var info RelayInformation
+	if err := json.NewDecoder(resp.Body).Decode(&info); err != nil {
+		return nil, fmt.Errorf("failed to decode relay information from %s: %w", u, err)
+	}
+
+	return &info, nil
+}
@@ -0,0 +1,78 @@
+package nip11
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func infoHandler(t *testing.T) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if accept := r.Header.Get("Accept"); accept != "application/nostr+json" {
+			t.Errorf("wrong Accept header '%s'", accept)
+			http.Error(w, "not a nip11 request", http.StatusBadRequest)
+			return
+		}
+		json.NewEncoder(w).Encode(RelayInformation{
+			Name:          "test relay",
+			SupportedNIPs: []int{1, 11},
+			Limitation:    RelayLimitation{MaxMessageLength: 65536, AuthRequired: true},
+			Software:      r.Header.Get("X-Test"),
+		})
+	})
+}
+
+func TestFetchRelayInfo(t *testing.T) {
+	ctx := context.Background()
+
+	plain := httptest.NewServer(infoHandler(t))
+	defer plain.Close()
+	secure := httptest.NewTLSServer(infoHandler(t))
+	defer secure.Close()
+	plainHost := strings.TrimPrefix(plain.URL, "http://")
+	secureHost := strings.TrimPrefix(secure.URL, "https://")
+
+	for _, c := range []struct {
+		client *http.Client
+		url    string
+	}{
+		{plain.Client(), "ws://" + plainHost},
+		{plain.Client(), "WS://" + plainHost},
+		{plain.Client(), plain.URL},
+		{secure.Client(), "wss://" + secureHost},
+		{secure.Client(), secure.URL},
+		{secure.Client(), secureHost},
+	} {
+		info, err := FetchRelayInfoWithClient(ctx, c.client, c.url, http.Header{"X-Test": {"passed"}})
+		if err != nil {
+			t.Errorf("failed to fetch %s: %v", c.url, err)
+			continue
+		}
+		if info.Name != "test relay" || len(info.SupportedNIPs) != 2 || info.Limitation.MaxMessageLength != 65536 ||
+			!info.Limitation.AuthRequired || info.Software != "passed" {
+			t.Errorf("wrong information from %s: %v", c.url, info)
+		}
+	}
+
+	// the plain server isn't reached with https
+	if _, err := FetchRelayInfoWithClient(ctx, plain.Client(), "wss://"+plainHost, nil); err == nil {
+		t.Error("wss:// URL wasn't fetched with https")
+	}
+
+	broken := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/missing" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte("{not json"))
+	}))
+	defer broken.Close()
+	for _, url := range []string{broken.URL, broken.URL + "/missing"} {
+		if _, err := FetchRelayInfoWithClient(ctx, broken.Client(), url, nil); err == nil {
+			t.Errorf("%s returned information", url)
+		}
+	}
+}
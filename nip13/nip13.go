@@ -0,0 +1,22 @@
+package nip13
+
+import (
+	"context"
+
+	"github.com/fiatjaf/go-nostr"
+)
+
+// Difficulty counts the number of leading zero bits in a hex event id, it is
+// the same as nostr.Difficulty.
+func Difficulty(id string) int {
+	return nostr.Difficulty(id)
+}
+
+// Generate sets a ["nonce", "<n>", "<difficulty>"] tag on the event and
+// increments it until the event id has at least the given number of leading
+// zero bits. Only the nonce tag is changed, so the event must be signed
+// afterwards. Returns ctx.Err() if the context is done before that. It is the
+// same as evt.GenerateProofOfWork and evt.Mine, see also evt.MineThenSign.
+func Generate(ctx context.Context, evt *nostr.Event, difficulty int) error {
+	return evt.Mine(ctx, difficulty)
+}
@@ -0,0 +1,32 @@
+package nip14
+
+import (
+	"strings"
+
+	"github.com/fiatjaf/go-nostr"
+)
+
+// SetSubject sets the "subject" tag, used by some clients as the title of a
+// thread, replacing any previous one.
+func SetSubject(evt *nostr.Event, subject string) {
+	evt.SetTagValue("subject", subject)
+}
+
+// Subject returns the value of the "subject" tag, ok is false if there is
+// none.
+func Subject(evt *nostr.Event) (subject string, ok bool) {
+	return evt.GetTagValue("subject")
+}
+
+// InheritSubject sets the subject of a reply to the one of parent, prefixed
+// with "Re: " unless it already is. It does nothing if parent has no subject.
+func InheritSubject(evt *nostr.Event, parent *nostr.Event) {
+	subject, ok := Subject(parent)
+	if !ok {
+		return
+	}
+	if !strings.HasPrefix(strings.ToLower(subject), "re:") {
+		subject = "Re: " + subject
+	}
+	SetSubject(evt, subject)
+}
@@ -0,0 +1,32 @@
+package nip14
+
+import (
+	"testing"
+
+	"github.com/fiatjaf/go-nostr"
+)
+
+func TestSubject(t *testing.T) {
+	parent := nostr.NewEvent(nostr.KindTextNote, "hello").WithTag("subject", "old")
+	SetSubject(parent, "Meetup")
+	if subject, ok := Subject(parent); !ok || subject != "Meetup" || len(parent.Tags) != 1 {
+		t.Errorf("subject wasn't replaced: %v", parent.Tags)
+	}
+
+	reply := nostr.NewEvent(nostr.KindTextNote, "count me in")
+	InheritSubject(reply, parent)
+	if subject, _ := Subject(reply); subject != "Re: Meetup" {
+		t.Errorf("wrong reply subject: %s", subject)
+	}
+	again := nostr.NewEvent(nostr.KindTextNote, "me too")
+	InheritSubject(again, reply)
+	if subject, _ := Subject(again); subject != "Re: Meetup" {
+		t.Errorf("reply subject was prefixed twice: %s", subject)
+	}
+
+	orphan := nostr.NewEvent(nostr.KindTextNote, "")
+	InheritSubject(orphan, nostr.NewEvent(nostr.KindTextNote, ""))
+	if _, ok := Subject(orphan); ok {
+		t.Error("subject inherited from a parent without one")
+	}
+}
@@ -0,0 +1,66 @@
+package nip18
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+
+	"github.com/fiatjaf/go-nostr"
+)
+
+// ErrRepostWithoutContent is returned by RepostedEvent when the repost only
+// references the original event by its "e" tag, so it must be fetched.
+var ErrRepostWithoutContent = errors.New("repost doesn't embed the reposted event")
+
+// MakeRepost returns an unsigned repost of target, relay is where target can
+// be found. Text notes are reposted with a kind-6 event, anything else with a
+// kind-16 generic repost carrying a "k" tag with the original kind.
+func MakeRepost(target *nostr.Event, relay string) *nostr.Event {
+	content, _ := json.Marshal(target)
+
+	kind := nostr.KindRepost
+	if target.Kind != nostr.KindTextNote {
+		kind = nostr.KindGenericRepost
+	}
+
+	evt := nostr.NewEvent(kind, string(content)).
+		WithTag("e", target.ID, relay).
+		WithTag("p", target.PubKey)
+
+	if kind == nostr.KindGenericRepost {
+		evt.WithTag("k", strconv.Itoa(target.Kind))
+		if address, err := target.Address(); err == nil {
+			evt.WithTag("a", address, relay)
+		}
+	}
+
+	return evt
+}
+
+// RepostedEvent parses the event embedded in a repost and checks it is
+// validly signed and it is the one referenced by the "e" tag. If the repost
+// has no content ErrRepostWithoutContent is returned.
+func RepostedEvent(evt *nostr.Event) (*nostr.Event, error) {
+	if evt.Kind != nostr.KindRepost && evt.Kind != nostr.KindGenericRepost {
+		return nil, fmt.Errorf("event kind %d is not a repost", evt.Kind)
+	}
+
+	if evt.Content == "" {
+		return nil, ErrRepostWithoutContent
+	}
+
+	var reposted nostr.Event
+	if err := json.Unmarshal([]byte(evt.Content), &reposted); err != nil {
+		return nil, fmt.Errorf("invalid reposted event: %w", err)
+	}
+	if err := reposted.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid reposted event: %w", err)
+	}
+
+	if tag := evt.Tags.GetFirst("e"); tag != nil && len(*tag) >= 2 && (*tag)[1] != reposted.ID {
+		return nil, fmt.Errorf("reposted event id doesn't match the \"e\" tag")
+	}
+
+	return &reposted, nil
+}
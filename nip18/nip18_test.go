@@ -0,0 +1,31 @@
+package nip18
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/fiatjaf/go-nostr"
+)
+
+func TestRepost(t *testing.T) {
+	sk := nostr.GeneratePrivateKey()
+	note, _ := nostr.NewEvent(nostr.KindTextNote, "hello").SignWith(sk)
+
+	repost := MakeRepost(note, "wss://relay.example.com")
+	if repost.Kind != nostr.KindRepost {
+		t.Errorf("text notes should be reposted with kind %d, not %d", nostr.KindRepost, repost.Kind)
+	}
+	if reposted, err := RepostedEvent(repost); err != nil || reposted.ID != note.ID {
+		t.Errorf("failed to get the reposted event: %v", err)
+	}
+
+	reaction, _ := nostr.NewEvent(nostr.KindReaction, "+").SignWith(sk)
+	if generic := MakeRepost(reaction, ""); generic.Kind != nostr.KindGenericRepost || !generic.Tags.ContainsAny("k", "7") {
+		t.Errorf("wrong generic repost: %v", generic)
+	}
+
+	repost.Content = ""
+	if _, err := RepostedEvent(repost); !errors.Is(err, ErrRepostWithoutContent) {
+		t.Errorf("expected ErrRepostWithoutContent, got %v", err)
+	}
+}
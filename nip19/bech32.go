@@ -0,0 +1,133 @@
+package nip19
+
+import (
+	"fmt"
+	"strings"
+)
+
+// bip-173 bech32, without the 90 characters limit since TLV entities can be
+// much longer than that.
+
+const charset = "qpzry9x8gf2tvdw0s3jn54khce6mua7l"
+
+var generator = [5]uint32{0x3b6a57b2, 0x26508e6d, 0x1ea119fa, 0x3d4233dd, 0x2a1462b3}
+
+func polymod(values []byte) uint32 {
+	chk := uint32(1)
+	for _, v := range values {
+		top := chk >> 25
+		chk = (chk&0x1ffffff)<<5 ^ uint32(v)
+		for i := 0; i < 5; i++ {
+			if (top>>uint(i))&1 == 1 {
+				chk ^= generator[i]
+			}
+		}
+	}
+	return chk
+}
+
+func hrpExpand(hrp string) []byte {
+	ret := make([]byte, 0, len(hrp)*2+1)
+	for i := 0; i < len(hrp); i++ {
+		ret = append(ret, hrp[i]>>5)
+	}
+	ret = append(ret, 0)
+	for i := 0; i < len(hrp); i++ {
+		ret = append(ret, hrp[i]&31)
+	}
+	return ret
+}
+
+func createChecksum(hrp string, data []byte) []byte {
+	values := append(hrpExpand(hrp), data...)
+	values = append(values, 0, 0, 0, 0, 0, 0)
+	mod := polymod(values) ^ 1
+	ret := make([]byte, 6)
+	for i := 0; i < 6; i++ {
+		ret[i] = byte((mod >> uint(5*(5-i))) & 31)
+	}
+	return ret
+}
+
+func bech32Encode(hrp string, data []byte) (string, error) {
+	values, err := convertBits(data, 8, 5, true)
+	if err != nil {
+		return "", err
+	}
+
+	var b strings.Builder
+	b.Grow(len(hrp) + 1 + len(values) + 6)
+	b.WriteString(hrp)
+	b.WriteByte('1')
+	for _, v := range append(values, createChecksum(hrp, values)...) {
+		b.WriteByte(charset[v])
+	}
+	return b.String(), nil
+}
+
+func bech32Decode(code string) (hrp string, data []byte, err error) {
+	if strings.ToLower(code) != code && strings.ToUpper(code) != code {
+		return "", nil, fmt.Errorf("mixed case in '%s'", code)
+	}
+	code = strings.ToLower(code)
+
+	pos := strings.LastIndexByte(code, '1')
+	if pos < 1 || pos+7 > len(code) {
+		return "", nil, fmt.Errorf("invalid separator position in '%s'", code)
+	}
+
+	hrp = code[0:pos]
+	for i := 0; i < len(hrp); i++ {
+		if hrp[i] < 33 || hrp[i] > 126 {
+			return "", nil, fmt.Errorf("invalid character in prefix '%s'", hrp)
+		}
+	}
+
+	values := make([]byte, 0, len(code)-pos-1)
+	for i := pos + 1; i < len(code); i++ {
+		v := strings.IndexByte(charset, code[i])
+		if v == -1 {
+			return "", nil, fmt.Errorf("invalid character '%c'", code[i])
+		}
+		values = append(values, byte(v))
+	}
+
+	if polymod(append(hrpExpand(hrp), values...)) != 1 {
+		return "", nil, fmt.Errorf("invalid checksum")
+	}
+
+	data, err = convertBits(values[:len(values)-6], 5, 8, false)
+	if err != nil {
+		return "", nil, err
+	}
+
+	return hrp, data, nil
+}
+
+func convertBits(data []byte, fromBits, toBits uint, pad bool) ([]byte, error) {
+	acc := uint32(0)
+	bits := uint(0)
+	maxv := uint32(1)<<toBits - 1
+	ret := make([]byte, 0, len(data)*int(fromBits)/int(toBits)+1)
+	for _, value := range data {
+		if uint32(value)>>fromBits != 0 {
+			return nil, fmt.Errorf("invalid data range: %d", value)
+		}
+		acc = acc<<fromBits | uint32(value)
+		bits += fromBits
+		for bits >= toBits {
+			bits -= toBits
+			ret = append(ret, byte(acc>>bits&maxv))
+		}
+	}
+
+	if pad {
+		if bits > 0 {
+			ret = append(ret, byte(acc<<(toBits-bits)&maxv))
+		}
+	} else if bits >= fromBits || acc<<(toBits-bits)&maxv != 0 {
+		return nil, fmt.Errorf("invalid padding")
+	}
+
+	return ret, nil
+}
@@ -0,0 +1,64 @@
+package nip19
+
+import (
+	"encoding/hex"
+	"fmt"
+)
+
+// EncodePublicKey encodes a hex public key as an "npub1..." string.
+func EncodePublicKey(publicKeyHex string) (string, error) {
+	return encodeHex32("npub", "key", publicKeyHex)
+}
+
+// EncodePrivateKey encodes a hex private key as an "nsec1..." string.
+func EncodePrivateKey(privateKeyHex string) (string, error) {
+	return encodeHex32("nsec", "key", privateKeyHex)
+}
+
+// EncodeNote encodes a hex event id as a "note1..." string.
+func EncodeNote(eventIDHex string) (string, error) {
+	return encodeHex32("note", "event id", eventIDHex)
+}
+
+// Decode decodes a bech32 "npub1...", "nsec1..." or "note1..." string,
+// returning its prefix and the value as lowercase hex.
+func Decode(bech32string string) (prefix string, value string, err error) {
+	prefix, data, err := bech32Decode(bech32string)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to decode '%s': %w", bech32string, err)
+	}
+
+	switch prefix {
+	case "npub", "nsec", "note":
+		if len(data) != 32 {
+			return "", "", fmt.Errorf("data for '%s' must be 32 bytes, not %d", prefix, len(data))
+		}
+		return prefix, hex.EncodeToString(data), nil
+	default:
+		return "", "", fmt.Errorf("unknown prefix '%s'", prefix)
+	}
+}
+
+// EncodeBech32 encodes arbitrary data as bech32 with the given prefix, without
+// the 90 characters limit, for strings that aren't NIP-19 entities like LNURLs.
+func EncodeBech32(prefix string, data []byte) (string, error) {
+	return bech32Encode(prefix, data)
+}
+
+// DecodeBech32 decodes any bech32 string, returning its lowercase prefix and
+// raw data, see EncodeBech32.
+func DecodeBech32(code string) (prefix string, data []byte, err error) {
+	return bech32Decode(code)
+}
+
+func encodeHex32(prefix string, name string, valueHex string) (string, error) {
+	b, err := hex.DecodeString(valueHex)
+	if err != nil {
+		return "", fmt.Errorf("failed to decode %s hex: %w", name, err)
+	}
+	if len(b) != 32 {
+		return "", fmt.Errorf("%s must be 32 bytes, not %d", name, len(b))
+	}
+
+	return bech32Encode(prefix, b)
+}
@@ -0,0 +1,71 @@
+package nip19
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestEncodeDecodeKeys(t *testing.T) {
+	pk := "3bf0c63fcb93463407af97a5e5ee64fa883d107ef9e558472c4eb9aaaefa459d"
+	npub, err := EncodePublicKey(pk)
+	if err != nil {
+		t.Fatalf("failed to encode public key: %v", err)
+	}
+	if npub != "npub180cvv07tjdrrgpa0j7j7tmnyl2yr6yr7l8j4s3evf6u64th6gkwsyjh6w6" {
+		t.Errorf("wrong npub: %s", npub)
+	}
+
+	prefix, value, err := Decode(npub)
+	if err != nil || prefix != "npub" || value != pk {
+		t.Errorf("failed to decode npub: %s %s %v", prefix, value, err)
+	}
+
+	sk := "67dea2ed018072d675f5415ecfaed7d2597555e202d85b3d65ea4e58d2d92ffa"
+	nsec, err := EncodePrivateKey(sk)
+	if err != nil {
+		t.Fatalf("failed to encode private key: %v", err)
+	}
+	if prefix, value, err := Decode(nsec); err != nil || prefix != "nsec" || value != sk {
+		t.Errorf("failed to decode nsec: %s %s %v", prefix, value, err)
+	}
+
+	if _, err := EncodePublicKey(pk[2:]); err == nil {
+		t.Error("encoded a key with the wrong length")
+	}
+	if _, _, err := Decode(npub[:len(npub)-1] + "q"); err == nil {
+		t.Error("decoded a string with a bad checksum")
+	}
+}
+
+func TestEncodeDecodePointers(t *testing.T) {
+	pk := "3bf0c63fcb93463407af97a5e5ee64fa883d107ef9e558472c4eb9aaaefa459d"
+
+	nprofile, err := EncodeProfile(pk, []string{"wss://r.x.com", "wss://djbas.sadkb.com"})
+	if err != nil {
+		t.Fatalf("failed to encode nprofile: %v", err)
+	}
+	if nprofile != "nprofile1qqsrhuxx8l9ex335q7he0f09aej04zpazpl0ne2cgukyawd24mayt8gpp4mhxue69uhhytnc9e3k7mgpz4mhxue69uhkg6nzv9ejuumpv34kytnrdaksjlyr9p" {
+		t.Errorf("wrong nprofile: %s", nprofile)
+	}
+
+	pointers := []Pointer{
+		ProfilePointer{PublicKey: pk, Relays: []string{"wss://r.x.com", "wss://djbas.sadkb.com"}},
+		EventPointer{ID: pk, Relays: []string{"wss://a.com", "wss://b.com"}, Author: pk},
+		EntityPointer{PublicKey: pk, Kind: 30023, Identifier: "banana", Relays: []string{"wss://c.com"}},
+	}
+	for _, pointer := range pointers {
+		code, err := pointer.Encode()
+		if err != nil {
+			t.Fatalf("failed to encode %v: %v", pointer, err)
+		}
+
+		decoded, err := DecodePointer(code)
+		if err != nil {
+			t.Fatalf("failed to decode %s: %v", code, err)
+		}
+
+		if fmt.Sprint(decoded) != fmt.Sprint(pointer) {
+			t.Errorf("round-trip failed: %v != %v", decoded, pointer)
+		}
+	}
+}
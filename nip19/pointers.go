@@ -0,0 +1,252 @@
+package nip19
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"math"
+)
+
+const (
+	TLVDefault uint8 = 0
+	TLVRelay   uint8 = 1
+	TLVAuthor  uint8 = 2
+	TLVKind    uint8 = 3
+)
+
+// Pointer is one of ProfilePointer, EventPointer or EntityPointer.
+type Pointer interface {
+	Encode() (string, error)
+}
+
+// ProfilePointer is what is encoded in an "nprofile1..." (or "npub1...") string.
+type ProfilePointer struct {
+	PublicKey string
+	Relays    []string
+}
+
+// EventPointer is what is encoded in an "nevent1..." (or "note1...") string.
+type EventPointer struct {
+	ID     string
+	Relays []string
+	Author string
+}
+
+// EntityPointer is what is encoded in an "naddr1..." string.
+type EntityPointer struct {
+	PublicKey  string
+	Kind       int
+	Identifier string
+	Relays     []string
+}
+
+func (p ProfilePointer) Encode() (string, error) { return EncodeProfile(p.PublicKey, p.Relays) }
+func (p EventPointer) Encode() (string, error)   { return EncodeEvent(p.ID, p.Relays, p.Author) }
+func (p EntityPointer) Encode() (string, error) {
+	return EncodeAddr(p.Identifier, p.PublicKey, p.Kind, p.Relays)
+}
+
+// EncodeProfile encodes a public key and relay hints as an "nprofile1..." string.
+func EncodeProfile(publicKeyHex string, relays []string) (string, error) {
+	buf := &bytes.Buffer{}
+	if err := writeHexTLV(buf, TLVDefault, publicKeyHex); err != nil {
+		return "", fmt.Errorf("invalid pubkey: %w", err)
+	}
+	if err := writeRelaysTLV(buf, relays); err != nil {
+		return "", err
+	}
+
+	return bech32Encode("nprofile", buf.Bytes())
+}
+
+// EncodeEvent encodes an event id, relay hints and an optional author as an
+// "nevent1..." string.
+func EncodeEvent(eventIDHex string, relays []string, author string) (string, error) {
+	buf := &bytes.Buffer{}
+	if err := writeHexTLV(buf, TLVDefault, eventIDHex); err != nil {
+		return "", fmt.Errorf("invalid id: %w", err)
+	}
+	if err := writeRelaysTLV(buf, relays); err != nil {
+		return "", err
+	}
+	if author != "" {
+		if err := writeHexTLV(buf, TLVAuthor, author); err != nil {
+			return "", fmt.Errorf("invalid author: %w", err)
+		}
+	}
+
+	return bech32Encode("nevent", buf.Bytes())
+}
+
+// EncodeAddr encodes the coordinates of a parameterized replaceable event and
+// relay hints as an "naddr1..." string.
+func EncodeAddr(identifier string, publicKeyHex string, kind int, relays []string) (string, error) {
+	buf := &bytes.Buffer{}
+	if err := writeTLV(buf, TLVDefault, []byte(identifier)); err != nil {
+		return "", fmt.Errorf("invalid identifier: %w", err)
+	}
+	if err := writeRelaysTLV(buf, relays); err != nil {
+		return "", err
+	}
+	if err := writeHexTLV(buf, TLVAuthor, publicKeyHex); err != nil {
+		return "", fmt.Errorf("invalid pubkey: %w", err)
+	}
+	if kind < 0 || uint64(kind) > math.MaxUint32 {
+		return "", fmt.Errorf("invalid kind %d", kind)
+	}
+	kindBytes := make([]byte, 4)
+	binary.BigEndian.PutUint32(kindBytes, uint32(kind))
+	writeTLV(buf, TLVKind, kindBytes)
+
+	return bech32Encode("naddr", buf.Bytes())
+}
+
+// DecodePointer decodes an "nprofile1...", "nevent1...", "naddr1...",
+// "npub1..." or "note1..." string into the corresponding Pointer.
+func DecodePointer(code string) (Pointer, error) {
+	prefix, data, err := bech32Decode(code)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode '%s': %w", code, err)
+	}
+
+	switch prefix {
+	case "npub":
+		if len(data) != 32 {
+			return nil, fmt.Errorf("pubkey must be 32 bytes, not %d", len(data))
+		}
+		return ProfilePointer{PublicKey: hex.EncodeToString(data)}, nil
+	case "note":
+		if len(data) != 32 {
+			return nil, fmt.Errorf("id must be 32 bytes, not %d", len(data))
+		}
+		return EventPointer{ID: hex.EncodeToString(data)}, nil
+	case "nprofile":
+		var result ProfilePointer
+		err := readTLVs(data, func(t uint8, v []byte) error {
+			switch t {
+			case TLVDefault:
+				if len(v) != 32 {
+					return fmt.Errorf("pubkey must be 32 bytes, not %d", len(v))
+				}
+				result.PublicKey = hex.EncodeToString(v)
+			case TLVRelay:
+				result.Relays = append(result.Relays, string(v))
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+		if result.PublicKey == "" {
+			return nil, fmt.Errorf("no pubkey found for nprofile")
+		}
+		return result, nil
+	case "nevent":
+		var result EventPointer
+		err := readTLVs(data, func(t uint8, v []byte) error {
+			switch t {
+			case TLVDefault:
+				if len(v) != 32 {
+					return fmt.Errorf("id must be 32 bytes, not %d", len(v))
+				}
+				result.ID = hex.EncodeToString(v)
+			case TLVRelay:
+				result.Relays = append(result.Relays, string(v))
+			case TLVAuthor:
+				if len(v) != 32 {
+					return fmt.Errorf("author must be 32 bytes, not %d", len(v))
+				}
+				result.Author = hex.EncodeToString(v)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+		if result.ID == "" {
+			return nil, fmt.Errorf("no id found for nevent")
+		}
+		return result, nil
+	case "naddr":
+		var result EntityPointer
+		var hasKind, hasIdentifier bool
+		err := readTLVs(data, func(t uint8, v []byte) error {
+			switch t {
+			case TLVDefault:
+				result.Identifier = string(v)
+				hasIdentifier = true
+			case TLVRelay:
+				result.Relays = append(result.Relays, string(v))
+			case TLVAuthor:
+				if len(v) != 32 {
+					return fmt.Errorf("author must be 32 bytes, not %d", len(v))
+				}
+				result.PublicKey = hex.EncodeToString(v)
+			case TLVKind:
+				if len(v) != 4 {
+					return fmt.Errorf("kind must be 4 bytes, not %d", len(v))
+				}
+				result.Kind = int(binary.BigEndian.Uint32(v))
+				hasKind = true
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+		if !hasIdentifier || !hasKind || result.PublicKey == "" {
+			return nil, fmt.Errorf("incomplete naddr")
+		}
+		return result, nil
+	default:
+		return nil, fmt.Errorf("unknown prefix '%s'", prefix)
+	}
+}
+
+func readTLVs(data []byte, handle func(t uint8, v []byte) error) error {
+	for len(data) > 0 {
+		if len(data) < 2 {
+			return fmt.Errorf("truncated TLV entry")
+		}
+		t, l := data[0], int(data[1])
+		if len(data) < 2+l {
+			return fmt.Errorf("TLV entry of type %d is truncated", t)
+		}
+		if err := handle(t, data[2:2+l]); err != nil {
+			return err
+		}
+		data = data[2+l:]
+	}
+	return nil
+}
+
+func writeTLV(buf *bytes.Buffer, t uint8, v []byte) error {
+	if len(v) > 255 {
+		return fmt.Errorf("value of type %d is too long (%d bytes)", t, len(v))
+	}
+	buf.WriteByte(t)
+	buf.WriteByte(uint8(len(v)))
+	buf.Write(v)
+	return nil
+}
+
+func writeHexTLV(buf *bytes.Buffer, t uint8, v string) error {
+	b, err := hex.DecodeString(v)
+	if err != nil {
+		return err
+	}
+	if len(b) != 32 {
+		return fmt.Errorf("must be 32 bytes, not %d", len(b))
+	}
+	return writeTLV(buf, t, b)
+}
+
+func writeRelaysTLV(buf *bytes.Buffer, relays []string) error {
+	for _, relay := range relays {
+		if err := writeTLV(buf, TLVRelay, []byte(relay)); err != nil {
+			return fmt.Errorf("invalid relay '%s': %w", relay, err)
+		}
+	}
+	return nil
+}
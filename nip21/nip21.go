@@ -0,0 +1,38 @@
+package nip21
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/fiatjaf/go-nostr/nip19"
+)
+
+const scheme = "nostr:"
+
+// ParseURI decodes a "nostr:..." URI into the nip19.Pointer it references.
+// "nsec1..." keys are rejected, they must never be shared as URIs.
+func ParseURI(uri string) (nip19.Pointer, error) {
+	if len(uri) < len(scheme) || !strings.EqualFold(uri[:len(scheme)], scheme) {
+		return nil, fmt.Errorf("'%s' is not a nostr: URI", uri)
+	}
+
+	code := uri[len(scheme):]
+	if strings.HasPrefix(strings.ToLower(code), "nsec1") {
+		return nil, fmt.Errorf("nostr: URIs can't contain private keys")
+	}
+
+	pointer, err := nip19.DecodePointer(code)
+	if err != nil {
+		return nil, fmt.Errorf("invalid nostr: URI: %w", err)
+	}
+	return pointer, nil
+}
+
+// EncodeURI encodes a nip19.Pointer as a "nostr:..." URI.
+func EncodeURI(pointer nip19.Pointer) (string, error) {
+	code, err := pointer.Encode()
+	if err != nil {
+		return "", err
+	}
+	return scheme + code, nil
+}
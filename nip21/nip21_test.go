@@ -0,0 +1,55 @@
+package nip21
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/fiatjaf/go-nostr/nip19"
+)
+
+func TestURI(t *testing.T) {
+	pk := "3bf0c63fcb93463407af97a5e5ee64fa883d107ef9e558472c4eb9aaaefa459d"
+	for _, pointer := range []nip19.Pointer{
+		nip19.ProfilePointer{PublicKey: pk, Relays: []string{"wss://relay.example.com"}},
+		nip19.EventPointer{ID: pk, Relays: []string{"wss://relay.example.com"}, Author: pk},
+		nip19.EntityPointer{PublicKey: pk, Kind: 30023, Identifier: "article"},
+	} {
+		uri, err := EncodeURI(pointer)
+		if err != nil {
+			t.Fatalf("failed to encode %v: %v", pointer, err)
+		}
+		if !strings.HasPrefix(uri, "nostr:") {
+			t.Errorf("%s doesn't start with nostr:", uri)
+		}
+
+		decoded, err := ParseURI(uri)
+		if err != nil || !reflect.DeepEqual(decoded, pointer) {
+			t.Errorf("%s decoded to %v, not %v: %v", uri, decoded, pointer, err)
+		}
+		if decoded, err := ParseURI("NOSTR:" + strings.TrimPrefix(uri, "nostr:")); err != nil ||
+			!reflect.DeepEqual(decoded, pointer) {
+			t.Errorf("uppercase scheme wasn't accepted: %v", err)
+		}
+	}
+
+	npub, _ := nip19.EncodePublicKey(pk)
+	if decoded, err := ParseURI("nostr:" + npub); err != nil || !reflect.DeepEqual(decoded, nip19.ProfilePointer{PublicKey: pk}) {
+		t.Errorf("npub decoded to %v: %v", decoded, err)
+	}
+
+	nsec, _ := nip19.EncodePrivateKey("67dea2ed018072d675f5415ecfaed7d2597555e202d85b3d65ea4e58d2d92ffa")
+	for _, uri := range []string{
+		"nostr:" + nsec,
+		"nostr:" + strings.ToUpper(nsec),
+		npub,
+		"nostr",
+		"nostr:",
+		"nostr:npub1invalid",
+		"https://example.com",
+	} {
+		if pointer, err := ParseURI(uri); err == nil {
+			t.Errorf("%s was parsed as %v", uri, pointer)
+		}
+	}
+}
@@ -0,0 +1,112 @@
+package nip23
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/fiatjaf/go-nostr"
+)
+
+// Article is a NIP-23 long-form post, the content of kind-30023 events.
+type Article struct {
+	Title       string
+	Summary     string
+	Image       string
+	Content     string
+	PublishedAt time.Time
+	Hashtags    []string
+
+	// Identifier is the "d" tag, articles with the same one replace each other.
+	Identifier string
+}
+
+// ParseArticle reads a kind-30023 event.
+func ParseArticle(evt *nostr.Event) (*Article, error) {
+	if evt.Kind != nostr.KindArticle {
+		return nil, fmt.Errorf("event kind is %d, not %d", evt.Kind, nostr.KindArticle)
+	}
+
+	a := &Article{Content: evt.Content}
+	a.Identifier, _ = evt.GetTagValue("d")
+	for _, tag := range evt.Tags {
+		if len(tag) < 2 {
+			continue
+		}
+
+		switch tag[0] {
+		case "title":
+			a.Title = tag[1]
+		case "summary":
+			a.Summary = tag[1]
+		case "image":
+			a.Image = tag[1]
+		case "published_at":
+			ts, err := strconv.ParseInt(tag[1], 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("invalid published_at '%s'", tag[1])
+			}
+			a.PublishedAt = time.Unix(ts, 0)
+		case "t":
+			a.Hashtags = append(a.Hashtags, tag[1])
+		}
+	}
+
+	return a, nil
+}
+
+// ToEvent returns an unsigned kind-30023 event with the article. If it has no
+// Identifier one is made from the title or, if it has no letters or digits,
+// from a hash of the title and the content, so the same article always gets
+// the same one.
+func (a *Article) ToEvent() *nostr.Event {
+	identifier := a.Identifier
+	if identifier == "" {
+		identifier = slugify(a.Title)
+	}
+	if identifier == "" {
+		h := sha256.Sum256([]byte(a.Title + "\n" + a.Content))
+		identifier = hex.EncodeToString(h[:8])
+	}
+
+	evt := nostr.NewEvent(nostr.KindArticle, a.Content).WithTag("d", identifier)
+	if a.Title != "" {
+		evt.WithTag("title", a.Title)
+	}
+	if a.Summary != "" {
+		evt.WithTag("summary", a.Summary)
+	}
+	if a.Image != "" {
+		evt.WithTag("image", a.Image)
+	}
+	if !a.PublishedAt.IsZero() {
+		evt.WithTag("published_at", strconv.FormatInt(a.PublishedAt.Unix(), 10))
+	}
+	for _, hashtag := range a.Hashtags {
+		evt.WithTag("t", hashtag)
+	}
+
+	return evt
+}
+
+// slugify turns "Hello, World!" into "hello-world", it is empty if the title
+// has no letters or digits.
+func slugify(title string) string {
+	var b strings.Builder
+	dash := false
+	for _, r := range strings.ToLower(title) {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			b.WriteRune(r)
+			dash = false
+		} else if !dash && b.Len() > 0 {
+			b.WriteByte('-')
+			dash = true
+		}
+	}
+
+	return strings.TrimSuffix(b.String(), "-")
+}
@@ -0,0 +1,36 @@
+package nip23
+
+import (
+	"testing"
+	"time"
+)
+
+func TestArticle(t *testing.T) {
+	article := Article{
+		Title:       "Hello, World! Again",
+		Content:     "# hi",
+		PublishedAt: time.Unix(1700000000, 0),
+		Hashtags:    []string{"intro", "nostr"},
+	}
+
+	evt := article.ToEvent()
+	if d := evt.Tags.GetFirst("d"); d == nil || (*d)[1] != "hello-world-again" {
+		t.Errorf("wrong d tag: %v", d)
+	}
+
+	parsed, err := ParseArticle(evt)
+	if err != nil || parsed.Title != article.Title || parsed.Identifier != "hello-world-again" ||
+		!parsed.PublishedAt.Equal(article.PublishedAt) || len(parsed.Hashtags) != 2 {
+		t.Errorf("article didn't roundtrip: %v %v", parsed, err)
+	}
+
+	// without a usable title the identifier comes from the content
+	untitled := Article{Title: "!!!", Content: "# hi"}
+	d1, _ := untitled.ToEvent().GetTagValue("d")
+	d2, _ := untitled.ToEvent().GetTagValue("d")
+	untitled.Content = "# bye"
+	d3, _ := untitled.ToEvent().GetTagValue("d")
+	if d1 == "" || d1 != d2 || d1 == d3 {
+		t.Errorf("identifiers without title aren't deterministic: %s %s %s", d1, d2, d3)
+	}
+}
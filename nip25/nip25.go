@@ -0,0 +1,29 @@
+package nip25
+
+import (
+	"github.com/fiatjaf/go-nostr"
+)
+
+// MakeReaction returns an unsigned kind-7 event reacting to target with the
+// given content, which is "+" for a like, "-" for a dislike or an emoji.
+func MakeReaction(target *nostr.Event, content string) *nostr.Event {
+	return nostr.NewEvent(nostr.KindReaction, content).
+		WithTag("e", target.ID).
+		WithTag("p", target.PubKey)
+}
+
+// ReactionTarget returns the id and the author of the event a reaction
+// refers to, which are its last "e" and "p" tags.
+func ReactionTarget(evt *nostr.Event) (eventID string, pubkey string, ok bool) {
+	if evt.Kind != nostr.KindReaction {
+		return "", "", false
+	}
+
+	etag := evt.Tags.GetLast("e")
+	ptag := evt.Tags.GetLast("p")
+	if etag == nil || len(*etag) < 2 || ptag == nil || len(*ptag) < 2 {
+		return "", "", false
+	}
+
+	return (*etag)[1], (*ptag)[1], true
+}
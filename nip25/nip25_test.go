@@ -0,0 +1,38 @@
+package nip25
+
+import (
+	"testing"
+
+	"github.com/fiatjaf/go-nostr"
+)
+
+func TestReaction(t *testing.T) {
+	target := nostr.NewEvent(nostr.KindTextNote, "nice")
+	target.ID, target.PubKey = "abc", "alice"
+
+	reaction := MakeReaction(target, "+")
+	if reaction.Kind != nostr.KindReaction || reaction.Content != "+" {
+		t.Errorf("wrong reaction: %v", reaction)
+	}
+	if id, pubkey, ok := ReactionTarget(reaction); !ok || id != "abc" || pubkey != "alice" {
+		t.Errorf("wrong target: %s %s %v", id, pubkey, ok)
+	}
+
+	// the last tags are the reacted event, the others are its thread
+	threaded := nostr.NewEvent(nostr.KindReaction, "🤙").
+		WithTag("e", "root").WithTag("p", "bob").
+		WithTag("e", "abc").WithTag("p", "alice")
+	if id, pubkey, ok := ReactionTarget(threaded); !ok || id != "abc" || pubkey != "alice" {
+		t.Errorf("wrong target: %s %s %v", id, pubkey, ok)
+	}
+
+	for name, evt := range map[string]*nostr.Event{
+		"not a reaction": nostr.NewEvent(nostr.KindTextNote, "+").WithTag("e", "abc").WithTag("p", "alice"),
+		"no e tag":       nostr.NewEvent(nostr.KindReaction, "+").WithTag("p", "alice"),
+		"no p tag":       nostr.NewEvent(nostr.KindReaction, "+").WithTag("e", "abc"),
+	} {
+		if _, _, ok := ReactionTarget(evt); ok {
+			t.Errorf("%s: got a target", name)
+		}
+	}
+}
@@ -0,0 +1,151 @@
+package nip26
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/fiatjaf/bip340"
+	"github.com/fiatjaf/go-nostr"
+)
+
+// CreateDelegation returns a ["delegation", <delegator>, <conditions>, <sig>]
+// tag that allows delegateePubKey to publish events, under the given
+// conditions (like "kind=1&created_at>1674834236"), on behalf of the delegator.
+func CreateDelegation(delegatorPrivKey string, delegateePubKey string, conditions string) (nostr.Tag, error) {
+	delegator, err := nostr.GetPublicKey(delegatorPrivKey)
+	if err != nil {
+		return nil, fmt.Errorf("invalid delegator private key: %w", err)
+	}
+
+	s, err := bip340.ParsePrivateKey(delegatorPrivKey)
+	if err != nil {
+		return nil, fmt.Errorf("invalid delegator private key: %w", err)
+	}
+
+	if _, err := parseConditions(conditions); err != nil {
+		return nil, err
+	}
+
+	aux := make([]byte, 32)
+	if _, err := rand.Read(aux); err != nil {
+		return nil, fmt.Errorf("failed to read random bytes: %w", err)
+	}
+
+	sig, err := bip340.Sign(s, delegationToken(delegateePubKey, conditions), aux)
+	if err != nil {
+		return nil, err
+	}
+
+	return nostr.Tag{"delegation", delegator, conditions, hex.EncodeToString(sig[:])}, nil
+}
+
+// CheckDelegation looks for a delegation tag in the event, validates its
+// signature and checks if the event satisfies its conditions. If so the
+// delegator pubkey can be treated as the effective author of the event.
+// An error is only returned if the tag itself is malformed or has an invalid
+// signature, an event that doesn't satisfy the conditions only gets ok=false.
+func CheckDelegation(evt *nostr.Event) (delegator string, ok bool, err error) {
+	ptag := evt.Tags.GetFirst("delegation")
+	if ptag == nil {
+		return "", false, nil
+	}
+	tag := *ptag
+	if len(tag) != 4 {
+		return "", false, fmt.Errorf("delegation tag must have 4 items, not %d", len(tag))
+	}
+	delegator, conditions := tag[1], tag[2]
+
+	pubkey, err := bip340.ParsePublicKey(delegator)
+	if err != nil {
+		return "", false, fmt.Errorf("invalid delegator pubkey: %w", err)
+	}
+
+	s, err := hex.DecodeString(tag[3])
+	if err != nil {
+		return "", false, fmt.Errorf("delegation signature is invalid hex: %w", err)
+	}
+	if len(s) != 64 {
+		return "", false, fmt.Errorf("delegation signature must be 64 bytes, not %d", len(s))
+	}
+	var sig [64]byte
+	copy(sig[:], s)
+
+	valid, err := bip340.Verify(pubkey, delegationToken(evt.PubKey, conditions), sig)
+	if err != nil {
+		return "", false, fmt.Errorf("invalid delegation signature: %w", err)
+	}
+	if !valid {
+		return "", false, fmt.Errorf("invalid delegation signature")
+	}
+
+	conds, err := parseConditions(conditions)
+	if err != nil {
+		return "", false, err
+	}
+
+	return delegator, conds.matches(evt), nil
+}
+
+func delegationToken(delegateePubKey string, conditions string) [32]byte {
+	return sha256.Sum256([]byte("nostr:delegation:" + delegateePubKey + ":" + conditions))
+}
+
+type delegationConditions struct {
+	kinds  nostr.IntList
+	after  *int64
+	before *int64
+}
+
+func parseConditions(conditions string) (delegationConditions, error) {
+	var conds delegationConditions
+	if conditions == "" {
+		return conds, nil
+	}
+
+	for _, cond := range strings.Split(conditions, "&") {
+		switch {
+		case strings.HasPrefix(cond, "kind="):
+			kind, err := strconv.Atoi(cond[5:])
+			if err != nil {
+				return conds, fmt.Errorf("invalid condition '%s': %w", cond, err)
+			}
+			conds.kinds = append(conds.kinds, kind)
+		case strings.HasPrefix(cond, "created_at>"):
+			ts, err := strconv.ParseInt(cond[11:], 10, 64)
+			if err != nil {
+				return conds, fmt.Errorf("invalid condition '%s': %w", cond, err)
+			}
+			conds.after = &ts
+		case strings.HasPrefix(cond, "created_at<"):
+			ts, err := strconv.ParseInt(cond[11:], 10, 64)
+			if err != nil {
+				return conds, fmt.Errorf("invalid condition '%s': %w", cond, err)
+			}
+			conds.before = &ts
+		default:
+			return conds, fmt.Errorf("unsupported condition '%s'", cond)
+		}
+	}
+
+	return conds, nil
+}
+
+func (conds delegationConditions) matches(evt *nostr.Event) bool {
+	if conds.kinds != nil && !conds.kinds.Contains(evt.Kind) {
+		return false
+	}
+
+	createdAt := int64(evt.CreatedAt)
+	if conds.after != nil && createdAt <= *conds.after {
+		return false
+	}
+	if conds.before != nil && createdAt >= *conds.before {
+		return false
+	}
+
+	return true
+}
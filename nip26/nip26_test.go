@@ -0,0 +1,96 @@
+package nip26
+
+import (
+	"testing"
+
+	"github.com/fiatjaf/go-nostr"
+)
+
+// a delegation made by another implementation, for kinds 1 to 3 after
+// created_at 1600000000
+const (
+	delegatorPrivKey = "3f0c63fcb93463407af97a5e5ee64fa883d107ef9e558472c4eb9aaaefa459da"
+	delegateePrivKey = "e9142f724955c5854de36324dab0434f97b15ec6b33464d56ebe491e3f559d1b"
+	conditions       = "kind=1&kind=2&kind=3&created_at>1600000000"
+	delegationSig    = "8432b8c86f789c2783ef3becb0fabf4def6031c6a615fa7a622f31329d80ed1b2a79ab753c0462f1440503c94e1829158a3a854a1d418ad256ae2cf8aa19fa9a"
+)
+
+func delegatedEvent(t *testing.T) (*nostr.Event, string) {
+	delegator, _ := nostr.GetPublicKey(delegatorPrivKey)
+	evt, err := nostr.NewEvent(nostr.KindTextNote, "hello world").
+		WithTag("delegation", delegator, conditions, delegationSig).
+		SignWith(delegateePrivKey)
+	if err != nil {
+		t.Fatalf("failed to sign: %v", err)
+	}
+	evt.CreatedAt = 1600000050
+	return evt, delegator
+}
+
+func TestCheckDelegation(t *testing.T) {
+	evt, delegatorPubKey := delegatedEvent(t)
+	delegator, ok, err := CheckDelegation(evt)
+	if err != nil || !ok || delegator != delegatorPubKey {
+		t.Fatalf("delegation wasn't valid: %s %v %v", delegator, ok, err)
+	}
+
+	early, _ := delegatedEvent(t)
+	early.CreatedAt = 1599999999
+	if _, ok, err := CheckDelegation(early); ok || err != nil {
+		t.Errorf("delegation was accepted before it started: %v %v", ok, err)
+	}
+
+	wrongKind, _ := delegatedEvent(t)
+	wrongKind.Kind = nostr.KindSetMetadata
+	if _, ok, err := CheckDelegation(wrongKind); ok || err != nil {
+		t.Errorf("delegation was accepted for the wrong kind: %v %v", ok, err)
+	}
+
+	badSig, _ := delegatedEvent(t)
+	badSig.Tags[0][2] = "kind=1"
+	if _, ok, err := CheckDelegation(badSig); ok || err == nil {
+		t.Errorf("changed conditions were accepted: %v %v", ok, err)
+	}
+	badSig.Tags[0][2] = conditions
+	badSig.Tags[0][3] = "0" + delegationSig[1:]
+	if _, ok, err := CheckDelegation(badSig); ok || err == nil {
+		t.Errorf("changed signature was accepted: %v %v", ok, err)
+	}
+
+	otherDelegatee, _ := delegatedEvent(t)
+	otherDelegatee.PubKey = delegatorPubKey
+	if _, ok, err := CheckDelegation(otherDelegatee); ok || err == nil {
+		t.Errorf("delegation was accepted for another pubkey: %v %v", ok, err)
+	}
+
+	if _, ok, err := CheckDelegation(nostr.NewEvent(nostr.KindTextNote, "")); ok || err != nil {
+		t.Errorf("event without delegation: %v %v", ok, err)
+	}
+}
+
+func TestCreateDelegation(t *testing.T) {
+	evt, delegatorPubKey := delegatedEvent(t)
+	tag, err := CreateDelegation(delegatorPrivKey, evt.PubKey, conditions)
+	if err != nil {
+		t.Fatalf("failed to create delegation: %v", err)
+	}
+	if tag[1] != delegatorPubKey || tag[2] != conditions {
+		t.Errorf("wrong tag: %v", tag)
+	}
+
+	evt.Tags[0] = tag
+	if delegator, ok, err := CheckDelegation(evt); err != nil || !ok || delegator != delegatorPubKey {
+		t.Errorf("created delegation wasn't valid: %s %v %v", delegator, ok, err)
+	}
+
+	// an expired delegation is still well formed, it just doesn't apply
+	tag, _ = CreateDelegation(delegatorPrivKey, evt.PubKey, "kind=1&created_at<1600000000")
+	evt.Tags[0] = tag
+	if _, ok, err := CheckDelegation(evt); ok || err != nil {
+		t.Errorf("expired delegation was accepted: %v %v", ok, err)
+	}
+
+	if _, err := CreateDelegation(delegatorPrivKey, evt.PubKey, "kind=x"); err == nil {
+		t.Error("invalid conditions were accepted")
+	}
+}
@@ -0,0 +1,96 @@
+package nip27
+
+import (
+	"regexp"
+	"strconv"
+
+	"github.com/fiatjaf/go-nostr"
+	"github.com/fiatjaf/go-nostr/nip19"
+)
+
+// Reference is a "nostr:..." mention found in an event's content.
+type Reference struct {
+	// Text is the full "nostr:..." token, found at Content[Start:End].
+	Text    string
+	Start   int
+	End     int
+	Pointer nip19.Pointer
+}
+
+var referenceRegexp = regexp.MustCompile(`nostr:((?:npub|nprofile|note|nevent|naddr)1[qpzry9x8gf2tvdw0s3jn54khce6mua7l]+)`)
+
+// References returns the nostr: mentions in the event content, in the order
+// they appear. Tokens that fail to decode are skipped.
+func References(evt *nostr.Event) []Reference {
+	var references []Reference
+	for _, match := range referenceRegexp.FindAllStringSubmatchIndex(evt.Content, -1) {
+		pointer, err := nip19.DecodePointer(evt.Content[match[2]:match[3]])
+		if err != nil {
+			continue
+		}
+		references = append(references, Reference{
+			Text:    evt.Content[match[0]:match[1]],
+			Start:   match[0],
+			End:     match[1],
+			Pointer: pointer,
+		})
+	}
+	return references
+}
+
+// Mention is a NIP-08 "#[n]" mention found in an event's content, pointing to
+// its nth tag, which is a "p" or an "e" tag.
+type Mention struct {
+	// Text is the full "#[n]" token, found at Content[Start:End].
+	Text     string
+	Start    int
+	End      int
+	TagIndex int
+
+	// Pointer is a nip19.ProfilePointer for "p" tags or a nip19.EventPointer
+	// for "e" tags.
+	Pointer nip19.Pointer
+}
+
+var legacyMentionRegexp = regexp.MustCompile(`#\[(\d+)\]`)
+
+// ResolveLegacyMentions returns the "#[n]" mentions in the event content, used
+// by old events before NIP-27, in the order they appear. Mentions of tags that
+// don't exist or aren't "p" or "e" tags are skipped.
+func ResolveLegacyMentions(evt *nostr.Event) []Mention {
+	var mentions []Mention
+	for _, match := range legacyMentionRegexp.FindAllStringSubmatchIndex(evt.Content, -1) {
+		index, err := strconv.Atoi(evt.Content[match[2]:match[3]])
+		if err != nil || index >= len(evt.Tags) {
+			continue
+		}
+
+		tag := evt.Tags[index]
+		if len(tag) < 2 {
+			continue
+		}
+		var relays []string
+		if len(tag) >= 3 && tag[2] != "" {
+			relays = []string{tag[2]}
+		}
+
+		var pointer nip19.Pointer
+		switch tag[0] {
+		case "p":
+			pointer = nip19.ProfilePointer{PublicKey: tag[1], Relays: relays}
+		case "e":
+			pointer = nip19.EventPointer{ID: tag[1], Relays: relays}
+		default:
+			continue
+		}
+
+		mentions = append(mentions, Mention{
+			Text:     evt.Content[match[0]:match[1]],
+			Start:    match[0],
+			End:      match[1],
+			TagIndex: index,
+			Pointer:  pointer,
+		})
+	}
+	return mentions
+}
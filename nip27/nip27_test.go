@@ -0,0 +1,53 @@
+package nip27
+
+import (
+	"testing"
+
+	"github.com/fiatjaf/go-nostr"
+	"github.com/fiatjaf/go-nostr/nip19"
+)
+
+func TestReferences(t *testing.T) {
+	npub, _ := nip19.EncodePublicKey("3bf0c63fcb93463407af97a5e5ee64fa883d107ef9e558472c4eb9aaaefa459d")
+	nevent, _ := nip19.EncodeEvent("a1e1b22c1a6c697a044a4d904b0c84e3fbb4d8796b8e5fecdd3f6fcb09fd8a7c", []string{"wss://relay.example.com"}, "")
+
+	content := "hello nostr:" + npub + ", see nostr:" + nevent + " and nostr:npub1invalid"
+	evt := nostr.NewEvent(nostr.KindTextNote, content)
+
+	refs := References(evt)
+	if len(refs) != 2 {
+		t.Fatalf("expected 2 references, got %d", len(refs))
+	}
+	if p, ok := refs[0].Pointer.(nip19.ProfilePointer); !ok || p.PublicKey != "3bf0c63fcb93463407af97a5e5ee64fa883d107ef9e558472c4eb9aaaefa459d" {
+		t.Errorf("wrong first reference: %v", refs[0].Pointer)
+	}
+	if p, ok := refs[1].Pointer.(nip19.EventPointer); !ok || p.Relays[0] != "wss://relay.example.com" {
+		t.Errorf("wrong second reference: %v", refs[1].Pointer)
+	}
+	for _, ref := range refs {
+		if content[ref.Start:ref.End] != ref.Text {
+			t.Errorf("reference position doesn't match its text: %v", ref)
+		}
+	}
+}
+
+func TestResolveLegacyMentions(t *testing.T) {
+	evt := nostr.NewEvent(nostr.KindTextNote, "hi #[0], replying to #[1] about #[2] #[9] #[99999999999999999999]").
+		WithTag("p", "3bf0c63fcb93463407af97a5e5ee64fa883d107ef9e558472c4eb9aaaefa459d").
+		WithTag("e", "a1e1b22c1a6c697a044a4d904b0c84e3fbb4d8796b8e5fecdd3f6fcb09fd8a7c", "wss://relay.example.com").
+		WithTag("t", "nostr")
+
+	mentions := ResolveLegacyMentions(evt)
+	if len(mentions) != 2 {
+		t.Fatalf("expected 2 mentions, got %d", len(mentions))
+	}
+	if p, ok := mentions[0].Pointer.(nip19.ProfilePointer); !ok || p.PublicKey != evt.Tags[0][1] || mentions[0].Text != "#[0]" {
+		t.Errorf("wrong first mention: %v", mentions[0])
+	}
+	if p, ok := mentions[1].Pointer.(nip19.EventPointer); !ok || p.ID != evt.Tags[1][1] || p.Relays[0] != "wss://relay.example.com" {
+		t.Errorf("wrong second mention: %v", mentions[1])
+	}
+	if evt.Content[mentions[1].Start:mentions[1].End] != "#[1]" || mentions[1].TagIndex != 1 {
+		t.Errorf("mention position doesn't match its text: %v", mentions[1])
+	}
+}
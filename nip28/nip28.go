@@ -0,0 +1,66 @@
+package nip28
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/fiatjaf/go-nostr"
+	"github.com/fiatjaf/go-nostr/nip10"
+)
+
+const (
+	KindChannelCreation    = 40
+	KindChannelMetadata    = 41
+	KindChannelMessage     = 42
+	KindChannelHideMessage = 43
+	KindChannelMuteUser    = 44
+)
+
+// ChannelMetadata is the content of kind-40 and kind-41 events.
+type ChannelMetadata struct {
+	Name    string   `json:"name,omitempty"`
+	About   string   `json:"about,omitempty"`
+	Picture string   `json:"picture,omitempty"`
+	Relays  []string `json:"relays,omitempty"`
+}
+
+// MakeChannelCreate returns an unsigned kind-40 event creating a channel, its
+// id is the channel id.
+func MakeChannelCreate(metadata ChannelMetadata) *nostr.Event {
+	content, _ := json.Marshal(metadata)
+	return nostr.NewEvent(KindChannelCreation, string(content))
+}
+
+// MakeChannelMetadata returns an unsigned kind-41 event updating the metadata
+// of a channel, only the channel creator is expected to send it.
+func MakeChannelMetadata(channelID string, metadata ChannelMetadata) *nostr.Event {
+	content, _ := json.Marshal(metadata)
+	return nostr.NewEvent(KindChannelMetadata, string(content)).WithTag("e", channelID)
+}
+
+// MakeChannelMessage returns an unsigned kind-42 message to a channel. If
+// replyTo is not nil the message is a reply to it, threaded like NIP-10
+// replies with the channel as the root.
+func MakeChannelMessage(channelID string, content string, replyTo *nostr.Event) *nostr.Event {
+	evt := nostr.NewEvent(KindChannelMessage, content)
+	if replyTo == nil {
+		evt.Tags = append(evt.Tags, nostr.Tag{"e", channelID, "", "root"})
+		return evt
+	}
+
+	nip10.SetReply(evt, replyTo, &nostr.Event{ID: channelID})
+	return evt
+}
+
+// ParseChannelMetadata decodes the content of a kind-40 or kind-41 event.
+func ParseChannelMetadata(evt *nostr.Event) (*ChannelMetadata, error) {
+	if evt.Kind != KindChannelCreation && evt.Kind != KindChannelMetadata {
+		return nil, fmt.Errorf("event kind is %d, not %d or %d", evt.Kind, KindChannelCreation, KindChannelMetadata)
+	}
+
+	var metadata ChannelMetadata
+	if err := json.Unmarshal([]byte(evt.Content), &metadata); err != nil {
+		return nil, fmt.Errorf("failed to parse channel metadata: %w", err)
+	}
+	return &metadata, nil
+}
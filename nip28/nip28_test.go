@@ -0,0 +1,56 @@
+package nip28
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/fiatjaf/go-nostr"
+	"github.com/fiatjaf/go-nostr/nip10"
+)
+
+func TestChannelMessage(t *testing.T) {
+	channelID := "4376c65d2f232afbe9b882a35baa4f6fe8667c4e684749af565f981833ed6a65"
+	author := "3bf0c63fcb93463407af97a5e5ee64fa883d107ef9e558472c4eb9aaaefa459d"
+
+	msg := MakeChannelMessage(channelID, "hello", nil)
+	if msg.Kind != KindChannelMessage || msg.Content != "hello" ||
+		!reflect.DeepEqual(msg.Tags, nostr.Tags{{"e", channelID, "", "root"}}) {
+		t.Errorf("wrong channel message: %v", msg)
+	}
+
+	parent := &nostr.Event{ID: "f7234bd4c1394dda46d09f35bd384dd30cc552ad5541990f98844fb06676e9ca", PubKey: author}
+	reply := MakeChannelMessage(channelID, "hi", parent)
+	expected := nostr.Tags{
+		{"e", channelID, "", "root"},
+		{"e", parent.ID, "", "reply"},
+		{"p", author},
+	}
+	if !reflect.DeepEqual(reply.Tags, expected) {
+		t.Errorf("wrong reply tags: %v", reply.Tags)
+	}
+	if root, replyTo := nip10.GetThread(reply); root == nil || *root != channelID || replyTo == nil || *replyTo != parent.ID {
+		t.Errorf("reply isn't threaded under the channel: %v %v", root, replyTo)
+	}
+}
+
+func TestChannelMetadata(t *testing.T) {
+	metadata := ChannelMetadata{Name: "test", About: "a test channel", Relays: []string{"wss://relay.example.com"}}
+
+	create := MakeChannelCreate(metadata)
+	update := MakeChannelMetadata("4376c65d2f232afbe9b882a35baa4f6fe8667c4e684749af565f981833ed6a65", metadata)
+	if create.Kind != KindChannelCreation || update.Kind != KindChannelMetadata || update.Tags.GetFirst("e") == nil {
+		t.Errorf("wrong channel events: %v %v", create, update)
+	}
+	for _, evt := range []*nostr.Event{create, update} {
+		if parsed, err := ParseChannelMetadata(evt); err != nil || !reflect.DeepEqual(*parsed, metadata) {
+			t.Errorf("metadata didn't round trip: %v %v", parsed, err)
+		}
+	}
+
+	if _, err := ParseChannelMetadata(&nostr.Event{Kind: KindChannelMessage, Content: "{}"}); err == nil {
+		t.Error("parsed metadata from a channel message")
+	}
+	if _, err := ParseChannelMetadata(&nostr.Event{Kind: KindChannelCreation, Content: "not json"}); err == nil {
+		t.Error("parsed invalid metadata")
+	}
+}
@@ -0,0 +1,59 @@
+package nip30
+
+import (
+	"regexp"
+
+	"github.com/fiatjaf/go-nostr"
+)
+
+// ContentPart is a piece of content, either plain Text or a custom emoji
+// with its Shortcode and image URL.
+type ContentPart struct {
+	Text      string
+	Shortcode string
+	URL       string
+}
+
+var shortcodeRegexp = regexp.MustCompile(`:([a-zA-Z0-9_]+):`)
+
+// AddEmoji adds an ["emoji", <shortcode>, <image url>] tag so ":shortcode:"
+// in the content is rendered as the image.
+func AddEmoji(evt *nostr.Event, shortcode string, imageURL string) {
+	evt.Tags = append(evt.Tags, nostr.Tag{"emoji", shortcode, imageURL})
+}
+
+// Emojis returns the image URLs of the custom emojis in the event tags, by
+// shortcode. If a shortcode is repeated the last tag wins.
+func Emojis(evt *nostr.Event) map[string]string {
+	emojis := make(map[string]string)
+	for _, tag := range evt.Tags.GetAll("emoji") {
+		if len(tag) >= 3 {
+			emojis[tag[1]] = tag[2]
+		}
+	}
+	return emojis
+}
+
+// ResolveEmojis splits content into text and the ":shortcode:"s found in
+// emojis. Unknown shortcodes are left as text.
+func ResolveEmojis(content string, emojis map[string]string) []ContentPart {
+	var parts []ContentPart
+	start := 0
+	for _, match := range shortcodeRegexp.FindAllStringSubmatchIndex(content, -1) {
+		shortcode := content[match[2]:match[3]]
+		url, ok := emojis[shortcode]
+		if !ok {
+			continue
+		}
+
+		if match[0] > start {
+			parts = append(parts, ContentPart{Text: content[start:match[0]]})
+		}
+		parts = append(parts, ContentPart{Shortcode: shortcode, URL: url})
+		start = match[1]
+	}
+	if start < len(content) {
+		parts = append(parts, ContentPart{Text: content[start:]})
+	}
+	return parts
+}
@@ -0,0 +1,25 @@
+package nip30
+
+import (
+	"testing"
+
+	"github.com/fiatjaf/go-nostr"
+)
+
+func TestEmojis(t *testing.T) {
+	evt := nostr.NewEvent(nostr.KindTextNote, "gm :sun: and :coffee: :unknown:")
+	AddEmoji(evt, "sun", "https://example.com/old-sun.png")
+	AddEmoji(evt, "coffee", "https://example.com/coffee.png")
+	AddEmoji(evt, "sun", "https://example.com/sun.png")
+
+	emojis := Emojis(evt)
+	if len(emojis) != 2 || emojis["sun"] != "https://example.com/sun.png" {
+		t.Errorf("wrong emojis: %v", emojis)
+	}
+
+	parts := ResolveEmojis(evt.Content, emojis)
+	if len(parts) != 5 || parts[0].Text != "gm " || parts[1].Shortcode != "sun" ||
+		parts[3].URL != "https://example.com/coffee.png" || parts[4].Text != " :unknown:" {
+		t.Errorf("wrong content parts: %v", parts)
+	}
+}
@@ -0,0 +1,15 @@
+package nip31
+
+import "github.com/fiatjaf/go-nostr"
+
+// SetAlt sets the "alt" tag, a short human-readable summary that clients
+// which don't know the event kind can show instead of the content, replacing
+// any previous one.
+func SetAlt(evt *nostr.Event, text string) {
+	evt.SetTagValue("alt", text)
+}
+
+// Alt returns the value of the "alt" tag, ok is false if there is none.
+func Alt(evt *nostr.Event) (text string, ok bool) {
+	return evt.GetTagValue("alt")
+}
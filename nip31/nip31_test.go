@@ -0,0 +1,19 @@
+package nip31
+
+import (
+	"testing"
+
+	"github.com/fiatjaf/go-nostr"
+)
+
+func TestAlt(t *testing.T) {
+	evt := nostr.NewEvent(nostr.KindZapRequest, "").WithTag("p", "someone").WithTag("alt", "old")
+	if _, ok := Alt(nostr.NewEvent(nostr.KindZapRequest, "")); ok {
+		t.Error("alt found on an event without one")
+	}
+
+	SetAlt(evt, "Zap request")
+	if text, ok := Alt(evt); !ok || text != "Zap request" || len(evt.Tags) != 2 || evt.Tags[0][0] != "p" {
+		t.Errorf("alt wasn't replaced: %v", evt.Tags)
+	}
+}
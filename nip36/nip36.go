@@ -0,0 +1,28 @@
+package nip36
+
+import (
+	"github.com/fiatjaf/go-nostr"
+)
+
+// SetContentWarning sets the ["content-warning", "<reason>"] tag, or just
+// ["content-warning"] if the reason is empty, replacing an existing one.
+func SetContentWarning(evt *nostr.Event, reason string) {
+	if reason == "" {
+		evt.SetTagValue("content-warning")
+	} else {
+		evt.SetTagValue("content-warning", reason)
+	}
+}
+
+// ContentWarning checks if the event has a content-warning tag and returns
+// its reason, which may be empty.
+func ContentWarning(evt *nostr.Event) (reason string, present bool) {
+	tag := evt.Tags.GetFirst("content-warning")
+	if tag == nil {
+		return "", false
+	}
+	if len(*tag) >= 2 {
+		reason = (*tag)[1]
+	}
+	return reason, true
+}
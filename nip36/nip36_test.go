@@ -0,0 +1,23 @@
+package nip36
+
+import (
+	"testing"
+
+	"github.com/fiatjaf/go-nostr"
+)
+
+func TestContentWarning(t *testing.T) {
+	evt := nostr.NewEvent(nostr.KindTextNote, "spoilers")
+	if _, present := ContentWarning(evt); present {
+		t.Error("event without content-warning has one")
+	}
+
+	SetContentWarning(evt, "")
+	if reason, present := ContentWarning(evt); !present || reason != "" || len(evt.Tags[0]) != 1 {
+		t.Errorf("wrong content-warning without reason: %v", evt.Tags)
+	}
+	SetContentWarning(evt, "movie ending")
+	if reason, _ := ContentWarning(evt); reason != "movie ending" || len(evt.Tags) != 1 {
+		t.Errorf("content-warning wasn't replaced: %v", evt.Tags)
+	}
+}
@@ -0,0 +1,34 @@
+package nip40
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/fiatjaf/go-nostr"
+)
+
+// SetExpiration sets the ["expiration", "<unix timestamp>"] tag, replacing an
+// existing one.
+func SetExpiration(evt *nostr.Event, t time.Time) {
+	evt.SetTagValue("expiration", strconv.FormatInt(t.Unix(), 10))
+}
+
+// Expiration returns the time set in the expiration tag, if any.
+func Expiration(evt *nostr.Event) (time.Time, bool) {
+	value, ok := evt.GetTagValue("expiration")
+	if !ok {
+		return time.Time{}, false
+	}
+
+	ts, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return time.Time{}, false
+	}
+	return time.Unix(ts, 0), true
+}
+
+// IsExpired checks if the event has an expiration time that is not after now.
+func IsExpired(evt *nostr.Event, now time.Time) bool {
+	expiration, ok := Expiration(evt)
+	return ok && !expiration.After(now)
+}
@@ -0,0 +1,29 @@
+package nip40
+
+import (
+	"testing"
+	"time"
+
+	"github.com/fiatjaf/go-nostr"
+)
+
+func TestExpiration(t *testing.T) {
+	evt := nostr.NewEvent(nostr.KindTextNote, "soon gone")
+	if _, ok := Expiration(evt); ok || IsExpired(evt, time.Now()) {
+		t.Error("event without expiration tag expires")
+	}
+
+	SetExpiration(evt, time.Unix(1700000000, 0))
+	SetExpiration(evt, time.Unix(1800000000, 0))
+	if expiration, ok := Expiration(evt); !ok || expiration.Unix() != 1800000000 || len(evt.Tags) != 1 {
+		t.Errorf("expiration wasn't replaced: %v", evt.Tags)
+	}
+	if IsExpired(evt, time.Unix(1799999999, 0)) || !IsExpired(evt, time.Unix(1800000000, 0)) {
+		t.Error("wrong expiry")
+	}
+
+	evt.Tags[0][1] = "tomorrow"
+	if _, ok := Expiration(evt); ok {
+		t.Error("invalid timestamp was read")
+	}
+}
@@ -0,0 +1,217 @@
+package nip44
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+
+	"github.com/btcsuite/btcd/btcec"
+	"golang.org/x/crypto/chacha20"
+	"golang.org/x/crypto/hkdf"
+)
+
+const (
+	version byte = 2
+
+	minPlaintextSize = 1
+	maxPlaintextSize = 65535
+)
+
+var ErrUnsupportedVersion = errors.New("unsupported encryption version")
+
+// GetConversationKey derives the key shared by the owners of privateKey and
+// publicKey, it is the same in both directions and can be reused for all the
+// messages between them.
+func GetConversationKey(privateKey string, publicKey string) ([]byte, error) {
+	sk, err := hex.DecodeString(privateKey)
+	if err != nil || len(sk) != 32 {
+		return nil, fmt.Errorf("invalid private key")
+	}
+
+	// x-only public keys are taken to have an even y
+	pkb, err := hex.DecodeString("02" + publicKey)
+	if err != nil || len(pkb) != 33 {
+		return nil, fmt.Errorf("invalid public key '%s'", publicKey)
+	}
+	pk, err := btcec.ParsePubKey(pkb, btcec.S256())
+	if err != nil {
+		return nil, fmt.Errorf("invalid public key '%s': %w", publicKey, err)
+	}
+
+	if k := new(big.Int).SetBytes(sk); k.Sign() == 0 || k.Cmp(btcec.S256().N) >= 0 {
+		return nil, fmt.Errorf("invalid private key")
+	}
+	x, _ := btcec.S256().ScalarMult(pk.X, pk.Y, sk)
+	sharedX := make([]byte, 32)
+	x.FillBytes(sharedX)
+
+	return hkdf.Extract(sha256.New, sharedX, []byte("nip44-v2")), nil
+}
+
+// Encrypt encrypts plaintext with a conversation key obtained from
+// GetConversationKey, returning the base64 payload.
+func Encrypt(plaintext string, conversationKey []byte) (string, error) {
+	nonce := make([]byte, 32)
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return "", fmt.Errorf("failed to generate nonce: %w", err)
+	}
+	return encrypt(plaintext, conversationKey, nonce)
+}
+
+// Decrypt decrypts a base64 payload produced by Encrypt.
+func Decrypt(payload string, conversationKey []byte) (string, error) {
+	if len(payload) == 0 {
+		return "", fmt.Errorf("empty payload")
+	}
+	if payload[0] == '#' {
+		return "", ErrUnsupportedVersion
+	}
+	if len(payload) < 132 || len(payload) > 87472 {
+		return "", fmt.Errorf("invalid payload length %d", len(payload))
+	}
+
+	data, err := base64.StdEncoding.DecodeString(payload)
+	if err != nil {
+		return "", fmt.Errorf("payload is not valid base64: %w", err)
+	}
+	if len(data) < 99 || len(data) > 65603 {
+		return "", fmt.Errorf("invalid payload length %d", len(data))
+	}
+	if data[0] != version {
+		return "", fmt.Errorf("%w %d", ErrUnsupportedVersion, data[0])
+	}
+
+	nonce := data[1:33]
+	ciphertext := data[33 : len(data)-32]
+	mac := data[len(data)-32:]
+
+	chachaKey, chachaNonce, hmacKey, err := messageKeys(conversationKey, nonce)
+	if err != nil {
+		return "", err
+	}
+
+	if !hmac.Equal(mac, computeMAC(hmacKey, nonce, ciphertext)) {
+		return "", fmt.Errorf("invalid mac")
+	}
+
+	padded, err := chacha(chachaKey, chachaNonce, ciphertext)
+	if err != nil {
+		return "", err
+	}
+
+	return unpad(padded)
+}
+
+func encrypt(plaintext string, conversationKey []byte, nonce []byte) (string, error) {
+	chachaKey, chachaNonce, hmacKey, err := messageKeys(conversationKey, nonce)
+	if err != nil {
+		return "", err
+	}
+
+	padded, err := pad(plaintext)
+	if err != nil {
+		return "", err
+	}
+
+	ciphertext, err := chacha(chachaKey, chachaNonce, padded)
+	if err != nil {
+		return "", err
+	}
+
+	data := make([]byte, 0, 1+32+len(ciphertext)+32)
+	data = append(data, version)
+	data = append(data, nonce...)
+	data = append(data, ciphertext...)
+	data = append(data, computeMAC(hmacKey, nonce, ciphertext)...)
+
+	return base64.StdEncoding.EncodeToString(data), nil
+}
+
+func messageKeys(conversationKey []byte, nonce []byte) (chachaKey []byte, chachaNonce []byte, hmacKey []byte, err error) {
+	if len(conversationKey) != 32 {
+		return nil, nil, nil, fmt.Errorf("conversation key must be 32 bytes, not %d", len(conversationKey))
+	}
+	if len(nonce) != 32 {
+		return nil, nil, nil, fmt.Errorf("nonce must be 32 bytes, not %d", len(nonce))
+	}
+
+	keys := make([]byte, 76)
+	if _, err := io.ReadFull(hkdf.Expand(sha256.New, conversationKey, nonce), keys); err != nil {
+		return nil, nil, nil, fmt.Errorf("failed to derive message keys: %w", err)
+	}
+
+	return keys[0:32], keys[32:44], keys[44:76], nil
+}
+
+func chacha(key []byte, nonce []byte, message []byte) ([]byte, error) {
+	cipher, err := chacha20.NewUnauthenticatedCipher(key, nonce)
+	if err != nil {
+		return nil, err
+	}
+	dst := make([]byte, len(message))
+	cipher.XORKeyStream(dst, message)
+	return dst, nil
+}
+
+func computeMAC(key []byte, nonce []byte, ciphertext []byte) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write(nonce)
+	h.Write(ciphertext)
+	return h.Sum(nil)
+}
+
+// calcPaddedLen rounds the plaintext length up so that messages only leak
+// their approximate size.
+func calcPaddedLen(unpaddedLen int) int {
+	if unpaddedLen <= 32 {
+		return 32
+	}
+
+	nextPower := 1
+	for nextPower < unpaddedLen {
+		nextPower <<= 1
+	}
+	chunk := 32
+	if nextPower > 256 {
+		chunk = nextPower / 8
+	}
+
+	return chunk * ((unpaddedLen-1)/chunk + 1)
+}
+
+func pad(plaintext string) ([]byte, error) {
+	size := len(plaintext)
+	if size < minPlaintextSize || size > maxPlaintextSize {
+		return nil, fmt.Errorf("plaintext must have between %d and %d bytes, not %d",
+			minPlaintextSize, maxPlaintextSize, size)
+	}
+
+	padded := make([]byte, 2+calcPaddedLen(size))
+	binary.BigEndian.PutUint16(padded, uint16(size))
+	copy(padded[2:], plaintext)
+	return padded, nil
+}
+
+func unpad(padded []byte) (string, error) {
+	if len(padded) < 2 {
+		return "", fmt.Errorf("invalid padding")
+	}
+
+	size := int(binary.BigEndian.Uint16(padded))
+	if size < minPlaintextSize || len(padded) != 2+calcPaddedLen(size) {
+		return "", fmt.Errorf("invalid padding")
+	}
+	if !bytes.Equal(padded[2+size:], make([]byte, len(padded)-2-size)) {
+		return "", fmt.Errorf("invalid padding")
+	}
+
+	return string(padded[2 : 2+size]), nil
+}
@@ -0,0 +1,103 @@
+package nip44
+
+import (
+	"encoding/hex"
+	"strings"
+	"testing"
+
+	"github.com/fiatjaf/go-nostr"
+)
+
+// test vectors from https://github.com/paulmillr/nip44/blob/main/nip44.vectors.json
+
+func TestGetConversationKey(t *testing.T) {
+	for _, v := range []struct {
+		sec1, pub2, conversationKey string
+	}{
+		{
+			"315e59ff51cb9209768cf7da80791ddcaae56ac9775eb25b6dee1234bc5d2268",
+			"c2f9d9948dc8c7c38321e4b85c8558872eafa0641cd269db76848a6073e69133",
+			"3dfef0ce2a4d80a25e7a328accf73448ef67096f65f79588e358d9a0eb9013f1",
+		},
+	} {
+		key, err := GetConversationKey(v.sec1, v.pub2)
+		if err != nil {
+			t.Errorf("failed to get conversation key: %v", err)
+			continue
+		}
+		if hex.EncodeToString(key) != v.conversationKey {
+			t.Errorf("wrong conversation key: %x != %s", key, v.conversationKey)
+		}
+	}
+}
+
+func TestEncryptDecrypt(t *testing.T) {
+	for _, v := range []struct {
+		sec1, sec2, conversationKey, nonce, plaintext, payload string
+	}{
+		{
+			"0000000000000000000000000000000000000000000000000000000000000001",
+			"0000000000000000000000000000000000000000000000000000000000000002",
+			"c41c775356fd92eadc63ff5a0dc1da211b268cbea22316767095b2871ea1412d",
+			"0000000000000000000000000000000000000000000000000000000000000001",
+			"a",
+			"AgAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAABee0G5VSK0/9YypIObAtDKfYEAjD35uVkHyB0F4DwrcNaCXlCWZKaArsGrY6M9wnuTMxWfp1RTN9Xga8no+kF5Vsb",
+		},
+		{
+			"0000000000000000000000000000000000000000000000000000000000000002",
+			"0000000000000000000000000000000000000000000000000000000000000001",
+			"c41c775356fd92eadc63ff5a0dc1da211b268cbea22316767095b2871ea1412d",
+			"f00000000000000000000000000000f00000000000000000000000000000000f",
+			"🍕🫃",
+			"AvAAAAAAAAAAAAAAAAAAAPAAAAAAAAAAAAAAAAAAAAAPSKSK6is9ngkX2+cSq85Th16oRTISAOfhStnixqZziKMDvB0QQzgFZdjLTPicCJaV8nDITO+QfaQ61+KbWQIOO2Yj",
+		},
+	} {
+		pub2, _ := nostr.GetPublicKey(v.sec2)
+		key, err := GetConversationKey(v.sec1, pub2)
+		if err != nil || hex.EncodeToString(key) != v.conversationKey {
+			t.Errorf("wrong conversation key: %x %v", key, err)
+			continue
+		}
+
+		nonce, _ := hex.DecodeString(v.nonce)
+		payload, err := encrypt(v.plaintext, key, nonce)
+		if err != nil || payload != v.payload {
+			t.Errorf("wrong payload for '%s': %s %v", v.plaintext, payload, err)
+		}
+
+		plaintext, err := Decrypt(v.payload, key)
+		if err != nil || plaintext != v.plaintext {
+			t.Errorf("wrong plaintext: '%s' %v", plaintext, err)
+		}
+	}
+}
+
+func TestCalcPaddedLen(t *testing.T) {
+	for _, v := range [][2]int{
+		{16, 32}, {32, 32}, {33, 64}, {37, 64}, {45, 64}, {49, 64}, {64, 64},
+		{65, 96}, {100, 128}, {111, 128}, {200, 224}, {250, 256}, {320, 320},
+		{383, 384}, {384, 384}, {400, 448}, {500, 512}, {512, 512}, {515, 640},
+		{700, 768}, {800, 896}, {900, 1024}, {1020, 1024}, {65536, 65536},
+	} {
+		if padded := calcPaddedLen(v[0]); padded != v[1] {
+			t.Errorf("padded length of %d should be %d, not %d", v[0], v[1], padded)
+		}
+	}
+}
+
+func TestDecryptInvalid(t *testing.T) {
+	key, _ := hex.DecodeString("c41c775356fd92eadc63ff5a0dc1da211b268cbea22316767095b2871ea1412d")
+	valid := "AgAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAABee0G5VSK0/9YypIObAtDKfYEAjD35uVkHyB0F4DwrcNaCXlCWZKaArsGrY6M9wnuTMxWfp1RTN9Xga8no+kF5Vsb"
+
+	for _, invalid := range []string{
+		"",
+		"#Atqupco0WyaOW2IGDKcshwxI9xO8HgD/P8Ddt46CbxDbOsrsqIEybscEwg5rnI/Cx03mDSmeweOLKD7dw5BDZQDxXe2FwUJ8Ag25",
+		valid[:len(valid)-4] + "AAAA",
+		"Aw" + valid[2:],
+		strings.Repeat("A", 131),
+	} {
+		if _, err := Decrypt(invalid, key); err == nil {
+			t.Errorf("invalid payload '%s' was decrypted", invalid)
+		}
+	}
+}
@@ -0,0 +1,270 @@
+package nip46
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fiatjaf/go-nostr"
+	"github.com/fiatjaf/go-nostr/nip04"
+)
+
+const KindNostrConnect = 24133
+
+var ErrTimeout = errors.New("remote signer didn't answer in time")
+
+var _ nostr.Signer = (*RemoteSigner)(nil)
+
+type request struct {
+	ID     string   `json:"id"`
+	Method string   `json:"method"`
+	Params []string `json:"params"`
+}
+
+type response struct {
+	ID     string `json:"id"`
+	Result string `json:"result"`
+	Error  string `json:"error,omitempty"`
+}
+
+// RemoteSigner is a nostr.Signer that asks a NIP-46 bunker to sign events,
+// talking to it through a relay.
+type RemoteSigner struct {
+	// Timeout is how long Sign and PublicKey wait for the bunker to answer.
+	Timeout time.Duration
+
+	remotePubkey string
+	secret       string
+	clientKey    string
+	clientPubkey string
+	sharedSecret []byte
+
+	relay *nostr.Relay
+	sub   *nostr.Subscription
+
+	mutex     sync.Mutex
+	listeners map[string]chan response
+	pubkey    string
+}
+
+// NewRemoteSigner parses a "bunker://<remote pubkey>?relay=<url>&secret=<secret>"
+// URI, connects to the first reachable relay and sends a connect request
+// to the bunker.
+func NewRemoteSigner(ctx context.Context, connectionURI string) (*RemoteSigner, error) {
+	remotePubkey, relays, secret, err := ParseBunkerURI(connectionURI)
+	if err != nil {
+		return nil, err
+	}
+
+	clientKey := nostr.GeneratePrivateKey()
+	clientPubkey, _ := nostr.GetPublicKey(clientKey)
+	sharedSecret, err := nip04.ComputeSharedSecret(clientKey, remotePubkey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to compute shared secret: %w", err)
+	}
+
+	rs := &RemoteSigner{
+		Timeout:      30 * time.Second,
+		remotePubkey: remotePubkey,
+		secret:       secret,
+		clientKey:    clientKey,
+		clientPubkey: clientPubkey,
+		sharedSecret: sharedSecret,
+		listeners:    make(map[string]chan response),
+	}
+
+	for _, url := range relays {
+		rs.relay, err = nostr.Connect(ctx, url)
+		if err == nil {
+			break
+		}
+	}
+	if rs.relay == nil {
+		return nil, fmt.Errorf("failed to connect to any of the bunker relays: %w", err)
+	}
+
+	since := time.Now().Add(-1 * time.Minute)
+	rs.sub, err = rs.relay.Subscribe(context.Background(), nostr.Filters{{
+		Kinds:   nostr.IntList{KindNostrConnect},
+		Authors: nostr.StringList{remotePubkey},
+		Tags:    nostr.TagMap{"p": {clientPubkey}},
+		Since:   &since,
+	}})
+	if err != nil {
+		rs.relay.Close()
+		return nil, fmt.Errorf("failed to subscribe to bunker responses: %w", err)
+	}
+	go rs.handleResponses()
+
+	params := []string{remotePubkey}
+	if secret != "" {
+		params = append(params, secret)
+	}
+	if _, err := rs.call(ctx, "connect", params); err != nil {
+		rs.Close()
+		return nil, err
+	}
+
+	return rs, nil
+}
+
+// ParseBunkerURI extracts the remote signer pubkey, relays and secret from a
+// "bunker://" URI.
+func ParseBunkerURI(connectionURI string) (remotePubkey string, relays []string, secret string, err error) {
+	u, err := url.Parse(connectionURI)
+	if err != nil {
+		return "", nil, "", fmt.Errorf("invalid connection URI: %w", err)
+	}
+	if u.Scheme != "bunker" {
+		return "", nil, "", fmt.Errorf("connection URI scheme must be 'bunker', not '%s'", u.Scheme)
+	}
+
+	remotePubkey = u.Host
+	if b, err := hex.DecodeString(remotePubkey); err != nil || len(b) != 32 {
+		return "", nil, "", fmt.Errorf("invalid remote signer pubkey '%s'", remotePubkey)
+	}
+
+	relays = u.Query()["relay"]
+	if len(relays) == 0 {
+		return "", nil, "", fmt.Errorf("connection URI has no relays")
+	}
+
+	return remotePubkey, relays, u.Query().Get("secret"), nil
+}
+
+// PublicKey asks the bunker for the public key it signs with.
+func (rs *RemoteSigner) PublicKey() (string, error) {
+	rs.mutex.Lock()
+	pubkey := rs.pubkey
+	rs.mutex.Unlock()
+	if pubkey != "" {
+		return pubkey, nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), rs.Timeout)
+	defer cancel()
+
+	pubkey, err := rs.call(ctx, "get_public_key", []string{})
+	if err != nil {
+		return "", err
+	}
+	if b, err := hex.DecodeString(pubkey); err != nil || len(b) != 32 {
+		return "", fmt.Errorf("remote signer returned an invalid pubkey '%s'", pubkey)
+	}
+
+	rs.mutex.Lock()
+	rs.pubkey = pubkey
+	rs.mutex.Unlock()
+	return pubkey, nil
+}
+
+// Sign asks the bunker to sign the event and sets its ID, PubKey and Sig.
+func (rs *RemoteSigner) Sign(evt *nostr.Event) error {
+	pubkey, err := rs.PublicKey()
+	if err != nil {
+		return err
+	}
+	evt.PubKey = pubkey
+
+	ctx, cancel := context.WithTimeout(context.Background(), rs.Timeout)
+	defer cancel()
+
+	unsigned, _ := json.Marshal(evt)
+	result, err := rs.call(ctx, "sign_event", []string{string(unsigned)})
+	if err != nil {
+		return err
+	}
+
+	var signed nostr.Event
+	if err := json.Unmarshal([]byte(result), &signed); err != nil {
+		return fmt.Errorf("remote signer returned an invalid event: %w", err)
+	}
+	if signed.GetID() != evt.GetID() {
+		return fmt.Errorf("remote signer returned a different event")
+	}
+	if ok, err := signed.CheckSignature(); !ok {
+		return fmt.Errorf("remote signer returned an invalid signature: %v", err)
+	}
+
+	evt.ID = signed.ID
+	evt.Sig = signed.Sig
+	return nil
+}
+
+// Close closes the subscription and the connection to the relay.
+func (rs *RemoteSigner) Close() error {
+	rs.sub.Unsub()
+	return rs.relay.Close()
+}
+
+func (rs *RemoteSigner) call(ctx context.Context, method string, params []string) (string, error) {
+	random := make([]byte, 12)
+	rand.Read(random)
+	id := hex.EncodeToString(random)
+
+	payload, _ := json.Marshal(request{ID: id, Method: method, Params: params})
+	content, err := nip04.Encrypt(string(payload), rs.sharedSecret)
+	if err != nil {
+		return "", fmt.Errorf("failed to encrypt request: %w", err)
+	}
+
+	evt := nostr.NewEvent(KindNostrConnect, content).WithTag("p", rs.remotePubkey)
+	if _, err := evt.SignWith(rs.clientKey); err != nil {
+		return "", err
+	}
+
+	listener := make(chan response, 1)
+	rs.mutex.Lock()
+	rs.listeners[id] = listener
+	rs.mutex.Unlock()
+	defer func() {
+		rs.mutex.Lock()
+		delete(rs.listeners, id)
+		rs.mutex.Unlock()
+	}()
+
+	if _, err := rs.relay.Publish(ctx, evt); err != nil {
+		return "", fmt.Errorf("failed to send %s request: %w", method, err)
+	}
+
+	select {
+	case resp := <-listener:
+		if resp.Error != "" {
+			return "", fmt.Errorf("remote signer rejected %s: %s", method, resp.Error)
+		}
+		return resp.Result, nil
+	case <-ctx.Done():
+		return "", fmt.Errorf("%w: %s (%s)", ErrTimeout, method, ctx.Err())
+	}
+}
+
+func (rs *RemoteSigner) handleResponses() {
+	for em := range rs.sub.Events {
+		plaintext, err := nip04.Decrypt(em.Event.Content, rs.sharedSecret)
+		if err != nil {
+			continue
+		}
+
+		var resp response
+		if err := json.Unmarshal([]byte(strings.TrimSpace(plaintext)), &resp); err != nil {
+			continue
+		}
+
+		rs.mutex.Lock()
+		listener, ok := rs.listeners[resp.ID]
+		rs.mutex.Unlock()
+		if ok {
+			select {
+			case listener <- resp:
+			default:
+			}
+		}
+	}
+}
@@ -0,0 +1,190 @@
+package nip46
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/fiatjaf/go-nostr"
+	"github.com/fiatjaf/go-nostr/nip04"
+	"github.com/gorilla/websocket"
+)
+
+// mockBunker is a relay that answers the NIP-46 requests sent through it
+// itself, signing events with userKey. Methods in rejected are answered with
+// that error and methods in ignored aren't answered at all.
+type mockBunker struct {
+	*httptest.Server
+
+	bunkerKey string
+	userKey   string
+	rejected  map[string]string
+	ignored   map[string]bool
+}
+
+func newMockBunker(t *testing.T) *mockBunker {
+	b := &mockBunker{
+		bunkerKey: nostr.GeneratePrivateKey(),
+		userKey:   nostr.GeneratePrivateKey(),
+		rejected:  make(map[string]string),
+		ignored:   make(map[string]bool),
+	}
+	upgrader := websocket.Upgrader{}
+	b.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		conn, err := upgrader.Upgrade(w, req, nil)
+		if err != nil {
+			t.Errorf("failed to upgrade: %v", err)
+			return
+		}
+		defer conn.Close()
+
+		var subscription string
+		for {
+			var msg []json.RawMessage
+			if err := conn.ReadJSON(&msg); err != nil {
+				return
+			}
+			var label string
+			json.Unmarshal(msg[0], &label)
+
+			switch label {
+			case "REQ":
+				json.Unmarshal(msg[1], &subscription)
+				conn.WriteJSON([]interface{}{"EOSE", subscription})
+			case "EVENT":
+				var evt nostr.Event
+				json.Unmarshal(msg[1], &evt)
+				conn.WriteJSON([]interface{}{"OK", evt.ID, true, ""})
+				if answer := b.answer(t, &evt); answer != nil {
+					conn.WriteJSON([]interface{}{"EVENT", subscription, answer})
+				}
+			}
+		}
+	}))
+	return b
+}
+
+func (b *mockBunker) answer(t *testing.T, evt *nostr.Event) *nostr.Event {
+	sharedSecret, _ := nip04.ComputeSharedSecret(b.bunkerKey, evt.PubKey)
+	plaintext, err := nip04.Decrypt(evt.Content, sharedSecret)
+	if err != nil {
+		t.Errorf("failed to decrypt request: %v", err)
+		return nil
+	}
+	var req request
+	json.Unmarshal([]byte(plaintext), &req)
+	if b.ignored[req.Method] {
+		return nil
+	}
+
+	resp := response{ID: req.ID, Error: b.rejected[req.Method]}
+	if resp.Error == "" {
+		switch req.Method {
+		case "connect":
+			resp.Result = "ack"
+		case "get_public_key":
+			resp.Result, _ = nostr.GetPublicKey(b.userKey)
+		case "sign_event":
+			var unsigned nostr.Event
+			json.Unmarshal([]byte(req.Params[0]), &unsigned)
+			unsigned.SignWith(b.userKey)
+			signed, _ := json.Marshal(unsigned)
+			resp.Result = string(signed)
+		}
+	}
+
+	payload, _ := json.Marshal(resp)
+	content, _ := nip04.Encrypt(string(payload), sharedSecret)
+	answer, _ := nostr.NewEvent(KindNostrConnect, content).WithTag("p", evt.PubKey).SignWith(b.bunkerKey)
+	return answer
+}
+
+func (b *mockBunker) URI() string {
+	pubkey, _ := nostr.GetPublicKey(b.bunkerKey)
+	return "bunker://" + pubkey + "?relay=" + url.QueryEscape("ws"+strings.TrimPrefix(b.URL, "http")) + "&secret=s3cr3t"
+}
+
+func TestParseBunkerURI(t *testing.T) {
+	pubkey := "3bf0c63fcb93463407af97a5e5ee64fa883d107ef9e558472c4eb9aaaefa459d"
+	remotePubkey, relays, secret, err := ParseBunkerURI("bunker://" + pubkey + "?relay=wss://a.example.com&relay=wss://b.example.com&secret=abc")
+	if err != nil || remotePubkey != pubkey || len(relays) != 2 || relays[1] != "wss://b.example.com" || secret != "abc" {
+		t.Errorf("wrong result: %s %v %s %v", remotePubkey, relays, secret, err)
+	}
+
+	for name, uri := range map[string]string{
+		"other scheme": "nostrconnect://" + pubkey + "?relay=wss://a.example.com",
+		"bad pubkey":   "bunker://" + pubkey[:60] + "?relay=wss://a.example.com",
+		"no relays":    "bunker://" + pubkey + "?secret=abc",
+		"not a uri":    "bunker://%zz",
+	} {
+		if _, _, _, err := ParseBunkerURI(uri); err == nil {
+			t.Errorf("%s: %s was accepted", name, uri)
+		}
+	}
+}
+
+func TestRemoteSigner(t *testing.T) {
+	bunker := newMockBunker(t)
+	defer bunker.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	rs, err := NewRemoteSigner(ctx, bunker.URI())
+	if err != nil {
+		t.Fatalf("failed to connect: %v", err)
+	}
+	defer rs.Close()
+
+	evt := nostr.NewEvent(nostr.KindTextNote, "signed remotely")
+	if err := evt.SignBy(rs); err != nil {
+		t.Fatalf("failed to sign: %v", err)
+	}
+	userPubkey, _ := nostr.GetPublicKey(bunker.userKey)
+	if evt.PubKey != userPubkey || evt.Validate() != nil {
+		t.Errorf("wrong signed event: %v", evt)
+	}
+}
+
+func TestRemoteSignerErrors(t *testing.T) {
+	connect := func(configure func(*mockBunker)) (*RemoteSigner, func()) {
+		bunker := newMockBunker(t)
+		configure(bunker)
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		rs, err := NewRemoteSigner(ctx, bunker.URI())
+		if err != nil {
+			t.Fatalf("failed to connect: %v", err)
+		}
+		rs.Timeout = 200 * time.Millisecond
+		return rs, func() {
+			rs.Close()
+			bunker.Close()
+		}
+	}
+
+	rejecting, done := connect(func(b *mockBunker) { b.rejected["sign_event"] = "permission denied" })
+	defer done()
+	err := rejecting.Sign(nostr.NewEvent(nostr.KindTextNote, "not allowed"))
+	if err == nil || !strings.Contains(err.Error(), "remote signer rejected sign_event: permission denied") {
+		t.Errorf("expected a rejection, got %v", err)
+	}
+
+	silent, done := connect(func(b *mockBunker) { b.ignored["get_public_key"] = true })
+	defer done()
+	if _, err := silent.PublicKey(); !errors.Is(err, ErrTimeout) {
+		t.Errorf("expected ErrTimeout, got %v", err)
+	}
+	if err := silent.Sign(nostr.NewEvent(nostr.KindTextNote, "")); !errors.Is(err, ErrTimeout) {
+		t.Errorf("expected ErrTimeout, got %v", err)
+	}
+
+	if _, err := NewRemoteSigner(context.Background(), "bunker://nobody"); err == nil {
+		t.Error("connected with an invalid URI")
+	}
+}
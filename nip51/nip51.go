@@ -0,0 +1,104 @@
+package nip51
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/fiatjaf/go-nostr"
+	"github.com/fiatjaf/go-nostr/nip04"
+)
+
+const (
+	KindMuteList     = 10000
+	KindPinList      = 10001
+	KindBookmarkList = 10003
+	KindFollowSet    = 30000
+	KindRelaySet     = 30002
+	KindBookmarkSet  = 30003
+)
+
+// List is a NIP-51 list. Public items are the event tags, Private items are
+// tags too, encrypted to the author with NIP-04 in the event content.
+type List struct {
+	Kind int
+
+	// Identifier is the "d" tag of sets, like bookmark sets, and empty for
+	// the standard lists.
+	Identifier string
+
+	Public  nostr.Tags
+	Private nostr.Tags
+}
+
+// ParseList reads the items of a list event. Private items are only decrypted
+// if privateKey, which must be the author's, is given.
+func ParseList(evt *nostr.Event, privateKey string) (*List, error) {
+	l := &List{Kind: evt.Kind, Public: make(nostr.Tags, 0, len(evt.Tags))}
+	for _, tag := range evt.Tags {
+		if len(tag) >= 2 && tag[0] == "d" && nostr.IsParameterizedReplaceable(evt.Kind) {
+			l.Identifier = tag[1]
+			continue
+		}
+		l.Public = append(l.Public, tag)
+	}
+
+	if evt.Content == "" || privateKey == "" {
+		return l, nil
+	}
+
+	pubkey, err := nostr.GetPublicKey(privateKey)
+	if err != nil {
+		return nil, fmt.Errorf("invalid private key: %w", err)
+	}
+	if pubkey != evt.PubKey {
+		return nil, fmt.Errorf("private items can only be decrypted by the list author")
+	}
+
+	sharedSecret, err := nip04.ComputeSharedSecret(privateKey, pubkey)
+	if err != nil {
+		return nil, err
+	}
+	plaintext, err := nip04.Decrypt(evt.Content, sharedSecret)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt private items: %w", err)
+	}
+	if err := json.Unmarshal([]byte(plaintext), &l.Private); err != nil {
+		return nil, fmt.Errorf("invalid private items: %w", err)
+	}
+
+	return l, nil
+}
+
+// ToEvent returns an unsigned event with the list items, the private ones are
+// encrypted with privateKey, which can be empty if there are none.
+func (l *List) ToEvent(privateKey string) (*nostr.Event, error) {
+	evt := nostr.NewEvent(l.Kind, "")
+	if nostr.IsParameterizedReplaceable(l.Kind) {
+		evt.WithTag("d", l.Identifier)
+	}
+	evt.Tags = append(evt.Tags, l.Public...)
+
+	if len(l.Private) == 0 {
+		return evt, nil
+	}
+	if privateKey == "" {
+		return nil, fmt.Errorf("a private key is needed to encrypt private items")
+	}
+
+	pubkey, err := nostr.GetPublicKey(privateKey)
+	if err != nil {
+		return nil, fmt.Errorf("invalid private key: %w", err)
+	}
+	sharedSecret, err := nip04.ComputeSharedSecret(privateKey, pubkey)
+	if err != nil {
+		return nil, err
+	}
+	plaintext, _ := json.Marshal(l.Private)
+	evt.Content, err = nip04.Encrypt(string(plaintext), sharedSecret)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encrypt private items: %w", err)
+	}
+	evt.PubKey = pubkey
+
+	return evt, nil
+}
@@ -0,0 +1,69 @@
+package nip51
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/fiatjaf/go-nostr"
+)
+
+func TestList(t *testing.T) {
+	sk := nostr.GeneratePrivateKey()
+	list := &List{
+		Kind:       KindBookmarkSet,
+		Identifier: "reading",
+		Public:     nostr.Tags{{"e", "public"}},
+		Private:    nostr.Tags{{"e", "private"}, {"t", "secret"}},
+	}
+
+	evt, err := list.ToEvent(sk)
+	if err != nil {
+		t.Fatalf("failed to make list: %v", err)
+	}
+	parsed, err := ParseList(evt, sk)
+	if err != nil || !reflect.DeepEqual(parsed, list) {
+		t.Errorf("list didn't roundtrip: %v %v", parsed, err)
+	}
+
+	if parsed, err := ParseList(evt, ""); err != nil || parsed.Private != nil || len(parsed.Public) != 1 {
+		t.Errorf("private items were read without a key: %v %v", parsed, err)
+	}
+	if _, err := ParseList(evt, nostr.GeneratePrivateKey()); err == nil {
+		t.Error("private items were decrypted with another key")
+	}
+	if _, err := list.ToEvent(""); err == nil {
+		t.Error("private items were encrypted without a key")
+	}
+
+	// standard lists have no identifier, so a "d" tag is just an item
+	mutes, _ := (&List{Kind: KindMuteList, Public: nostr.Tags{{"d", "x"}}}).ToEvent("")
+	if parsed, _ := ParseList(mutes, ""); parsed.Identifier != "" || len(parsed.Public) != 1 {
+		t.Errorf("wrong mute list: %v", parsed)
+	}
+}
+
+func TestFollowSet(t *testing.T) {
+	set := FollowSet{Identifier: "friends", Title: "Friends", Members: []string{"alice", "bob"}}
+	parsed, err := ParseFollowSet(MakeFollowSet(set))
+	if err != nil || !reflect.DeepEqual(*parsed, set) {
+		t.Errorf("follow set didn't roundtrip: %v %v", parsed, err)
+	}
+
+	if _, err := ParseFollowSet(nostr.NewEvent(KindFollowSet, "").WithTag("p", "alice")); err == nil {
+		t.Error("follow set without 'd' tag was accepted")
+	}
+	if _, err := ParseFollowSet(MakeRelaySet(RelaySet{Identifier: "friends"})); err == nil {
+		t.Error("relay set was parsed as a follow set")
+	}
+}
+
+func TestRelaySet(t *testing.T) {
+	set := RelaySet{Identifier: "fast", Relays: []string{"wss://relay.example.com", "https://Other.Example.com/", "wss://bad host"}}
+	parsed, err := ParseRelaySet(MakeRelaySet(set))
+	if err != nil || parsed.Identifier != "fast" || parsed.Title != "" {
+		t.Fatalf("relay set didn't roundtrip: %v %v", parsed, err)
+	}
+	if !reflect.DeepEqual(parsed.Relays, []string{"wss://relay.example.com", "wss://other.example.com"}) {
+		t.Errorf("wrong relays: %v", parsed.Relays)
+	}
+}
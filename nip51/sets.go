@@ -0,0 +1,100 @@
+package nip51
+
+import (
+	"fmt"
+
+	"github.com/fiatjaf/go-nostr"
+)
+
+// FollowSet is a kind-30000 categorized list of people, named by its "d" tag.
+type FollowSet struct {
+	Identifier string
+	Title      string
+	// Members are the pubkeys in the "p" tags.
+	Members []string
+}
+
+// RelaySet is a kind-30002 user-defined list of relays, named by its "d" tag.
+type RelaySet struct {
+	Identifier string
+	Title      string
+	// Relays are the URLs in the "relay" tags.
+	Relays []string
+}
+
+// MakeFollowSet returns an unsigned kind-30000 event with the members.
+func MakeFollowSet(set FollowSet) *nostr.Event {
+	evt := makeSet(KindFollowSet, set.Identifier, set.Title)
+	for _, pubkey := range set.Members {
+		evt.WithTag("p", pubkey)
+	}
+	return evt
+}
+
+// ParseFollowSet reads the members of a kind-30000 event.
+func ParseFollowSet(evt *nostr.Event) (*FollowSet, error) {
+	identifier, title, err := parseSet(evt, KindFollowSet)
+	if err != nil {
+		return nil, err
+	}
+
+	set := &FollowSet{Identifier: identifier, Title: title}
+	for _, tag := range evt.Tags.GetAll("p") {
+		if len(tag) >= 2 && tag[1] != "" {
+			set.Members = append(set.Members, tag[1])
+		}
+	}
+	return set, nil
+}
+
+// MakeRelaySet returns an unsigned kind-30002 event with the relays.
+func MakeRelaySet(set RelaySet) *nostr.Event {
+	evt := makeSet(KindRelaySet, set.Identifier, set.Title)
+	for _, url := range set.Relays {
+		evt.WithTag("relay", url)
+	}
+	return evt
+}
+
+// ParseRelaySet reads the relays of a kind-30002 event, normalizing their
+// URLs and leaving out the invalid ones.
+func ParseRelaySet(evt *nostr.Event) (*RelaySet, error) {
+	identifier, title, err := parseSet(evt, KindRelaySet)
+	if err != nil {
+		return nil, err
+	}
+
+	set := &RelaySet{Identifier: identifier, Title: title}
+	for _, tag := range evt.Tags.GetAll("relay") {
+		if len(tag) < 2 {
+			continue
+		}
+		if url := nostr.NormalizeURL(tag[1]); url != "" {
+			set.Relays = append(set.Relays, url)
+		}
+	}
+	return set, nil
+}
+
+func makeSet(kind int, identifier string, title string) *nostr.Event {
+	evt := nostr.NewEvent(kind, "").WithTag("d", identifier)
+	if title != "" {
+		evt.WithTag("title", title)
+	}
+	return evt
+}
+
+// parseSet checks the kind of a set and returns its "d" and "title" tags, the
+// "d" tag is required as sets are parameterized replaceable events.
+func parseSet(evt *nostr.Event, kind int) (identifier string, title string, err error) {
+	if evt.Kind != kind {
+		return "", "", fmt.Errorf("event kind is %d, not %d", evt.Kind, kind)
+	}
+
+	identifier, ok := evt.GetTagValue("d")
+	if !ok {
+		return "", "", fmt.Errorf("set has no 'd' tag")
+	}
+	title, _ = evt.GetTagValue("title")
+	return identifier, title, nil
+}
@@ -0,0 +1,68 @@
+package nip56
+
+import (
+	"fmt"
+
+	"github.com/fiatjaf/go-nostr"
+)
+
+// reportTypes are the report types defined by NIP-56.
+var reportTypes = map[string]bool{
+	"nudity":        true,
+	"malware":       true,
+	"profanity":     true,
+	"illegal":       true,
+	"spam":          true,
+	"impersonation": true,
+	"other":         true,
+}
+
+// Report is what a kind-1984 event reports.
+type Report struct {
+	PubKey string
+	// EventID is empty when the report is about the user, not an event.
+	EventID string
+	Type    string
+	Reason  string
+}
+
+// MakeReport returns an unsigned kind-1984 report of target with reason as
+// content. If target has no ID only its author is reported. It returns nil if
+// reportType is not one of "nudity", "malware", "profanity", "illegal",
+// "spam", "impersonation" or "other".
+func MakeReport(target *nostr.Event, reportType string, reason string) *nostr.Event {
+	if !reportTypes[reportType] {
+		return nil
+	}
+
+	evt := nostr.NewEvent(nostr.KindReporting, reason).WithTag("p", target.PubKey, reportType)
+	if target.ID != "" {
+		evt.WithTag("e", target.ID, reportType)
+	}
+	return evt
+}
+
+// ParseReport reads what a kind-1984 event reports.
+func ParseReport(evt *nostr.Event) (*Report, error) {
+	if evt.Kind != nostr.KindReporting {
+		return nil, fmt.Errorf("event kind is %d, not %d", evt.Kind, nostr.KindReporting)
+	}
+
+	p := evt.Tags.GetFirst("p")
+	if p == nil || len(*p) < 2 {
+		return nil, fmt.Errorf("report has no \"p\" tag")
+	}
+
+	report := &Report{PubKey: (*p)[1], Reason: evt.Content}
+	if len(*p) >= 3 {
+		report.Type = (*p)[2]
+	}
+	if e := evt.Tags.GetFirst("e"); e != nil && len(*e) >= 2 {
+		report.EventID = (*e)[1]
+		if len(*e) >= 3 {
+			report.Type = (*e)[2]
+		}
+	}
+
+	return report, nil
+}
@@ -0,0 +1,26 @@
+package nip56
+
+import (
+	"testing"
+
+	"github.com/fiatjaf/go-nostr"
+)
+
+func TestReport(t *testing.T) {
+	sk := nostr.GeneratePrivateKey()
+	note, _ := nostr.NewEvent(nostr.KindTextNote, "buy my coin").SignWith(sk)
+
+	if MakeReport(note, "boring", "") != nil {
+		t.Error("unknown report type was accepted")
+	}
+
+	report, err := ParseReport(MakeReport(note, "spam", "scam"))
+	if err != nil || report.PubKey != note.PubKey || report.EventID != note.ID || report.Type != "spam" || report.Reason != "scam" {
+		t.Errorf("wrong event report: %v %v", report, err)
+	}
+
+	report, err = ParseReport(MakeReport(&nostr.Event{PubKey: note.PubKey}, "impersonation", ""))
+	if err != nil || report.EventID != "" || report.Type != "impersonation" {
+		t.Errorf("wrong profile report: %v %v", report, err)
+	}
+}
@@ -0,0 +1,120 @@
+package nip57
+
+import (
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/fiatjaf/go-nostr"
+	"github.com/fiatjaf/go-nostr/nip19"
+)
+
+var lnurlClient = &http.Client{Timeout: 10 * time.Second}
+
+// LNURLPayParams is the LUD-06 response of an LNURL-pay endpoint, with the
+// LUD-57 fields of endpoints that accept zaps. Amounts are in millisatoshis.
+type LNURLPayParams struct {
+	Callback       string `json:"callback"`
+	MinSendable    int64  `json:"minSendable"`
+	MaxSendable    int64  `json:"maxSendable"`
+	Metadata       string `json:"metadata"`
+	CommentAllowed int    `json:"commentAllowed,omitempty"`
+
+	// AllowsNostr is true if the endpoint accepts NIP-57 zap requests, in
+	// which case the zap receipts are signed by NostrPubkey.
+	AllowsNostr bool   `json:"allowsNostr,omitempty"`
+	NostrPubkey string `json:"nostrPubkey,omitempty"`
+}
+
+// LNURLPayURL returns the LNURL-pay endpoint of the profile, from its "lud16"
+// lightning address, like "alice@example.com", or else its "lud06" bech32
+// LNURL.
+func LNURLPayURL(p *nostr.Profile) (string, error) {
+	if address := strings.TrimSpace(p.Lud16); address != "" {
+		spl := strings.Split(address, "@")
+		if len(spl) != 2 || spl[0] == "" || spl[1] == "" {
+			return "", fmt.Errorf("invalid lightning address '%s'", address)
+		}
+		return fmt.Sprintf("https://%s/.well-known/lnurlp/%s",
+			strings.ToLower(spl[1]), url.PathEscape(strings.ToLower(spl[0]))), nil
+	}
+
+	if lnurl := strings.TrimSpace(p.Lud06); lnurl != "" {
+		lnurl = strings.TrimPrefix(strings.ToLower(lnurl), "lightning:")
+		prefix, data, err := nip19.DecodeBech32(lnurl)
+		if err != nil {
+			return "", fmt.Errorf("invalid lnurl: %w", err)
+		}
+		if prefix != "lnurl" {
+			return "", fmt.Errorf("invalid lnurl prefix '%s'", prefix)
+		}
+		return string(data), nil
+	}
+
+	return "", fmt.Errorf("profile has no lud16 or lud06")
+}
+
+// ResolveLNURL fetches the LNURL-pay parameters of the profile, see
+// LNURLPayURL, with a request made by client, which has a 10 seconds timeout
+// if nil. Check AllowsNostr before sending a zap request to the callback.
+func ResolveLNURL(ctx context.Context, profile *nostr.Profile, client *http.Client) (*LNURLPayParams, error) {
+	endpoint, err := LNURLPayURL(profile)
+	if err != nil {
+		return nil, err
+	}
+	if client == nil {
+		client = lnurlClient
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "GET", endpoint, nil)
+	if err != nil {
+		return nil, fmt.Errorf("invalid lnurl endpoint '%s': %w", endpoint, err)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch lnurl endpoint '%s': %w", endpoint, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != 200 {
+		return nil, fmt.Errorf("lnurl endpoint '%s' returned status %d", endpoint, resp.StatusCode)
+	}
+
+	var result struct {
+		LNURLPayParams
+		Tag    string `json:"tag"`
+		Status string `json:"status"`
+		Reason string `json:"reason"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("failed to decode json response from '%s': %w", endpoint, err)
+	}
+
+	params := &result.LNURLPayParams
+	switch {
+	case strings.EqualFold(result.Status, "ERROR"):
+		return nil, fmt.Errorf("lnurl endpoint '%s' returned an error: %s", endpoint, result.Reason)
+	case result.Tag != "payRequest":
+		return nil, fmt.Errorf("lnurl endpoint '%s' returned tag '%s', not 'payRequest'", endpoint, result.Tag)
+	case params.Callback == "":
+		return nil, fmt.Errorf("lnurl endpoint '%s' returned no callback", endpoint)
+	case params.MinSendable <= 0 || params.MaxSendable < params.MinSendable:
+		return nil, fmt.Errorf("lnurl endpoint '%s' returned invalid amounts %d-%d", endpoint,
+			params.MinSendable, params.MaxSendable)
+	case params.AllowsNostr && !validPubkey(params.NostrPubkey):
+		return nil, fmt.Errorf("lnurl endpoint '%s' allows nostr with invalid pubkey '%s'", endpoint,
+			params.NostrPubkey)
+	}
+
+	return params, nil
+}
+
+func validPubkey(pubkey string) bool {
+	b, err := hex.DecodeString(pubkey)
+	return err == nil && len(b) == 32
+}
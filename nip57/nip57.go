@@ -0,0 +1,123 @@
+package nip57
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/fiatjaf/go-nostr"
+)
+
+// MakeZapRequest returns an unsigned kind-9734 zap request for amountMsat
+// millisatoshis to recipientPubkey, optionally zapping the event eventID.
+// relays are where the recipient's wallet should publish the zap receipt.
+func MakeZapRequest(recipientPubkey string, amountMsat int64, relays []string, comment string, eventID string) *nostr.Event {
+	evt := nostr.NewEvent(nostr.KindZapRequest, comment).
+		WithTag("relays", relays...).
+		WithTag("amount", strconv.FormatInt(amountMsat, 10)).
+		WithTag("p", recipientPubkey)
+	if eventID != "" {
+		evt.WithTag("e", eventID)
+	}
+	return evt
+}
+
+// ValidateZapReceipt checks a kind-9735 zap receipt and the zap request
+// embedded in its "description" tag, returning the amount zapped and the
+// pubkey of who sent the zap. If both the bolt11 invoice and the request
+// specify an amount they must be the same.
+func ValidateZapReceipt(receipt *nostr.Event) (amountMsat int64, sender string, err error) {
+	if receipt.Kind != nostr.KindZap {
+		return 0, "", fmt.Errorf("event kind is %d, not %d", receipt.Kind, nostr.KindZap)
+	}
+
+	description := receipt.Tags.GetFirst("description")
+	if description == nil || len(*description) < 2 {
+		return 0, "", fmt.Errorf("zap receipt has no description tag")
+	}
+
+	var request nostr.Event
+	if err := json.Unmarshal([]byte((*description)[1]), &request); err != nil {
+		return 0, "", fmt.Errorf("invalid zap request in description: %w", err)
+	}
+	if request.Kind != nostr.KindZapRequest {
+		return 0, "", fmt.Errorf("zap request kind is %d, not %d", request.Kind, nostr.KindZapRequest)
+	}
+	if ok, err := request.CheckSignature(); !ok {
+		return 0, "", fmt.Errorf("invalid zap request signature: %v", err)
+	}
+
+	if p := request.Tags.GetFirst("p"); p == nil || len(*p) < 2 || !receipt.Tags.ContainsAny("p", (*p)[1]) {
+		return 0, "", fmt.Errorf("zap receipt and zap request have different recipients")
+	}
+
+	var requestAmount int64
+	if tag := request.Tags.GetFirst("amount"); tag != nil && len(*tag) >= 2 {
+		requestAmount, err = strconv.ParseInt((*tag)[1], 10, 64)
+		if err != nil {
+			return 0, "", fmt.Errorf("invalid zap request amount '%s'", (*tag)[1])
+		}
+	}
+
+	var invoiceAmount int64
+	if tag := receipt.Tags.GetFirst("bolt11"); tag != nil && len(*tag) >= 2 {
+		invoiceAmount, err = bolt11Amount((*tag)[1])
+		if err != nil {
+			return 0, "", err
+		}
+	}
+
+	switch {
+	case invoiceAmount != 0 && requestAmount != 0 && invoiceAmount != requestAmount:
+		return 0, "", fmt.Errorf("invoice amount %d doesn't match zap request amount %d", invoiceAmount, requestAmount)
+	case invoiceAmount != 0:
+		return invoiceAmount, request.PubKey, nil
+	default:
+		return requestAmount, request.PubKey, nil
+	}
+}
+
+// bolt11Amount reads the amount in millisatoshis from the human-readable
+// part of a bolt11 invoice, 0 means the invoice doesn't specify one.
+func bolt11Amount(invoice string) (int64, error) {
+	invoice = strings.TrimPrefix(strings.ToLower(strings.TrimSpace(invoice)), "lightning:")
+	sep := strings.LastIndexByte(invoice, '1')
+	if !strings.HasPrefix(invoice, "ln") || sep < 0 {
+		return 0, fmt.Errorf("invalid bolt11 invoice")
+	}
+
+	// "ln" + currency prefix + amount + multiplier
+	hrp := invoice[2:sep]
+	start := strings.IndexAny(hrp, "0123456789")
+	if start < 0 {
+		return 0, nil
+	}
+	amount := hrp[start:]
+
+	// millisatoshis per unit of each multiplier, times 10 for pico-bitcoin
+	multiplier := int64(1000000000000)
+	switch amount[len(amount)-1] {
+	case 'm':
+		multiplier = 1000000000
+	case 'u':
+		multiplier = 1000000
+	case 'n':
+		multiplier = 1000
+	case 'p':
+		multiplier = 1
+	}
+	if multiplier != 1000000000000 {
+		amount = amount[:len(amount)-1]
+	}
+
+	value, err := strconv.ParseInt(amount, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid bolt11 amount '%s'", hrp[start:])
+	}
+	if value*multiplier%10 != 0 {
+		return 0, fmt.Errorf("bolt11 amount '%s' is not a whole number of millisatoshis", hrp[start:])
+	}
+
+	return value * multiplier / 10, nil
+}
@@ -0,0 +1,105 @@
+package nip57
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/fiatjaf/go-nostr"
+	"github.com/fiatjaf/go-nostr/nip19"
+)
+
+func TestResolveLNURL(t *testing.T) {
+	pk, _ := nostr.GetPublicKey(nostr.GeneratePrivateKey())
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/.well-known/lnurlp/alice":
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"tag": "payRequest", "callback": "https://example.com/pay", "metadata": "[]",
+				"minSendable": 1000, "maxSendable": 5000000, "allowsNostr": true, "nostrPubkey": pk,
+			})
+		case "/lnurl/bob":
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"tag": "payRequest", "callback": "https://example.com/bob", "metadata": "[]",
+				"minSendable": 1000, "maxSendable": 1000,
+			})
+		case "/.well-known/lnurlp/broken":
+			json.NewEncoder(w).Encode(map[string]interface{}{"tag": "payRequest", "minSendable": 1000, "maxSendable": 1000})
+		case "/.well-known/lnurlp/failing":
+			json.NewEncoder(w).Encode(map[string]interface{}{"status": "ERROR", "reason": "no such user"})
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+	host := server.Listener.Addr().String()
+	ctx := context.Background()
+
+	params, err := ResolveLNURL(ctx, &nostr.Profile{Lud16: "Alice@" + host}, server.Client())
+	if err != nil || params.Callback != "https://example.com/pay" || params.MaxSendable != 5000000 ||
+		!params.AllowsNostr || params.NostrPubkey != pk {
+		t.Errorf("failed to resolve lightning address: %v %v", params, err)
+	}
+
+	lnurl, _ := nip19.EncodeBech32("lnurl", []byte(server.URL+"/lnurl/bob"))
+	params, err = ResolveLNURL(ctx, &nostr.Profile{Lud06: strings.ToUpper(lnurl)}, server.Client())
+	if err != nil || params.Callback != "https://example.com/bob" || params.AllowsNostr {
+		t.Errorf("failed to resolve lnurl: %v %v", params, err)
+	}
+
+	for _, profile := range []*nostr.Profile{
+		{},
+		{Lud16: "nobody@" + host},
+		{Lud16: "broken@" + host},
+		{Lud16: "failing@" + host},
+		{Lud16: "not-an-address"},
+		{Lud06: "npub1" + lnurl[6:]},
+	} {
+		if params, err := ResolveLNURL(ctx, profile, server.Client()); err == nil {
+			t.Errorf("resolved %v to %v", profile, params)
+		}
+	}
+}
+
+func TestValidateZapReceipt(t *testing.T) {
+	senderKey := nostr.GeneratePrivateKey()
+	recipientKey := nostr.GeneratePrivateKey()
+	recipient, _ := nostr.GetPublicKey(recipientKey)
+	sender, _ := nostr.GetPublicKey(senderKey)
+
+	request, _ := MakeZapRequest(recipient, 1000000, []string{"wss://relay.example.com"}, "great post", "").SignWith(senderKey)
+	description, _ := json.Marshal(request)
+
+	receipt := nostr.NewEvent(nostr.KindZap, "").
+		WithTag("p", recipient).
+		WithTag("bolt11", "lnbc10u1pjxyzqqpp5qqqsyqcyq5rqwzqfqqqsyqcyq5rqwzqfqqqsyqcyq5rqwzqfqypq").
+		WithTag("description", string(description))
+
+	amount, from, err := ValidateZapReceipt(receipt)
+	if err != nil || amount != 1000000 || from != sender {
+		t.Errorf("valid zap receipt: got %d %s %v", amount, from, err)
+	}
+
+	request.Tags[1] = nostr.Tag{"amount", "2000000"}
+	request.Sign(senderKey)
+	description, _ = json.Marshal(request)
+	receipt.Tags[2] = nostr.Tag{"description", string(description)}
+	if _, _, err := ValidateZapReceipt(receipt); err == nil {
+		t.Error("zap receipt with a mismatched amount was accepted")
+	}
+
+	for invoice, expected := range map[string]int64{
+		"lnbc1pvjluez":       0,
+		"lnbc2500u1pvjluez":  250000000,
+		"lnbc20m1pvjluez":    2000000000,
+		"lntb10n1pvjluez":    1000,
+		"lnbc9678785340p1pw": 967878534,
+	} {
+		if amount, err := bolt11Amount(invoice); err != nil || amount != expected {
+			t.Errorf("bolt11 %s: got %d %v, expected %d", invoice, amount, err, expected)
+		}
+	}
+}
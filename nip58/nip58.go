@@ -0,0 +1,142 @@
+package nip58
+
+import (
+	"fmt"
+
+	"github.com/fiatjaf/go-nostr"
+)
+
+const (
+	KindBadgeAward      = 8
+	KindProfileBadges   = 30008
+	KindBadgeDefinition = 30009
+)
+
+// BadgeDefinition is the content of a kind-30009 event. Identifier is its "d"
+// tag, all the other fields are optional.
+type BadgeDefinition struct {
+	Identifier  string
+	Name        string
+	Description string
+	Image       string
+	Thumb       string
+}
+
+// AcceptedBadge is a badge that was awarded to someone and that they chose to
+// display in their kind-30008 profile badges event.
+type AcceptedBadge struct {
+	// Address is the "30009:<pubkey>:<d tag>" coordinates of the badge
+	// definition.
+	Address      string
+	AwardEventID string
+	Relay        string
+}
+
+// MakeBadgeDefinition returns an unsigned kind-30009 event defining a badge.
+func MakeBadgeDefinition(def BadgeDefinition) *nostr.Event {
+	evt := nostr.NewEvent(KindBadgeDefinition, "").WithTag("d", def.Identifier)
+	for _, field := range []struct{ name, value string }{
+		{"name", def.Name},
+		{"description", def.Description},
+		{"image", def.Image},
+		{"thumb", def.Thumb},
+	} {
+		if field.value != "" {
+			evt.WithTag(field.name, field.value)
+		}
+	}
+	return evt
+}
+
+// ParseBadgeDefinition reads a kind-30009 event.
+func ParseBadgeDefinition(evt *nostr.Event) (*BadgeDefinition, error) {
+	if evt.Kind != KindBadgeDefinition {
+		return nil, fmt.Errorf("event kind is %d, not %d", evt.Kind, KindBadgeDefinition)
+	}
+
+	identifier, ok := evt.GetTagValue("d")
+	if !ok {
+		return nil, fmt.Errorf("badge definition has no 'd' tag")
+	}
+	def := &BadgeDefinition{Identifier: identifier}
+	def.Name, _ = evt.GetTagValue("name")
+	def.Description, _ = evt.GetTagValue("description")
+	def.Image, _ = evt.GetTagValue("image")
+	def.Thumb, _ = evt.GetTagValue("thumb")
+	return def, nil
+}
+
+// MakeBadgeAward returns an unsigned kind-8 event awarding the badge defined at
+// badgeAddr to the recipients pubkeys.
+func MakeBadgeAward(badgeAddr string, recipients []string) *nostr.Event {
+	evt := nostr.NewEvent(KindBadgeAward, "").WithTag("a", badgeAddr)
+	for _, pubkey := range recipients {
+		evt.WithTag("p", pubkey)
+	}
+	return evt
+}
+
+// ParseBadgeAward returns the badge definition address and the recipients of a
+// kind-8 event.
+func ParseBadgeAward(evt *nostr.Event) (badgeAddr string, recipients []string, err error) {
+	if evt.Kind != KindBadgeAward {
+		return "", nil, fmt.Errorf("event kind is %d, not %d", evt.Kind, KindBadgeAward)
+	}
+
+	tag := evt.Tags.GetFirst("a")
+	if tag == nil || len(*tag) < 2 {
+		return "", nil, fmt.Errorf("badge award has no 'a' tag")
+	}
+	badgeAddr = (*tag)[1]
+
+	for _, tag := range evt.Tags.GetAll("p") {
+		if len(tag) >= 2 {
+			recipients = append(recipients, tag[1])
+		}
+	}
+	if len(recipients) == 0 {
+		return "", nil, fmt.Errorf("badge award has no 'p' tags")
+	}
+
+	return badgeAddr, recipients, nil
+}
+
+// ParseProfileBadges returns the badges accepted in a kind-30008 event, each
+// one an "a" tag pointing to the definition followed by an "e" tag pointing to
+// the award.
+func ParseProfileBadges(evt *nostr.Event) ([]AcceptedBadge, error) {
+	if evt.Kind != KindProfileBadges {
+		return nil, fmt.Errorf("event kind is %d, not %d", evt.Kind, KindProfileBadges)
+	}
+
+	var badges []AcceptedBadge
+	var pending *AcceptedBadge
+	for i, tag := range evt.Tags {
+		if len(tag) < 2 || (tag[0] != "a" && tag[0] != "e") {
+			continue
+		}
+
+		switch tag[0] {
+		case "a":
+			if pending != nil {
+				return nil, fmt.Errorf("badge '%s' is not followed by its award 'e' tag", pending.Address)
+			}
+			pending = &AcceptedBadge{Address: tag[1]}
+		case "e":
+			if pending == nil {
+				return nil, fmt.Errorf("award 'e' tag '%s' at position %d is not preceded by a badge 'a' tag", tag[1], i)
+			}
+			pending.AwardEventID = tag[1]
+			if len(tag) >= 3 {
+				pending.Relay = tag[2]
+			}
+			badges = append(badges, *pending)
+			pending = nil
+		}
+	}
+	if pending != nil {
+		return nil, fmt.Errorf("badge '%s' is not followed by its award 'e' tag", pending.Address)
+	}
+
+	return badges, nil
+}
@@ -0,0 +1,63 @@
+package nip58
+
+import (
+	"testing"
+
+	"github.com/fiatjaf/go-nostr"
+)
+
+const badgeAddr = "30009:3bf0c63fcb93463407af97a5e5ee64fa883d107ef9e558472c4eb9aaaefa459d:bravery"
+
+func TestBadgeDefinition(t *testing.T) {
+	def := BadgeDefinition{Identifier: "bravery", Name: "Medal of Bravery", Image: "https://example.com/medal.png"}
+	parsed, err := ParseBadgeDefinition(MakeBadgeDefinition(def))
+	if err != nil || *parsed != def {
+		t.Errorf("badge definition didn't roundtrip: %v %v", parsed, err)
+	}
+
+	if _, err := ParseBadgeDefinition(nostr.NewEvent(KindBadgeDefinition, "")); err == nil {
+		t.Error("badge definition without 'd' tag was accepted")
+	}
+}
+
+func TestBadgeAward(t *testing.T) {
+	recipients := []string{"alice", "bob"}
+	addr, parsed, err := ParseBadgeAward(MakeBadgeAward(badgeAddr, recipients))
+	if err != nil || addr != badgeAddr || len(parsed) != 2 || parsed[0] != "alice" || parsed[1] != "bob" {
+		t.Errorf("badge award didn't roundtrip: %s %v %v", addr, parsed, err)
+	}
+
+	if _, _, err := ParseBadgeAward(MakeBadgeAward(badgeAddr, nil)); err == nil {
+		t.Error("badge award without recipients was accepted")
+	}
+	if _, _, err := ParseBadgeAward(nostr.NewEvent(KindBadgeAward, "").WithTag("p", "alice")); err == nil {
+		t.Error("badge award without badge was accepted")
+	}
+}
+
+func TestParseProfileBadges(t *testing.T) {
+	evt := nostr.NewEvent(KindProfileBadges, "").
+		WithTag("d", "profile_badges").
+		WithTag("a", badgeAddr).
+		WithTag("e", "award1", "wss://relay.example.com").
+		WithTag("a", "30009:other:speed").
+		WithTag("e", "award2")
+
+	badges, err := ParseProfileBadges(evt)
+	if err != nil || len(badges) != 2 {
+		t.Fatalf("wrong badges: %v %v", badges, err)
+	}
+	if badges[0] != (AcceptedBadge{badgeAddr, "award1", "wss://relay.example.com"}) || badges[1].AwardEventID != "award2" {
+		t.Errorf("wrong badges: %v", badges)
+	}
+
+	for name, evt := range map[string]*nostr.Event{
+		"missing award": nostr.NewEvent(KindProfileBadges, "").WithTag("a", badgeAddr),
+		"two badges":    nostr.NewEvent(KindProfileBadges, "").WithTag("a", badgeAddr).WithTag("a", badgeAddr).WithTag("e", "award"),
+		"award first":   nostr.NewEvent(KindProfileBadges, "").WithTag("e", "award").WithTag("a", badgeAddr),
+	} {
+		if _, err := ParseProfileBadges(evt); err == nil {
+			t.Errorf("%s: mismatched pairs were accepted", name)
+		}
+	}
+}
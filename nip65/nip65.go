@@ -0,0 +1,73 @@
+package nip65
+
+import (
+	"fmt"
+
+	"github.com/fiatjaf/go-nostr"
+)
+
+const KindRelayListMetadata = 10002
+
+// RelayEntry is one of the "r" tags of a kind-10002 relay list, it is the
+// same type the relay pool uses for routing.
+type RelayEntry = nostr.RelayEntry
+
+// ParseRelayList returns the relays in the "r" tags of a kind-10002 event.
+// Relays without a "read" or "write" marker are used for both.
+func ParseRelayList(evt *nostr.Event) ([]RelayEntry, error) {
+	if evt.Kind != KindRelayListMetadata {
+		return nil, fmt.Errorf("event kind is %d, not %d", evt.Kind, KindRelayListMetadata)
+	}
+
+	entries := make([]RelayEntry, 0, len(evt.Tags))
+	for _, tag := range evt.Tags.GetAll("r") {
+		if len(tag) < 2 {
+			continue
+		}
+		url := nostr.NormalizeURL(tag[1])
+		if url == "" {
+			continue
+		}
+
+		entry := RelayEntry{URL: url, Read: true, Write: true}
+		if len(tag) >= 3 {
+			switch tag[2] {
+			case "read":
+				entry.Write = false
+			case "write":
+				entry.Read = false
+			}
+		}
+		entries = append(entries, entry)
+	}
+
+	return entries, nil
+}
+
+// MakeRelayList returns an unsigned kind-10002 event with the relays. Entries
+// that are neither read nor write are left out.
+func MakeRelayList(entries []RelayEntry) *nostr.Event {
+	evt := nostr.NewEvent(KindRelayListMetadata, "")
+	for _, entry := range entries {
+		switch {
+		case entry.Read && entry.Write:
+			evt.WithTag("r", entry.URL)
+		case entry.Read:
+			evt.WithTag("r", entry.URL, "read")
+		case entry.Write:
+			evt.WithTag("r", entry.URL, "write")
+		}
+	}
+	return evt
+}
+
+// CacheRelayList parses a kind-10002 event and caches it in the pool as the
+// relay list of its author, for the outbox methods like PublishToWriteRelays.
+func CacheRelayList(pool *nostr.RelayPool, evt *nostr.Event) error {
+	entries, err := ParseRelayList(evt)
+	if err != nil {
+		return err
+	}
+	pool.SetRelayList(evt.PubKey, entries)
+	return nil
+}
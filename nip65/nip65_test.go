@@ -0,0 +1,49 @@
+package nip65
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/fiatjaf/go-nostr"
+)
+
+func TestRelayList(t *testing.T) {
+	evt := &nostr.Event{
+		Kind: KindRelayListMetadata,
+		Tags: nostr.Tags{
+			{"r", "wss://both.example.com"},
+			{"r", "wss://read.example.com", "read"},
+			{"r", "WSS://Write.Example.com/", "write"},
+			{"r", "wss://other.example.com", "unknown"},
+			{"r"},
+			{"r", "not a url"},
+			{"p", "wss://ignored.example.com"},
+		},
+	}
+
+	entries, err := ParseRelayList(evt)
+	if err != nil {
+		t.Fatalf("failed to parse relay list: %v", err)
+	}
+	expected := []RelayEntry{
+		{URL: "wss://both.example.com", Read: true, Write: true},
+		{URL: "wss://read.example.com", Read: true, Write: false},
+		{URL: "wss://write.example.com", Read: false, Write: true},
+		{URL: "wss://other.example.com", Read: true, Write: true},
+	}
+	if !reflect.DeepEqual(entries, expected) {
+		t.Errorf("wrong entries: %v", entries)
+	}
+
+	made := MakeRelayList(append(expected, RelayEntry{URL: "wss://neither.example.com"}))
+	if made.Kind != KindRelayListMetadata || len(made.Tags) != 4 {
+		t.Fatalf("wrong relay list event: %v", made)
+	}
+	if entries, err := ParseRelayList(made); err != nil || !reflect.DeepEqual(entries, expected) {
+		t.Errorf("relay list didn't round trip: %v %v", entries, err)
+	}
+
+	if _, err := ParseRelayList(&nostr.Event{Kind: 3}); err == nil {
+		t.Error("parsed a relay list from a kind 3 event")
+	}
+}
@@ -0,0 +1,87 @@
+package nip78
+
+import (
+	"fmt"
+
+	"github.com/fiatjaf/go-nostr"
+	"github.com/fiatjaf/go-nostr/nip04"
+)
+
+const KindApplicationSpecificData = 30078
+
+// MakeAppData returns an unsigned kind-30078 event holding arbitrary data for
+// the app, identified by its "d" tag so each app keeps a single event.
+func MakeAppData(appIdentifier string, content string) *nostr.Event {
+	return nostr.NewEvent(KindApplicationSpecificData, content).
+		WithTag("d", appIdentifier)
+}
+
+// ParseAppData returns the app identifier and the content of a kind-30078
+// event. The content is returned as is, see DecryptAppData.
+func ParseAppData(evt *nostr.Event) (identifier, content string, err error) {
+	if evt.Kind != KindApplicationSpecificData {
+		return "", "", fmt.Errorf("event kind is %d, not %d", evt.Kind, KindApplicationSpecificData)
+	}
+
+	tag := evt.Tags.GetFirst("d")
+	if tag == nil || len(*tag) < 2 {
+		return "", "", fmt.Errorf("app data event has no 'd' tag")
+	}
+
+	return (*tag)[1], evt.Content, nil
+}
+
+// MakeEncryptedAppData is like MakeAppData, but the content is encrypted with
+// NIP-04 to the author, whose key is privateKey, so only they can read it.
+func MakeEncryptedAppData(appIdentifier string, content string, privateKey string) (*nostr.Event, error) {
+	pubkey, sharedSecret, err := selfSecret(privateKey)
+	if err != nil {
+		return nil, err
+	}
+
+	ciphertext, err := nip04.Encrypt(content, sharedSecret)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encrypt app data: %w", err)
+	}
+
+	evt := MakeAppData(appIdentifier, ciphertext)
+	evt.PubKey = pubkey
+	return evt, nil
+}
+
+// DecryptAppData is like ParseAppData for events made with
+// MakeEncryptedAppData, privateKey must be the author's.
+func DecryptAppData(evt *nostr.Event, privateKey string) (identifier, content string, err error) {
+	identifier, ciphertext, err := ParseAppData(evt)
+	if err != nil {
+		return "", "", err
+	}
+
+	pubkey, sharedSecret, err := selfSecret(privateKey)
+	if err != nil {
+		return "", "", err
+	}
+	if pubkey != evt.PubKey {
+		return "", "", fmt.Errorf("app data can only be decrypted by its author")
+	}
+
+	content, err = nip04.Decrypt(ciphertext, sharedSecret)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to decrypt app data: %w", err)
+	}
+	return identifier, content, nil
+}
+
+// selfSecret returns the public key for privateKey and the NIP-04 shared
+// secret used to encrypt data to that same key.
+func selfSecret(privateKey string) (string, []byte, error) {
+	pubkey, err := nostr.GetPublicKey(privateKey)
+	if err != nil {
+		return "", nil, fmt.Errorf("invalid private key: %w", err)
+	}
+	sharedSecret, err := nip04.ComputeSharedSecret(privateKey, pubkey)
+	if err != nil {
+		return "", nil, err
+	}
+	return pubkey, sharedSecret, nil
+}
@@ -0,0 +1,47 @@
+package nip78
+
+import (
+	"testing"
+
+	"github.com/fiatjaf/go-nostr"
+)
+
+func TestAppData(t *testing.T) {
+	sk := nostr.GeneratePrivateKey()
+	evt, err := MakeAppData("my-app", `{"theme":"dark"}`).SignWith(sk)
+	if err != nil {
+		t.Fatalf("failed to sign: %v", err)
+	}
+	identifier, content, err := ParseAppData(evt)
+	if err != nil || identifier != "my-app" || content != `{"theme":"dark"}` {
+		t.Errorf("app data didn't roundtrip: %s %s %v", identifier, content, err)
+	}
+
+	if _, _, err := ParseAppData(nostr.NewEvent(KindApplicationSpecificData, "")); err == nil {
+		t.Error("app data without 'd' tag was accepted")
+	}
+	if _, _, err := ParseAppData(nostr.NewEvent(nostr.KindTextNote, "").WithTag("d", "my-app")); err == nil {
+		t.Error("app data of the wrong kind was accepted")
+	}
+}
+
+func TestEncryptedAppData(t *testing.T) {
+	sk := nostr.GeneratePrivateKey()
+	evt, err := MakeEncryptedAppData("my-app", "secret settings", sk)
+	if err != nil {
+		t.Fatalf("failed to encrypt: %v", err)
+	}
+	if evt.Content == "secret settings" {
+		t.Error("content wasn't encrypted")
+	}
+	evt, _ = evt.SignWith(sk)
+
+	identifier, content, err := DecryptAppData(evt, sk)
+	if err != nil || identifier != "my-app" || content != "secret settings" {
+		t.Errorf("encrypted app data didn't roundtrip: %s %s %v", identifier, content, err)
+	}
+
+	if _, _, err := DecryptAppData(evt, nostr.GeneratePrivateKey()); err == nil {
+		t.Error("app data was decrypted by someone else")
+	}
+}
@@ -0,0 +1,27 @@
+package nip89
+
+import "github.com/fiatjaf/go-nostr"
+
+// SetClient sets the "client" tag with the name of the client that published
+// the event and, optionally, the "31990:<pubkey>:<d tag>" address of its
+// handler information event, replacing any previous one.
+func SetClient(evt *nostr.Event, name string, handlerAddr string) {
+	if handlerAddr == "" {
+		evt.SetTagValue("client", name)
+	} else {
+		evt.SetTagValue("client", name, handlerAddr)
+	}
+}
+
+// Client returns the name and the handler address from the "client" tag, ok
+// is false if there is none.
+func Client(evt *nostr.Event) (name, addr string, ok bool) {
+	tag := evt.Tags.GetFirst("client")
+	if tag == nil || len(*tag) < 2 {
+		return "", "", false
+	}
+	if len(*tag) >= 3 {
+		addr = (*tag)[2]
+	}
+	return (*tag)[1], addr, true
+}
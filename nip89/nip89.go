@@ -0,0 +1,148 @@
+package nip89
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+
+	"github.com/fiatjaf/go-nostr"
+)
+
+const (
+	KindHandlerRecommendation = 31989
+	KindHandlerInformation    = 31990
+)
+
+// platforms are the tags of a handler information event that hold the URLs
+// to open events with.
+var platforms = []string{"web", "ios", "android"}
+
+// HandlerRef points to a kind-31990 handler information event.
+type HandlerRef struct {
+	// Address is the "31990:<pubkey>:<d tag>" coordinates of the handler.
+	Address  string
+	Relay    string
+	Platform string
+}
+
+// HandlerURL is a URL template to open events with on some platform, like
+// "https://example.com/e/<bech32>", where "<bech32>" is replaced by the event
+// encoded as Entity, like "nevent", or by any entity if that is empty.
+type HandlerURL struct {
+	Platform string
+	URL      string
+	Entity   string
+}
+
+// HandlerInfo is an application that can handle events of some kinds, from a
+// kind-31990 event.
+type HandlerInfo struct {
+	Identifier string
+
+	// Profile is the application metadata, or nil if it should be taken from
+	// the author profile.
+	Profile *nostr.Profile
+
+	Kinds []int
+	URLs  []HandlerURL
+}
+
+// MakeHandlerRecommendation returns an unsigned kind-31989 event recommending
+// handlers for events of the given kind.
+func MakeHandlerRecommendation(kind int, handlers []HandlerRef) *nostr.Event {
+	evt := nostr.NewEvent(KindHandlerRecommendation, "").
+		WithTag("d", strconv.Itoa(kind))
+	for _, handler := range handlers {
+		evt.WithTag("a", handler.Address, handler.Relay, handler.Platform)
+	}
+	return evt
+}
+
+// ParseHandlerRecommendation returns the kind and the handlers recommended by
+// a kind-31989 event.
+func ParseHandlerRecommendation(evt *nostr.Event) (kind int, handlers []HandlerRef, err error) {
+	if evt.Kind != KindHandlerRecommendation {
+		return 0, nil, fmt.Errorf("event kind is %d, not %d", evt.Kind, KindHandlerRecommendation)
+	}
+
+	tag := evt.Tags.GetFirst("d")
+	if tag == nil || len(*tag) < 2 {
+		return 0, nil, fmt.Errorf("handler recommendation has no 'd' tag")
+	}
+	kind, err = strconv.Atoi((*tag)[1])
+	if err != nil {
+		return 0, nil, fmt.Errorf("handler recommendation 'd' tag '%s' is not a kind: %w", (*tag)[1], err)
+	}
+
+	for _, tag := range evt.Tags.GetAll("a") {
+		if len(tag) < 2 {
+			continue
+		}
+		handler := HandlerRef{Address: tag[1]}
+		if len(tag) >= 3 {
+			handler.Relay = tag[2]
+		}
+		if len(tag) >= 4 {
+			handler.Platform = tag[3]
+		}
+		handlers = append(handlers, handler)
+	}
+
+	return kind, handlers, nil
+}
+
+// ParseHandlerInfo reads a kind-31990 handler information event.
+func ParseHandlerInfo(evt *nostr.Event) (*HandlerInfo, error) {
+	if evt.Kind != KindHandlerInformation {
+		return nil, fmt.Errorf("event kind is %d, not %d", evt.Kind, KindHandlerInformation)
+	}
+
+	tag := evt.Tags.GetFirst("d")
+	if tag == nil || len(*tag) < 2 {
+		return nil, fmt.Errorf("handler information has no 'd' tag")
+	}
+	info := &HandlerInfo{Identifier: (*tag)[1]}
+
+	if evt.Content != "" {
+		info.Profile = &nostr.Profile{}
+		if err := json.Unmarshal([]byte(evt.Content), info.Profile); err != nil {
+			return nil, fmt.Errorf("failed to parse handler metadata: %w", err)
+		}
+	}
+
+	for _, tag := range evt.Tags.GetAll("k") {
+		if len(tag) < 2 {
+			continue
+		}
+		kind, err := strconv.Atoi(tag[1])
+		if err != nil {
+			return nil, fmt.Errorf("invalid 'k' tag '%s': %w", tag[1], err)
+		}
+		info.Kinds = append(info.Kinds, kind)
+	}
+
+	for _, platform := range platforms {
+		for _, tag := range evt.Tags.GetAll(platform) {
+			if len(tag) < 2 {
+				continue
+			}
+			u := HandlerURL{Platform: platform, URL: tag[1]}
+			if len(tag) >= 3 {
+				u.Entity = tag[2]
+			}
+			info.URLs = append(info.URLs, u)
+		}
+	}
+
+	return info, nil
+}
+
+// Handles checks if the application can handle events of the given kind.
+func (info *HandlerInfo) Handles(kind int) bool {
+	for _, k := range info.Kinds {
+		if k == kind {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,80 @@
+package nip89
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/fiatjaf/go-nostr"
+)
+
+func TestClient(t *testing.T) {
+	evt := nostr.NewEvent(nostr.KindTextNote, "hello").WithTag("t", "nostr")
+	if _, _, ok := Client(evt); ok {
+		t.Error("event without a client tag has a client")
+	}
+
+	SetClient(evt, "Old Client", "")
+	shared := *evt
+	SetClient(evt, "New Client", "31990:3bf0c63fcb93463407af97a5e5ee64fa883d107ef9e558472c4eb9aaaefa459d:app")
+	if len(evt.Tags.GetAll("client")) != 1 || len(evt.Tags) != 2 {
+		t.Errorf("client tag wasn't replaced: %v", evt.Tags)
+	}
+	if name, addr, ok := Client(evt); !ok || name != "New Client" || addr == "" {
+		t.Errorf("wrong client: %s %s %v", name, addr, ok)
+	}
+	if name, _, _ := Client(&shared); name != "Old Client" {
+		t.Errorf("setting the client changed a copy of the event: %s", name)
+	}
+
+	SetClient(evt, "Plain Client", "")
+	if tag := evt.Tags.GetFirst("client"); len(*tag) != 2 {
+		t.Errorf("client tag without a handler should have 2 items: %v", *tag)
+	}
+}
+
+func TestHandlerRecommendation(t *testing.T) {
+	handlers := []HandlerRef{
+		{Address: "31990:3bf0c63fcb93463407af97a5e5ee64fa883d107ef9e558472c4eb9aaaefa459d:app", Relay: "wss://relay.example.com", Platform: "web"},
+		{Address: "31990:3bf0c63fcb93463407af97a5e5ee64fa883d107ef9e558472c4eb9aaaefa459d:other", Relay: "", Platform: "ios"},
+	}
+	kind, parsed, err := ParseHandlerRecommendation(MakeHandlerRecommendation(nostr.KindArticle, handlers))
+	if err != nil || kind != nostr.KindArticle || len(parsed) != 2 || parsed[0] != handlers[0] || parsed[1] != handlers[1] {
+		t.Errorf("recommendation didn't roundtrip: %d %v %v", kind, parsed, err)
+	}
+
+	if _, _, err := ParseHandlerRecommendation(nostr.NewEvent(KindHandlerRecommendation, "").WithTag("d", "x")); err == nil {
+		t.Error("recommendation for a kind that isn't a number was accepted")
+	}
+}
+
+func TestHandlerInfo(t *testing.T) {
+	metadata, _ := json.Marshal(nostr.Profile{Name: "Reader"})
+	evt := nostr.NewEvent(KindHandlerInformation, string(metadata)).
+		WithTag("d", "reader").
+		WithTag("k", "30023").
+		WithTag("k", "1").
+		WithTag("web", "https://reader.example.com/a/<bech32>", "naddr").
+		WithTag("web", "https://reader.example.com/<bech32>").
+		WithTag("android", "reader://<bech32>")
+
+	info, err := ParseHandlerInfo(evt)
+	if err != nil {
+		t.Fatalf("failed to parse: %v", err)
+	}
+	if info.Identifier != "reader" || info.Profile == nil || info.Profile.Name != "Reader" {
+		t.Errorf("wrong identifier or profile: %v", info)
+	}
+	if !info.Handles(nostr.KindArticle) || !info.Handles(nostr.KindTextNote) || info.Handles(nostr.KindReaction) {
+		t.Errorf("wrong kinds: %v", info.Kinds)
+	}
+	if len(info.URLs) != 3 || info.URLs[0].Entity != "naddr" || info.URLs[1].Entity != "" || info.URLs[2].Platform != "android" {
+		t.Errorf("wrong urls: %v", info.URLs)
+	}
+
+	if info, err := ParseHandlerInfo(nostr.NewEvent(KindHandlerInformation, "").WithTag("d", "bare")); err != nil || info.Profile != nil {
+		t.Errorf("handler without metadata should use the author profile: %v %v", info, err)
+	}
+	if _, err := ParseHandlerInfo(nostr.NewEvent(KindHandlerInformation, "").WithTag("d", "x").WithTag("k", "one")); err == nil {
+		t.Error("invalid 'k' tag was accepted")
+	}
+}
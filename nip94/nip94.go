@@ -0,0 +1,68 @@
+package nip94
+
+import (
+	"fmt"
+
+	"github.com/fiatjaf/go-nostr"
+)
+
+// FileMetadata is what a kind-1063 event describes, all fields but URL are
+// optional.
+type FileMetadata struct {
+	URL        string
+	MimeType   string
+	Hash       string
+	Size       string
+	Dimensions string
+	Magnet     string
+	Blurhash   string
+}
+
+// ParseFileMetadata reads the tags of a kind-1063 event.
+func ParseFileMetadata(evt *nostr.Event) (*FileMetadata, error) {
+	if evt.Kind != nostr.KindFileMetadata {
+		return nil, fmt.Errorf("event kind is %d, not %d", evt.Kind, nostr.KindFileMetadata)
+	}
+
+	var f FileMetadata
+	for _, field := range f.fields() {
+		if tag := evt.Tags.GetFirst(field.name); tag != nil && len(*tag) >= 2 {
+			*field.value = (*tag)[1]
+		}
+	}
+
+	if f.URL == "" {
+		return nil, fmt.Errorf("file metadata has no \"url\" tag")
+	}
+	return &f, nil
+}
+
+// ToEvent returns an unsigned kind-1063 event with a tag for each field that
+// is set.
+func (f *FileMetadata) ToEvent() *nostr.Event {
+	evt := nostr.NewEvent(nostr.KindFileMetadata, "")
+	for _, field := range f.fields() {
+		if *field.value != "" {
+			evt.WithTag(field.name, *field.value)
+		}
+	}
+	return evt
+}
+
+type fileMetadataField struct {
+	name  string
+	value *string
+}
+
+// fields maps each tag name to the field it is read into.
+func (f *FileMetadata) fields() []fileMetadataField {
+	return []fileMetadataField{
+		{"url", &f.URL},
+		{"m", &f.MimeType},
+		{"x", &f.Hash},
+		{"size", &f.Size},
+		{"dim", &f.Dimensions},
+		{"magnet", &f.Magnet},
+		{"blurhash", &f.Blurhash},
+	}
+}
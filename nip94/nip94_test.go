@@ -0,0 +1,19 @@
+package nip94
+
+import (
+	"testing"
+
+	"github.com/fiatjaf/go-nostr"
+)
+
+func TestFileMetadata(t *testing.T) {
+	f := FileMetadata{URL: "https://example.com/cat.png", MimeType: "image/png", Dimensions: "800x600"}
+	parsed, err := ParseFileMetadata(f.ToEvent())
+	if err != nil || *parsed != f {
+		t.Errorf("file metadata didn't roundtrip: %v %v", parsed, err)
+	}
+
+	if _, err := ParseFileMetadata(nostr.NewEvent(nostr.KindFileMetadata, "").WithTag("m", "image/png")); err == nil {
+		t.Error("file metadata without url was accepted")
+	}
+}
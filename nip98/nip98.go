@@ -0,0 +1,74 @@
+package nip98
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/fiatjaf/go-nostr"
+)
+
+const KindHTTPAuth = 27235
+
+// MakeHTTPAuth returns an unsigned kind-27235 event authorizing a request to
+// url with the given method. payloadHash is the sha256 hex of the request
+// body, it can be empty.
+func MakeHTTPAuth(url string, method string, payloadHash string) *nostr.Event {
+	evt := nostr.NewEvent(KindHTTPAuth, "").
+		WithTag("u", url).
+		WithTag("method", strings.ToUpper(method))
+	if payloadHash != "" {
+		evt.WithTag("payload", payloadHash)
+	}
+	return evt
+}
+
+// ValidateHTTPAuth checks the "Authorization: Nostr <base64 event>" header of
+// a request to url with the given method and body, returning the pubkey that
+// signed it. The body is only checked if the event has a "payload" tag.
+func ValidateHTTPAuth(authHeader string, url string, method string, body []byte, maxAge time.Duration) (pubkey string, err error) {
+	spl := strings.SplitN(strings.TrimSpace(authHeader), " ", 2)
+	if len(spl) != 2 || spl[0] != "Nostr" {
+		return "", fmt.Errorf("authorization header is not 'Nostr <event>'")
+	}
+
+	raw, err := base64.StdEncoding.DecodeString(strings.TrimSpace(spl[1]))
+	if err != nil {
+		return "", fmt.Errorf("authorization event is not valid base64: %w", err)
+	}
+
+	var evt nostr.Event
+	if err := json.Unmarshal(raw, &evt); err != nil {
+		return "", fmt.Errorf("invalid authorization event: %w", err)
+	}
+	if evt.Kind != KindHTTPAuth {
+		return "", fmt.Errorf("event kind is %d, not %d", evt.Kind, KindHTTPAuth)
+	}
+
+	if u := evt.Tags.GetFirst("u"); u == nil || len(*u) < 2 || (*u)[1] != url {
+		return "", fmt.Errorf("authorization is not for '%s'", url)
+	}
+	if m := evt.Tags.GetFirst("method"); m == nil || len(*m) < 2 || !strings.EqualFold((*m)[1], method) {
+		return "", fmt.Errorf("authorization is not for method %s", method)
+	}
+	if p := evt.Tags.GetFirst("payload"); p != nil && len(*p) >= 2 {
+		hash := sha256.Sum256(body)
+		if !strings.EqualFold((*p)[1], hex.EncodeToString(hash[:])) {
+			return "", fmt.Errorf("authorization payload hash doesn't match the body")
+		}
+	}
+
+	if age := time.Since(evt.CreatedAt.Time()); age > maxAge || age < -maxAge {
+		return "", fmt.Errorf("authorization event created_at is too far from now (%s)", age.Round(time.Second))
+	}
+
+	if err := evt.Validate(); err != nil {
+		return "", fmt.Errorf("invalid authorization event: %w", err)
+	}
+
+	return evt.PubKey, nil
+}
@@ -0,0 +1,88 @@
+package nip98
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/fiatjaf/go-nostr"
+)
+
+const authURL = "https://api.example.com/upload"
+
+func authHeader(t *testing.T, evt *nostr.Event) string {
+	raw, err := json.Marshal(evt)
+	if err != nil {
+		t.Fatalf("failed to encode event: %v", err)
+	}
+	return "Nostr " + base64.StdEncoding.EncodeToString(raw)
+}
+
+func TestValidateHTTPAuth(t *testing.T) {
+	sk := nostr.GeneratePrivateKey()
+	body := []byte(`{"file":"cat.jpg"}`)
+	hash := sha256.Sum256(body)
+
+	evt, err := MakeHTTPAuth(authURL, "post", hex.EncodeToString(hash[:])).SignWith(sk)
+	if err != nil {
+		t.Fatalf("failed to sign: %v", err)
+	}
+	pubkey, err := ValidateHTTPAuth(authHeader(t, evt), authURL, "POST", body, time.Minute)
+	if err != nil || pubkey != evt.PubKey {
+		t.Fatalf("valid authorization was rejected: %s %v", pubkey, err)
+	}
+
+	// without a payload tag the body isn't checked
+	noPayload, _ := MakeHTTPAuth(authURL, "GET", "").SignWith(sk)
+	if _, err := ValidateHTTPAuth(authHeader(t, noPayload), authURL, "get", []byte("anything"), time.Minute); err != nil {
+		t.Errorf("authorization without payload was rejected: %v", err)
+	}
+
+	stale := MakeHTTPAuth(authURL, "POST", hex.EncodeToString(hash[:]))
+	stale.CreatedAt = nostr.Timestamp(time.Now().Add(-time.Hour).Unix())
+	stale, _ = stale.SignWith(sk)
+
+	future := MakeHTTPAuth(authURL, "POST", hex.EncodeToString(hash[:]))
+	future.CreatedAt = nostr.Timestamp(time.Now().Add(time.Hour).Unix())
+	future, _ = future.SignWith(sk)
+
+	wrongKind := MakeHTTPAuth(authURL, "POST", hex.EncodeToString(hash[:]))
+	wrongKind.Kind = nostr.KindTextNote
+	wrongKind, _ = wrongKind.SignWith(sk)
+
+	badSig := *evt
+	if badSig.Sig[0] == '0' {
+		badSig.Sig = "1" + badSig.Sig[1:]
+	} else {
+		badSig.Sig = "0" + badSig.Sig[1:]
+	}
+
+	for _, c := range []struct {
+		name   string
+		header string
+		url    string
+		method string
+		body   []byte
+	}{
+		{"wrong method", authHeader(t, evt), authURL, "PUT", body},
+		{"wrong url", authHeader(t, evt), authURL + "/other", "POST", body},
+		{"payload mismatch", authHeader(t, evt), authURL, "POST", []byte("other body")},
+		{"stale", authHeader(t, stale), authURL, "POST", body},
+		{"future", authHeader(t, future), authURL, "POST", body},
+		{"wrong kind", authHeader(t, wrongKind), authURL, "POST", body},
+		{"bad signature", authHeader(t, &badSig), authURL, "POST", body},
+		{"empty header", "", authURL, "POST", body},
+		{"wrong scheme", strings.Replace(authHeader(t, evt), "Nostr", "Bearer", 1), authURL, "POST", body},
+		{"no event", "Nostr", authURL, "POST", body},
+		{"not base64", "Nostr !!!", authURL, "POST", body},
+		{"not json", "Nostr " + base64.StdEncoding.EncodeToString([]byte("{not json")), authURL, "POST", body},
+	} {
+		if _, err := ValidateHTTPAuth(c.header, c.url, c.method, c.body, time.Minute); err == nil {
+			t.Errorf("%s: authorization was accepted", c.name)
+		}
+	}
+}
@@ -5,8 +5,11 @@ import (
 	"strings"
 )
 
+// NormalizeURL returns the relay URL with a lowercase ws:// or wss:// scheme,
+// a lowercase host and no trailing slash, or "" if it is invalid.
 func NormalizeURL(u string) string {
-	if !strings.HasPrefix(u, "http") && !strings.HasPrefix(u, "ws") {
+	lower := strings.ToLower(u)
+	if !strings.HasPrefix(lower, "http") && !strings.HasPrefix(lower, "ws") {
 		u = "wss://" + u
 	}
 	p, err := url.Parse(u)
@@ -20,9 +23,9 @@ func NormalizeURL(u string) string {
 		p.Scheme = "wss"
 	}
 
-	if strings.HasSuffix(p.RawPath, "/") {
-		p.RawPath = p.RawPath[0 : len(p.RawPath)-1]
-	}
+	p.Host = strings.ToLower(p.Host)
+	p.Path = strings.TrimRight(p.Path, "/")
+	p.RawPath = strings.TrimRight(p.RawPath, "/")
 
 	return p.String()
 }
@@ -0,0 +1,236 @@
+package nostr
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// RelayEntry is one of the relays of a NIP-65 relay list, see the nip65
+// package for reading and writing kind-10002 events.
+type RelayEntry struct {
+	URL   string
+	Read  bool
+	Write bool
+}
+
+// SetRelayList caches the relay list of a pubkey, to be used by the outbox
+// methods when it isn't given. A nil list forgets it.
+func (r *RelayPool) SetRelayList(pubkey string, entries []RelayEntry) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if entries == nil {
+		delete(r.relayLists, pubkey)
+		return
+	}
+	r.relayLists[pubkey] = append([]RelayEntry(nil), entries...)
+}
+
+// RelayList returns the cached relay list of a pubkey.
+func (r *RelayPool) RelayList(pubkey string) ([]RelayEntry, bool) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	entries, ok := r.relayLists[pubkey]
+	return entries, ok
+}
+
+// writeRelays returns the normalized write relays of the list, falling back to
+// the cached list of pubkey and then to DefaultRelays.
+func (r *RelayPool) writeRelays(pubkey string, entries []RelayEntry) []string {
+	if len(entries) == 0 {
+		entries, _ = r.RelayList(pubkey)
+	}
+
+	urls := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		if url := NormalizeURL(entry.URL); entry.Write && url != "" {
+			urls = append(urls, url)
+		}
+	}
+	if len(urls) == 0 {
+		for _, url := range r.DefaultRelays {
+			if url = NormalizeURL(url); url != "" {
+				urls = append(urls, url)
+			}
+		}
+	}
+	return uniqueStrings(urls)
+}
+
+// connection returns the pool relay with the url or else a connection opened
+// just for the outbox methods, which isn't used by Sub or PublishEvent.
+func (r *RelayPool) connection(ctx context.Context, url string) (*Relay, error) {
+	r.mutex.Lock()
+	relay, ok := r.relays[url]
+	if !ok {
+		relay, ok = r.outbox[url]
+	}
+	r.mutex.Unlock()
+	if ok {
+		return relay, nil
+	}
+
+	relay, err := Connect(ctx, url, WithSeenCache(r.seen), WithReconnect(reconnectBaseDelay, reconnectMaxDelay))
+	if err != nil {
+		return nil, err
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	select {
+	case <-r.closed:
+		relay.Close()
+		return nil, errors.New("relay pool is closed")
+	default:
+	}
+	if existing, ok := r.outbox[url]; ok {
+		// someone else connected meanwhile
+		relay.Close()
+		return existing, nil
+	}
+	r.outbox[url] = relay
+	return relay, nil
+}
+
+// PublishToWriteRelays publishes a signed event to the write relays of its
+// author, following the NIP-65 outbox model. These come from relayList or, if
+// it is empty, from the list cached with SetRelayList, or else DefaultRelays.
+// The relays don't need to be in the pool; the statuses are like the ones of
+// PublishEvent, and relays that can't be reached get a PublishStatusFailed.
+func (r *RelayPool) PublishToWriteRelays(ctx context.Context, evt *Event, relayList []RelayEntry) (chan PublishStatus, error) {
+	if evt.PubKey == "" || evt.Sig == "" {
+		return nil, errors.New("PublishToWriteRelays needs a signed event")
+	}
+
+	urls := r.writeRelays(evt.PubKey, relayList)
+	if len(urls) == 0 {
+		return nil, fmt.Errorf("no write relays known for '%s'", evt.PubKey)
+	}
+
+	status := make(chan PublishStatus, len(urls)*2)
+
+	var wg sync.WaitGroup
+	wg.Add(len(urls))
+	for _, url := range urls {
+		go func(url string) {
+			defer wg.Done()
+
+			relay, err := r.connection(ctx, url)
+			if err != nil {
+				status <- PublishStatus{Relay: url, Status: PublishStatusFailed, Reason: err.Error()}
+				return
+			}
+			r.publishTo(ctx, relay, evt, status)
+		}(url)
+	}
+
+	go func() {
+		wg.Wait()
+		close(status)
+	}()
+
+	return status, nil
+}
+
+// QueryWriteRelays fetches the stored events matching the filter from the
+// write relays of its authors, each relay only being asked about the authors
+// that write to it. Filters without authors go to DefaultRelays. Relays that
+// can't be reached or don't send an EOSE within EOSETimeout are skipped; the
+// events are returned newest first and without duplicates.
+func (r *RelayPool) QueryWriteRelays(ctx context.Context, filter Filter) ([]*Event, error) {
+	routes := make(map[string]Filter)
+	if len(filter.Authors) == 0 {
+		for _, url := range r.writeRelays("", nil) {
+			routes[url] = filter
+		}
+	}
+	for _, pubkey := range filter.Authors {
+		for _, url := range r.writeRelays(pubkey, nil) {
+			f, ok := routes[url]
+			if !ok {
+				f = filter
+				f.Authors = nil
+			}
+			f.Authors = append(f.Authors, pubkey)
+			routes[url] = f
+		}
+	}
+	if len(routes) == 0 {
+		return nil, errors.New("no relays to query")
+	}
+
+	eoseTimeout := r.EOSETimeout
+	if eoseTimeout <= 0 {
+		eoseTimeout = defaultEOSETimeout
+	}
+	parent := ctx
+	ctx, cancel := context.WithTimeout(ctx, eoseTimeout)
+	defer cancel()
+
+	var mutex sync.Mutex
+	seen := make(map[string]bool)
+	var events []*Event
+
+	var wg sync.WaitGroup
+	wg.Add(len(routes))
+	for url, f := range routes {
+		go func(url string, f Filter) {
+			defer wg.Done()
+
+			relay, err := r.connection(ctx, url)
+			if err != nil {
+				return
+			}
+			sub, err := relay.Subscribe(ctx, Filters{f}, WithSkipVerification(r.SkipVerification))
+			if err != nil {
+				return
+			}
+			defer sub.Unsub()
+
+			for {
+				select {
+				case em, ok := <-sub.Events:
+					if !ok {
+						return
+					}
+					mutex.Lock()
+					if !seen[em.Event.ID] {
+						seen[em.Event.ID] = true
+						evt := em.Event
+						events = append(events, &evt)
+					}
+					mutex.Unlock()
+				case <-sub.EndOfStoredEvents:
+					return
+				case <-ctx.Done():
+					return
+				}
+			}
+		}(url, f)
+	}
+	wg.Wait()
+	if err := parent.Err(); err != nil {
+		return nil, err
+	}
+
+	sort.SliceStable(events, func(i, j int) bool {
+		return events[i].CreatedAt > events[j].CreatedAt
+	})
+	return events, nil
+}
+
+func uniqueStrings(values []string) []string {
+	seen := make(map[string]bool, len(values))
+	result := values[:0]
+	for _, value := range values {
+		if !seen[value] {
+			seen[value] = true
+			result = append(result, value)
+		}
+	}
+	return result
+}
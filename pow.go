@@ -0,0 +1,175 @@
+package nostr
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math/bits"
+	"strconv"
+)
+
+var (
+	ErrNoPoWCommitment    = errors.New("event has no nonce tag with a target difficulty")
+	ErrPoWBelowRequested  = errors.New("event commits to a difficulty below the requested one")
+	ErrPoWCommitmentUnmet = errors.New("event id doesn't have the committed difficulty")
+)
+
+// Mine sets a NIP-13 ["nonce", "<n>", "<difficulty>"] tag on the event and
+// increments it until the event id has at least difficulty leading zero bits,
+// replacing any previous nonce tag in place. Only the nonce tag and the id
+// change, so the pubkey must be set before and the event signed afterwards,
+// see MineThenSign. Returns ctx.Err() if the context is done before that, in
+// which case the event isn't changed. The difficulty must be between 0 and
+// 256, as ids have no more bits.
+func (evt *Event) Mine(ctx context.Context, difficulty int) error {
+	if difficulty < 0 || difficulty > 256 {
+		return fmt.Errorf("invalid difficulty %d, it must be between 0 and 256", difficulty)
+	}
+	target := strconv.Itoa(difficulty)
+
+	// the nonce tag goes at the end if there isn't one yet, but the tags are
+	// only changed once it is found
+	idx := len(evt.Tags)
+	for i, tag := range evt.Tags {
+		if len(tag) >= 1 && tag[0] == "nonce" {
+			idx = i
+			break
+		}
+	}
+
+	// only the nonce changes, so the rest of the serialization is only
+	// written once, around it
+	before := appendTags(nil, evt.Tags[:idx])
+	after := []byte("[]")
+	if idx < len(evt.Tags) {
+		after = appendTags(nil, evt.Tags[idx+1:])
+	}
+
+	prefix := []byte("[0,")
+	prefix = appendJSONString(prefix, evt.PubKey)
+	prefix = append(prefix, ',')
+	prefix = strconv.AppendInt(prefix, int64(evt.CreatedAt), 10)
+	prefix = append(prefix, ',')
+	prefix = strconv.AppendInt(prefix, int64(evt.Kind), 10)
+	prefix = append(prefix, ',')
+	prefix = append(prefix, before[:len(before)-1]...)
+	if idx > 0 {
+		prefix = append(prefix, ',')
+	}
+	prefix = append(prefix, `["nonce","`...)
+
+	suffix := []byte(`",`)
+	suffix = appendJSONString(suffix, target)
+	suffix = append(suffix, ']')
+	if len(after) > 2 {
+		suffix = append(suffix, ',')
+		suffix = append(suffix, after[1:]...)
+	} else {
+		suffix = append(suffix, ']')
+	}
+	suffix = append(suffix, ',')
+	suffix = appendJSONString(suffix, evt.Content)
+	suffix = append(suffix, ']')
+
+	buf := make([]byte, 0, len(prefix)+20+len(suffix))
+	buf = append(buf, prefix...)
+	for nonce := uint64(0); ; nonce++ {
+		if nonce%1024 == 0 {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+		}
+
+		buf = strconv.AppendUint(buf[:len(prefix)], nonce, 10)
+		buf = append(buf, suffix...)
+		h := sha256.Sum256(buf)
+		if leadingZeroBits(h[:]) >= difficulty {
+			// a fresh slice, as the tags may be shared by copies of the event
+			tags := make(Tags, len(evt.Tags), len(evt.Tags)+1)
+			copy(tags, evt.Tags)
+			nonceTag := Tag{"nonce", strconv.FormatUint(nonce, 10), target}
+			if idx == len(tags) {
+				tags = append(tags, nonceTag)
+			} else {
+				tags[idx] = nonceTag
+			}
+			evt.Tags = tags
+			evt.ID = hex.EncodeToString(h[:])
+			return nil
+		}
+	}
+}
+
+// GenerateProofOfWork is the same as Mine.
+func (evt *Event) GenerateProofOfWork(ctx context.Context, difficulty int) error {
+	return evt.Mine(ctx, difficulty)
+}
+
+// MineThenSign sets the event pubkey to the one of privateKey, mines it with
+// Mine and then signs it, once, discarding any previous signature. The event
+// must not be changed afterwards, which would break both the id and the proof
+// of work.
+func (evt *Event) MineThenSign(ctx context.Context, difficulty int, privateKey string) error {
+	pubkey, err := GetPublicKey(privateKey)
+	if err != nil {
+		return fmt.Errorf("MineThenSign called with invalid private key: %w", err)
+	}
+	evt.PubKey = pubkey
+	evt.Sig = ""
+
+	if err := evt.Mine(ctx, difficulty); err != nil {
+		return err
+	}
+	return evt.Sign(privateKey)
+}
+
+// CheckProofOfWork checks that the event commits to a NIP-13 difficulty of at
+// least minDifficulty in its nonce tag and that its id really has that many
+// leading zero bits. The error wraps ErrNoPoWCommitment, ErrPoWBelowRequested
+// or ErrPoWCommitmentUnmet. The id itself isn't checked against the content,
+// see Validate.
+func (evt *Event) CheckProofOfWork(minDifficulty int) error {
+	tag := evt.Tags.GetFirst("nonce")
+	if tag == nil || len(*tag) < 3 {
+		return ErrNoPoWCommitment
+	}
+	target, err := strconv.Atoi((*tag)[2])
+	if err != nil || target < 0 {
+		return fmt.Errorf("%w: invalid target '%s'", ErrNoPoWCommitment, (*tag)[2])
+	}
+	if target < minDifficulty {
+		return fmt.Errorf("%w: committed %d, wanted %d", ErrPoWBelowRequested, target, minDifficulty)
+	}
+
+	var actual int
+	if len(evt.ID) == 64 {
+		actual = Difficulty(evt.ID)
+	}
+	if actual < target {
+		return fmt.Errorf("%w: committed %d, got %d", ErrPoWCommitmentUnmet, target, actual)
+	}
+	return nil
+}
+
+// Difficulty returns the NIP-13 difficulty of a hex event id, the number of
+// leading zero bits it has, or 0 if it isn't hex.
+func Difficulty(id string) int {
+	b, err := hex.DecodeString(id)
+	if err != nil {
+		return 0
+	}
+	return leadingZeroBits(b)
+}
+
+func leadingZeroBits(h []byte) int {
+	var zeros int
+	for _, v := range h {
+		if v != 0 {
+			return zeros + bits.LeadingZeros8(v)
+		}
+		zeros += 8
+	}
+	return zeros
+}
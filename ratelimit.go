@@ -0,0 +1,62 @@
+package nostr
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// rateLimiter is a token bucket that refills one token every interval, up to
+// burst tokens.
+type rateLimiter struct {
+	mutex    sync.Mutex
+	interval time.Duration
+	burst    int
+	// next is when the bucket will be full again, tokens are taken by moving
+	// it forward by interval.
+	next time.Time
+}
+
+func newRateLimiter(perSecond float64, burst int) *rateLimiter {
+	if burst < 1 {
+		burst = 1
+	}
+	return &rateLimiter{
+		interval: time.Duration(float64(time.Second) / perSecond),
+		burst:    burst,
+	}
+}
+
+// wait takes a token, sleeping until there is one. If that would go past the
+// deadline of ctx it returns context.DeadlineExceeded right away, without
+// taking it.
+func (l *rateLimiter) wait(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	l.mutex.Lock()
+	now := time.Now()
+	if l.next.Before(now) {
+		l.next = now
+	}
+	delay := l.next.Add(-time.Duration(l.burst-1) * l.interval).Sub(now)
+	if deadline, ok := ctx.Deadline(); ok && delay > 0 && now.Add(delay).After(deadline) {
+		l.mutex.Unlock()
+		return context.DeadlineExceeded
+	}
+	l.next = l.next.Add(l.interval)
+	l.mutex.Unlock()
+
+	if delay <= 0 {
+		return nil
+	}
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
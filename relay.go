@@ -0,0 +1,772 @@
+package nostr
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	mathrand "math/rand"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fiatjaf/go-nostr/nip11"
+	"github.com/gorilla/websocket"
+)
+
+type Status int
+
+const (
+	PublishStatusSent      Status = 0
+	PublishStatusFailed    Status = -1
+	PublishStatusSucceeded Status = 1
+)
+
+// PublishResult is the outcome of Relay.Publish, Accepted and Reason are what
+// the relay answered in its OK message, if it did.
+type PublishResult struct {
+	Status   Status
+	Accepted bool
+	Reason   string
+}
+
+// OKReason is the machine-readable prefix of the reason in an OK message, like
+// "rate-limited" in "rate-limited: slow down".
+type OKReason string
+
+const (
+	OKReasonDuplicate    OKReason = "duplicate"
+	OKReasonPoW          OKReason = "pow"
+	OKReasonBlocked      OKReason = "blocked"
+	OKReasonRateLimited  OKReason = "rate-limited"
+	OKReasonInvalid      OKReason = "invalid"
+	OKReasonError        OKReason = "error"
+	OKReasonAuthRequired OKReason = "auth-required"
+)
+
+// ParseOKReason returns the prefix of the reason in an OK message, or an empty
+// OKReason if it doesn't have one of the known prefixes.
+func ParseOKReason(reason string) OKReason {
+	prefix := reason
+	if i := strings.IndexByte(reason, ':'); i >= 0 {
+		prefix = reason[:i]
+	}
+	switch r := OKReason(prefix); r {
+	case OKReasonDuplicate, OKReasonPoW, OKReasonBlocked, OKReasonRateLimited,
+		OKReasonInvalid, OKReasonError, OKReasonAuthRequired:
+		return r
+	default:
+		return ""
+	}
+}
+
+// Prefix returns the machine-readable prefix of Reason.
+func (res PublishResult) Prefix() OKReason {
+	return ParseOKReason(res.Reason)
+}
+
+// ConnectionStatus is the state of the connection to a relay.
+type ConnectionStatus int
+
+const (
+	ConnectionStatusConnected    ConnectionStatus = 0
+	ConnectionStatusReconnecting ConnectionStatus = 1
+	ConnectionStatusClosed       ConnectionStatus = 2
+)
+
+const (
+	reconnectBaseDelay = 1 * time.Second
+	reconnectMaxDelay  = 5 * time.Minute
+)
+
+// ErrCountUnsupported is returned by Count when the relay doesn't implement
+// NIP-45.
+var ErrCountUnsupported = errors.New("relay doesn't support COUNT")
+
+// ErrReconcileUnsupported is returned by Reconcile when the relay doesn't
+// implement NIP-77.
+var ErrReconcileUnsupported = errors.New("relay doesn't support negentropy reconciliation")
+
+// ErrMessageTooLarge is sent on DroppedMessages for each message bigger than
+// the limit set with WithMaxMessageSize, and is the ConnectionError of relays
+// closed for sending one when the option asks for it.
+var ErrMessageTooLarge = errors.New("relay sent a message that is too large")
+
+// DefaultMaxMessageSize is the biggest message accepted from relays unless
+// WithMaxMessageSize says otherwise.
+const DefaultMaxMessageSize = 512 << 10
+
+// Relay is a connection to a relay. When the connection drops the relay is
+// closed, unless it was connected WithReconnect, in which case it reconnects
+// with exponential backoff and sends the REQs of the active subscriptions
+// again. Delivery is then at most once: events the relay sends while
+// disconnected are lost, and stored events may arrive again after the REQs are
+// re-sent.
+type Relay struct {
+	URL string
+
+	// Connection is kept across reconnections, only its socket changes.
+	Connection *Connection
+
+	mutex          sync.Mutex
+	subscriptions  map[string]*Subscription
+	okCallbacks    map[string]func(bool, string)
+	countCallbacks map[string]func(int64, error)
+	negCallbacks   map[string]func(string, error)
+
+	verifier func(*Event) error
+
+	onAuth      func(challenge string) (*Event, error)
+	challenge   string
+	authAttempt *authAttempt
+
+	// Notices gets the NOTICE messages sent by the relay, it is closed when
+	// the connection ends. Notices are dropped if nobody is reading.
+	Notices chan string
+
+	// AssumeValid skips the signature check of received events, only set it
+	// for trusted relays.
+	AssumeValid bool
+
+	// StatusChanges gets the new status each time the connection drops, is
+	// reestablished or is closed for good, in which case it is closed too.
+	// Changes are dropped if nobody is reading.
+	StatusChanges chan ConnectionStatus
+	status        ConnectionStatus
+
+	// DroppedMessages gets an ErrMessageTooLarge each time a message bigger
+	// than the limit is discarded, it is closed when the connection ends.
+	// Errors are dropped if nobody is reading.
+	DroppedMessages chan error
+
+	// ConnectionError is set when the connection ends, before Closed is closed.
+	ConnectionError error
+	Closed          chan struct{}
+
+	seen          *SeenCache
+	limiter       *rateLimiter
+	maxMessage    int64
+	disconnectBig bool
+	dialer        *websocket.Dialer
+	header        http.Header
+	httpClient    *http.Client
+	reconnectBase time.Duration
+	reconnectMax  time.Duration
+	// closing is done when Close is called, it aborts reconnections
+	closing     context.Context
+	stopClosing context.CancelFunc
+}
+
+// Connect opens a websocket connection to the relay at url and starts
+// reading its messages.
+func Connect(ctx context.Context, url string, opts ...RelayOption) (*Relay, error) {
+	nm := NormalizeURL(url)
+	if nm == "" {
+		return nil, fmt.Errorf("invalid relay URL '%s'", url)
+	}
+
+	dialer := *websocket.DefaultDialer
+	dialer.EnableCompression = true
+	r := &Relay{
+		URL:             nm,
+		subscriptions:   make(map[string]*Subscription),
+		okCallbacks:     make(map[string]func(bool, string)),
+		countCallbacks:  make(map[string]func(int64, error)),
+		negCallbacks:    make(map[string]func(string, error)),
+		Notices:         make(chan string, 20),
+		StatusChanges:   make(chan ConnectionStatus, 10),
+		DroppedMessages: make(chan error, 10),
+		Closed:          make(chan struct{}),
+		dialer:          &dialer,
+		maxMessage:      DefaultMaxMessageSize,
+	}
+	for _, opt := range opts {
+		opt(r)
+	}
+	r.httpClient = newHTTPClient(r.dialer)
+	r.closing, r.stopClosing = context.WithCancel(context.Background())
+
+	socket, _, err := r.dialer.DialContext(ctx, nm, r.header)
+	if err != nil {
+		return nil, fmt.Errorf("error opening websocket to '%s': %w", nm, err)
+	}
+	r.Connection = NewConnection(socket)
+
+	go r.readLoop()
+
+	return r, nil
+}
+
+// ConnectionStatus tells if the relay is connected, reconnecting or closed.
+func (r *Relay) ConnectionStatus() ConnectionStatus {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return r.status
+}
+
+func (r *Relay) setStatus(status ConnectionStatus) {
+	r.mutex.Lock()
+	r.status = status
+	r.mutex.Unlock()
+
+	select {
+	case r.StatusChanges <- status:
+	default:
+	}
+}
+
+func (r *Relay) readLoop() {
+	defer close(r.Closed)
+	defer close(r.Notices)
+	defer close(r.StatusChanges)
+	defer close(r.DroppedMessages)
+
+	for {
+		// only this goroutine replaces the socket, so it can read it unlocked
+		typ, message, err := r.Connection.readMessage(r.maxMessage)
+		if err == ErrMessageTooLarge {
+			if !r.disconnectBig {
+				select {
+				case r.DroppedMessages <- fmt.Errorf("%w: over %d bytes", err, r.maxMessage):
+				default:
+				}
+				continue
+			}
+			r.Close()
+			r.ConnectionError = err
+			r.setStatus(ConnectionStatusClosed)
+			return
+		}
+		if err != nil {
+			if r.reconnect() {
+				continue
+			}
+			r.ConnectionError = err
+			r.setStatus(ConnectionStatusClosed)
+			return
+		}
+		if typ == websocket.PingMessage {
+			r.Connection.WriteMessage(websocket.PongMessage, nil)
+		}
+
+		if typ != websocket.TextMessage || len(message) == 0 || message[0] != '[' {
+			continue
+		}
+
+		envelope, err := ParseMessage(message)
+		if err != nil {
+			continue
+		}
+
+		switch env := envelope.(type) {
+		case NoticeEnvelope:
+			// relays that don't know COUNT usually answer it with a NOTICE,
+			// which can only be told apart from other notices if there is
+			// nothing else it could be about; otherwise it is left to the
+			// context deadline. Reconcile relies on NIP-11, NEG-ERR and CLOSED.
+			r.mutex.Lock()
+			if len(r.countCallbacks) == 1 && len(r.negCallbacks) == 0 {
+				for _, callback := range r.countCallbacks {
+					callback(0, fmt.Errorf("%w: %s", ErrCountUnsupported, env.Message))
+				}
+			}
+			r.mutex.Unlock()
+
+			select {
+			case r.Notices <- env.Message:
+			default:
+				log.Printf("dropped notice from '%s': %s", r.URL, env.Message)
+			}
+		case EventEnvelope:
+			r.mutex.Lock()
+			subscription, ok := r.subscriptions[env.SubscriptionID]
+			r.mutex.Unlock()
+			if !ok {
+				continue
+			}
+
+			// check signature of all received events, ignore invalid
+			if !r.AssumeValid && !subscription.skipVerification && !r.alreadyVerified(env.Event) {
+				if err := r.verify(env.Event); err != nil {
+					continue
+				}
+				if r.seen != nil && env.Event.VerifyID() {
+					r.seen.Add(verifiedKey(env.Event))
+				}
+			}
+
+			// check if the event matches the desired filter, ignore otherwise
+			if !subscription.filters.Match(env.Event) {
+				continue
+			}
+
+			subscription.dispatch(EventMessage{
+				Relay: r.URL,
+				Event: *env.Event,
+			})
+		case EOSEEnvelope:
+			r.mutex.Lock()
+			subscription, ok := r.subscriptions[env.SubscriptionID]
+			r.mutex.Unlock()
+			if ok {
+				subscription.dispatchEOSE(r.URL)
+			}
+		case CountEnvelope:
+			r.mutex.Lock()
+			callback, exists := r.countCallbacks[env.SubscriptionID]
+			r.mutex.Unlock()
+			if exists && env.Count != nil {
+				callback(*env.Count, nil)
+			}
+		case ClosedEnvelope:
+			r.mutex.Lock()
+			callback, exists := r.countCallbacks[env.SubscriptionID]
+			negCallback, negExists := r.negCallbacks[env.SubscriptionID]
+			r.mutex.Unlock()
+			if exists {
+				callback(0, fmt.Errorf("%w: %s", ErrCountUnsupported, env.Reason))
+			}
+			if negExists {
+				negCallback("", fmt.Errorf("%w: %s", ErrReconcileUnsupported, env.Reason))
+			}
+		case NegMsgEnvelope:
+			r.mutex.Lock()
+			callback, exists := r.negCallbacks[env.SubscriptionID]
+			r.mutex.Unlock()
+			if exists {
+				callback(env.Message, nil)
+			}
+		case NegErrEnvelope:
+			r.mutex.Lock()
+			callback, exists := r.negCallbacks[env.SubscriptionID]
+			r.mutex.Unlock()
+			if exists {
+				callback("", fmt.Errorf("reconciliation refused by '%s': %s", r.URL, env.Reason))
+			}
+		case AuthEnvelope:
+			if env.Challenge != "" {
+				r.handleAuthChallenge(env.Challenge)
+			}
+		case OKEnvelope:
+			r.mutex.Lock()
+			callback, exists := r.okCallbacks[env.EventID]
+			r.mutex.Unlock()
+			if exists {
+				callback(env.OK, env.Reason)
+			}
+		}
+	}
+}
+
+// SetVerifier replaces the signature check of received events with fn, which
+// returns an error for the events to ignore, for example to hand them to a
+// batch verifier. A nil fn restores the default check.
+func (r *Relay) SetVerifier(fn func(*Event) error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.verifier = fn
+}
+
+func (r *Relay) verify(evt *Event) error {
+	r.mutex.Lock()
+	verifier := r.verifier
+	r.mutex.Unlock()
+
+	if verifier == nil {
+		verifier = verifyOne
+	}
+	return verifier(evt)
+}
+
+// alreadyVerified checks if this signature of an event with this id was
+// already checked, the id must match the content, as it is what ties them
+// together.
+func (r *Relay) alreadyVerified(evt *Event) bool {
+	return r.seen != nil && r.seen.Seen(verifiedKey(evt)) && evt.VerifyID()
+}
+
+// verifiedKey is what the seen cache records for an event whose signature was
+// checked, the id alone would let another relay send a forged signature.
+func verifiedKey(evt *Event) string {
+	return evt.ID + ":" + evt.Sig
+}
+
+// reconnect dials the relay until it succeeds, sending the REQs of the
+// active subscriptions again, or until the relay is closed. It returns false
+// if the relay shouldn't be used anymore.
+func (r *Relay) reconnect() bool {
+	if r.reconnectBase <= 0 {
+		return false
+	}
+	if r.closing.Err() != nil {
+		return false
+	}
+
+	r.setStatus(ConnectionStatusReconnecting)
+
+	delay := r.reconnectBase
+	for {
+		// wait between half and the whole delay so clients don't all come back
+		// at the same time
+		wait := delay/2 + time.Duration(mathrand.Int63n(int64(delay/2)+1))
+		select {
+		case <-r.closing.Done():
+			return false
+		case <-time.After(wait):
+		}
+
+		socket, _, err := r.dialer.DialContext(r.closing, r.URL, r.header)
+		if err != nil {
+			if r.closing.Err() != nil {
+				return false
+			}
+			log.Printf("failed to reconnect to '%s': %s", r.URL, err.Error())
+			delay *= 2
+			if delay > r.reconnectMax {
+				delay = r.reconnectMax
+			}
+			continue
+		}
+		if !r.Connection.replace(socket) {
+			return false
+		}
+
+		r.mutex.Lock()
+		// the new connection gets its own challenge
+		r.challenge = ""
+		r.authAttempt = nil
+		subscriptions := make([]*Subscription, 0, len(r.subscriptions))
+		for _, subscription := range r.subscriptions {
+			subscriptions = append(subscriptions, subscription)
+		}
+		r.mutex.Unlock()
+		for _, subscription := range subscriptions {
+			r.Connection.WriteJSON(subscription.reqMessage())
+		}
+
+		r.setStatus(ConnectionStatusConnected)
+		return true
+	}
+}
+
+// Publish sends an event to the relay and waits until it answers with an OK
+// message for it. The result Status is PublishStatusSucceeded if the event was
+// accepted and PublishStatusFailed if it was rejected, in which case an error
+// with the reason is returned too. If the context is done before the answer
+// the Status is PublishStatusSent and the error is the context error, or
+// PublishStatusFailed if it was done before sending. Events rejected with an
+// "auth-required:" reason are sent again once after authenticating, if there
+// is an OnAuth callback.
+func (r *Relay) Publish(ctx context.Context, evt *Event) (PublishResult, error) {
+	type okMessage struct {
+		ok     bool
+		reason string
+	}
+	result := make(chan okMessage, 1)
+
+	if err := ctx.Err(); err != nil {
+		return PublishResult{Status: PublishStatusFailed}, err
+	}
+
+	r.mutex.Lock()
+	r.okCallbacks[evt.ID] = func(ok bool, reason string) {
+		select {
+		case result <- okMessage{ok, reason}:
+		default:
+		}
+	}
+	r.mutex.Unlock()
+	defer func() {
+		r.mutex.Lock()
+		delete(r.okCallbacks, evt.ID)
+		r.mutex.Unlock()
+	}()
+
+	for retried := false; ; retried = true {
+		if err := r.throttle(ctx); err != nil {
+			return PublishResult{Status: PublishStatusFailed}, err
+		}
+		if err := r.Connection.WriteJSONContext(ctx, EventEnvelope{Event: evt}); err != nil {
+			return PublishResult{Status: PublishStatusFailed}, fmt.Errorf("error sending event to '%s': %w", r.URL, err)
+		}
+
+		select {
+		case res := <-result:
+			answer := PublishResult{Status: PublishStatusSucceeded, Accepted: res.ok, Reason: res.reason}
+			if res.ok {
+				return answer, nil
+			}
+			if !retried && r.canRetryWithAuth(res.reason) {
+				if err := r.authenticate(ctx); err != nil {
+					answer.Status = PublishStatusFailed
+					return answer, fmt.Errorf("event rejected by '%s': %s, and authentication failed: %w", r.URL, res.reason, err)
+				}
+				continue
+			}
+			answer.Status = PublishStatusFailed
+			return answer, fmt.Errorf("event rejected by '%s': %s", r.URL, res.reason)
+		case <-r.Closed:
+			return PublishResult{Status: PublishStatusSent}, fmt.Errorf("connection to '%s' closed: %w", r.URL, r.ConnectionError)
+		case <-ctx.Done():
+			return PublishResult{Status: PublishStatusSent}, ctx.Err()
+		}
+	}
+}
+
+// throttle waits for the rate limiter set with WithRateLimit, if any.
+func (r *Relay) throttle(ctx context.Context) error {
+	if r.limiter == nil {
+		return nil
+	}
+	return r.limiter.wait(ctx)
+}
+
+// PublishOK is like Publish, but only returns an error if the event wasn't
+// accepted, an event the relay already had counts as accepted.
+func (r *Relay) PublishOK(ctx context.Context, evt *Event) error {
+	res, err := r.Publish(ctx, evt)
+	if res.Status == PublishStatusFailed && res.Prefix() == OKReasonDuplicate {
+		return nil
+	}
+	return err
+}
+
+// Subscribe sends a REQ with the given filters to the relay. The
+// subscription is closed when the context is done or Unsub is called, which
+// frees its id.
+func (r *Relay) Subscribe(ctx context.Context, filters Filters, opts ...SubscriptionOption) (*Subscription, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	if err := r.throttle(ctx); err != nil {
+		return nil, err
+	}
+
+	subscription := newSubscription(filters, opts...)
+	if err := r.reserveSubscription(subscription); err != nil {
+		return nil, err
+	}
+	subscription.relays[r.URL] = r
+
+	if err := subscription.sub(ctx); err != nil {
+		r.removeSubscription(subscription)
+		return nil, err
+	}
+
+	go func() {
+		select {
+		case <-ctx.Done():
+			subscription.Unsub()
+		case <-subscription.done:
+		}
+	}()
+
+	return subscription, nil
+}
+
+// Count asks the relay how many events match the filters, without fetching
+// them. If the relay answers with a CLOSED instead the error wraps
+// ErrCountUnsupported, as it does for a NOTICE while this is the only COUNT
+// waiting for an answer; with more of them the NOTICE can't be attributed, so
+// the context should have a deadline.
+func (r *Relay) Count(ctx context.Context, filters Filters) (int64, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+
+	random := make([]byte, 7)
+	rand.Read(random)
+	id := hex.EncodeToString(random)
+
+	type countMessage struct {
+		count int64
+		err   error
+	}
+	result := make(chan countMessage, 1)
+
+	r.mutex.Lock()
+	r.countCallbacks[id] = func(count int64, err error) {
+		select {
+		case result <- countMessage{count, err}:
+		default:
+		}
+	}
+	r.mutex.Unlock()
+	defer func() {
+		r.mutex.Lock()
+		delete(r.countCallbacks, id)
+		r.mutex.Unlock()
+	}()
+
+	if err := r.Connection.WriteJSONContext(ctx, CountEnvelope{SubscriptionID: id, Filters: filters}); err != nil {
+		return 0, fmt.Errorf("error sending COUNT to '%s': %w", r.URL, err)
+	}
+
+	select {
+	case res := <-result:
+		return res.count, res.err
+	case <-r.Closed:
+		return 0, fmt.Errorf("connection to '%s' closed: %w", r.URL, r.ConnectionError)
+	case <-ctx.Done():
+		return 0, ctx.Err()
+	}
+}
+
+// Reconcile compares the events the relay has that match the filter with the
+// ones we have, using NIP-77 negentropy, so they can be synced without
+// downloading or uploading everything. need has the ids only the relay has,
+// have those only we have. If the relay doesn't advertise NIP-77 in its NIP-11
+// information document, fetched with the same dialer, TLS configuration and
+// headers as the websocket, or answers with a CLOSED, the error wraps
+// ErrReconcileUnsupported. Relays that answer with a NOTICE aren't told apart
+// from slow ones, so the context should have a deadline.
+func (r *Relay) Reconcile(ctx context.Context, filter Filter, haveIDs []EventID) (need []string, have []string, err error) {
+	if err := ctx.Err(); err != nil {
+		return nil, nil, err
+	}
+
+	// relays whose information can't be fetched are tried anyway
+	if info, err := nip11.FetchRelayInfoWithClient(ctx, r.httpClient, r.URL, r.header); err == nil && !supportsNIP(info, 77) {
+		return nil, nil, ErrReconcileUnsupported
+	}
+
+	neg, err := NewNegentropy(haveIDs)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	random := make([]byte, 7)
+	rand.Read(random)
+	id := hex.EncodeToString(random)
+
+	type negMessage struct {
+		message string
+		err     error
+	}
+	messages := make(chan negMessage, 1)
+
+	r.mutex.Lock()
+	r.negCallbacks[id] = func(message string, err error) {
+		select {
+		case messages <- negMessage{message, err}:
+		default:
+		}
+	}
+	r.mutex.Unlock()
+	defer func() {
+		r.mutex.Lock()
+		delete(r.negCallbacks, id)
+		r.mutex.Unlock()
+	}()
+
+	var envelope Envelope = NegOpenEnvelope{SubscriptionID: id, Filter: filter, Message: hex.EncodeToString(neg.Initiate())}
+	for {
+		if err := r.Connection.WriteJSONContext(ctx, envelope); err != nil {
+			return nil, nil, fmt.Errorf("error sending %s to '%s': %w", envelope.Label(), r.URL, err)
+		}
+
+		var msg negMessage
+		select {
+		case msg = <-messages:
+		case <-r.Closed:
+			return nil, nil, fmt.Errorf("connection to '%s' closed: %w", r.URL, r.ConnectionError)
+		case <-ctx.Done():
+			r.Connection.WriteJSON(NegCloseEnvelope{SubscriptionID: id})
+			return nil, nil, ctx.Err()
+		}
+		if msg.err != nil {
+			return nil, nil, msg.err
+		}
+
+		query, err := hex.DecodeString(msg.message)
+		if err != nil {
+			r.Connection.WriteJSON(NegCloseEnvelope{SubscriptionID: id})
+			return nil, nil, fmt.Errorf("invalid NEG-MSG from '%s': %w", r.URL, err)
+		}
+		next, h, n, err := neg.Reconcile(query)
+		if err != nil {
+			r.Connection.WriteJSON(NegCloseEnvelope{SubscriptionID: id})
+			return nil, nil, fmt.Errorf("invalid NEG-MSG from '%s': %w", r.URL, err)
+		}
+		have = append(have, h...)
+		need = append(need, n...)
+
+		if next == nil {
+			r.Connection.WriteJSON(NegCloseEnvelope{SubscriptionID: id})
+			return need, have, nil
+		}
+		envelope = NegMsgEnvelope{SubscriptionID: id, Message: hex.EncodeToString(next)}
+	}
+}
+
+// newHTTPClient returns a client for the HTTP requests made to relays, like
+// NIP-11 ones, that connects the same way as the websocket dialer.
+func newHTTPClient(dialer *websocket.Dialer) *http.Client {
+	transport := &http.Transport{
+		Proxy:             dialer.Proxy,
+		TLSClientConfig:   dialer.TLSClientConfig,
+		DialContext:       dialer.NetDialContext,
+		DisableKeepAlives: true,
+	}
+	if transport.DialContext == nil && dialer.NetDial != nil {
+		dial := dialer.NetDial
+		transport.DialContext = func(ctx context.Context, network string, address string) (net.Conn, error) {
+			return dial(network, address)
+		}
+	}
+	return &http.Client{Transport: transport, Timeout: 10 * time.Second}
+}
+
+func supportsNIP(info *nip11.RelayInformation, nip int) bool {
+	for _, n := range info.SupportedNIPs {
+		if n == nip {
+			return true
+		}
+	}
+	return false
+}
+
+// Close closes the connection to the relay, it isn't reconnected afterwards.
+func (r *Relay) Close() error {
+	r.stopClosing()
+	return r.Connection.Close()
+}
+
+func (r *Relay) addSubscription(subscription *Subscription) {
+	r.mutex.Lock()
+	r.subscriptions[subscription.channel] = subscription
+	r.mutex.Unlock()
+}
+
+// reserveSubscription registers the subscription, picking another random id
+// if its id is already in use, or failing if the id was given by the caller.
+func (r *Relay) reserveSubscription(subscription *Subscription) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	for {
+		if _, used := r.subscriptions[subscription.channel]; !used {
+			r.subscriptions[subscription.channel] = subscription
+			return nil
+		}
+		if subscription.customID {
+			return fmt.Errorf("subscription id '%s' is already in use on '%s'", subscription.channel, r.URL)
+		}
+		subscription.channel = subscription.generateID()
+	}
+}
+
+// removeSubscription frees the subscription id, unless it was already taken
+// by another subscription.
+func (r *Relay) removeSubscription(subscription *Subscription) {
+	r.mutex.Lock()
+	if r.subscriptions[subscription.channel] == subscription {
+		delete(r.subscriptions, subscription.channel)
+	}
+	r.mutex.Unlock()
+}
@@ -0,0 +1,121 @@
+package nostr
+
+import (
+	"context"
+	"crypto/tls"
+	"net"
+	"net/http"
+	"time"
+)
+
+// RelayOption configures a Relay, it is passed to Connect.
+type RelayOption func(*Relay)
+
+// WithReconnect makes the relay reconnect when the connection drops, instead
+// of closing, waiting between attempts a delay that starts at base and doubles
+// up to max, with some jitter. Closed is then only closed by Close. A base of
+// zero, the default, disables reconnection. Relays in a RelayPool reconnect
+// starting at 1 second and up to 5 minutes.
+func WithReconnect(base time.Duration, max time.Duration) RelayOption {
+	return func(r *Relay) {
+		r.reconnectBase = base
+		r.reconnectMax = max
+	}
+}
+
+// WithSeenCache shares a cache of checked events among relays, the signature
+// of an event that was already checked for another relay isn't checked again
+// if it is the same.
+func WithSeenCache(cache *SeenCache) RelayOption {
+	return func(r *Relay) {
+		r.seen = cache
+	}
+}
+
+// WithMaxMessageSize sets the biggest message accepted from the relay, which
+// is DefaultMaxMessageSize unless set, so a misbehaving relay can't exhaust
+// the memory. Bigger messages are discarded as they are read and reported on
+// DroppedMessages, or, if disconnect is true, the connection is closed for good
+// with ErrMessageTooLarge. A size of 0 accepts any message.
+func WithMaxMessageSize(size int64, disconnect bool) RelayOption {
+	return func(r *Relay) {
+		r.maxMessage = size
+		r.disconnectBig = disconnect
+	}
+}
+
+// WithRateLimit spaces the EVENT and REQ messages sent by Publish and
+// Subscribe to at most perSecond per second, after an initial burst, so that
+// flushing a backlog doesn't get the client kicked by the relay. Calls wait for
+// their turn, and fail with the context error if it comes after the deadline.
+// NIP-11 documents don't advertise rates, so they must be chosen by the caller.
+func WithRateLimit(perSecond float64, burst int) RelayOption {
+	return func(r *Relay) {
+		if perSecond > 0 {
+			r.limiter = newRateLimiter(perSecond, burst)
+		} else {
+			r.limiter = nil
+		}
+	}
+}
+
+// WithAuthSigner answers the relay AUTH challenges with events signed by s,
+// it is like calling OnAuth with a callback that does so.
+func WithAuthSigner(s Signer) RelayOption {
+	return func(r *Relay) {
+		r.onAuth = func(challenge string) (*Event, error) {
+			evt := MakeAuthEvent(r.URL, challenge)
+			if err := evt.SignBy(s); err != nil {
+				return nil, err
+			}
+			return evt, nil
+		}
+	}
+}
+
+// WithCompression enables or disables the negotiation of permessage-deflate
+// compression, which is enabled by default and only used if the relay
+// supports it. Each message is compressed on its own and ids, pubkeys and
+// signatures are random, so the gain is modest and depends on the compression
+// level of the relay, BenchmarkRelaySyncCompression reports the bytes read to
+// sync short notes with and without it.
+func WithCompression(enabled bool) RelayOption {
+	return func(r *Relay) {
+		r.dialer.EnableCompression = enabled
+	}
+}
+
+// NetDialer opens the connections to relays, both *net.Dialer and the
+// dialers from golang.org/x/net/proxy, like SOCKS5 ones, satisfy it.
+type NetDialer interface {
+	Dial(network string, address string) (net.Conn, error)
+}
+
+// WithDialer makes the connections to the relay with d, its DialContext
+// method is used if it has one.
+func WithDialer(d NetDialer) RelayOption {
+	return func(r *Relay) {
+		r.dialer.NetDial = d.Dial
+		if cd, ok := d.(interface {
+			DialContext(ctx context.Context, network string, address string) (net.Conn, error)
+		}); ok {
+			r.dialer.NetDialContext = cd.DialContext
+		}
+	}
+}
+
+// WithTLSConfig sets the TLS configuration for wss:// relays, for example to
+// trust a self-signed certificate.
+func WithTLSConfig(config *tls.Config) RelayOption {
+	return func(r *Relay) {
+		r.dialer.TLSClientConfig = config
+	}
+}
+
+// WithHeader sets HTTP headers to send in the websocket handshake, for relays
+// gated by some authentication.
+func WithHeader(header http.Header) RelayOption {
+	return func(r *Relay) {
+		r.header = header.Clone()
+	}
+}
@@ -0,0 +1,1558 @@
+package nostr
+
+import (
+	"compress/flate"
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"go.uber.org/goleak"
+)
+
+// mockRelay is a minimal relay that answers EVENT with OK and REQ with the
+// matching events it has stored. It also answers a made-up HUGE message with a
+// 1MB NOTICE followed by a small one.
+type mockRelay struct {
+	*httptest.Server
+
+	mutex  sync.Mutex
+	events []Event
+	conns  []*websocket.Conn
+	header http.Header
+	// infoHeader has the headers of the last NIP-11 request.
+	infoHeader http.Header
+
+	// countUnsupported makes it answer COUNT with a NOTICE.
+	countUnsupported bool
+	// batchCounts makes it hold the answers to COUNT until it has this many,
+	// then send an unrelated NOTICE before them.
+	batchCounts int
+	// silent makes it store events without answering with OK.
+	silent bool
+	// rejectDuplicates makes it answer events it already has with a false OK.
+	rejectDuplicates bool
+	// closed has the ids of the subscriptions that got a CLOSE.
+	closed []string
+	// negentropyUnsupported takes NIP-77 out of its NIP-11 supported_nips.
+	negentropyUnsupported bool
+	// negentropyClosed makes it answer NEG-OPEN with a NOTICE and a CLOSED.
+	negentropyClosed bool
+	// live makes it send the events it gets to the matching subscriptions of
+	// the same connection.
+	live bool
+	// arrivals has the times at which EVENT and REQ messages were received.
+	arrivals []time.Time
+	// authRequired makes it send an AUTH challenge on connection and reject
+	// events until it is answered.
+	authRequired bool
+	// noEOSE makes it send the stored events of a REQ without an EOSE after.
+	noEOSE bool
+	// compressionLevel is the flate level of the messages it sends, if
+	// compression was negotiated, instead of gorilla's default of 1.
+	compressionLevel int
+}
+
+func newMockRelay(t testing.TB) *mockRelay {
+	m := newUnstartedMockRelay(t)
+	m.Start()
+	return m
+}
+
+func newUnstartedMockRelay(t testing.TB) *mockRelay {
+	m := &mockRelay{}
+	upgrader := websocket.Upgrader{EnableCompression: true}
+	m.Server = httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.Header.Get("Accept") == "application/nostr+json" {
+			m.mutex.Lock()
+			m.infoHeader = req.Header
+			nips := []int{1, 11, 45, 77}
+			if m.negentropyUnsupported {
+				nips = nips[:3]
+			}
+			m.mutex.Unlock()
+			json.NewEncoder(w).Encode(map[string]interface{}{"supported_nips": nips})
+			return
+		}
+
+		conn, err := upgrader.Upgrade(w, req, nil)
+		if err != nil {
+			t.Errorf("failed to upgrade: %v", err)
+			return
+		}
+		defer conn.Close()
+		m.mutex.Lock()
+		if m.compressionLevel != 0 {
+			conn.SetCompressionLevel(m.compressionLevel)
+		}
+		m.conns = append(m.conns, conn)
+		m.header = req.Header
+		m.mutex.Unlock()
+
+		negentropies := make(map[string]*Negentropy)
+		subscriptions := make(map[string]Filters)
+		var pendingCounts []CountEnvelope
+
+		m.mutex.Lock()
+		authRequired := m.authRequired
+		m.mutex.Unlock()
+		challenge, authed := strconv.FormatInt(time.Now().UnixNano(), 36), false
+		if authRequired {
+			conn.WriteJSON(AuthEnvelope{Challenge: challenge})
+		}
+
+		for {
+			var msg []json.RawMessage
+			if err := conn.ReadJSON(&msg); err != nil {
+				return
+			}
+			var label string
+			json.Unmarshal(msg[0], &label)
+			if label == "EVENT" || label == "REQ" {
+				m.mutex.Lock()
+				m.arrivals = append(m.arrivals, time.Now())
+				m.mutex.Unlock()
+			}
+
+			switch label {
+			case "AUTH":
+				var evt Event
+				json.Unmarshal(msg[1], &evt)
+				if err := evt.ValidateAuth(challenge, m.URL(), time.Minute); err != nil {
+					conn.WriteJSON([]interface{}{"OK", evt.ID, false, "invalid: " + err.Error()})
+					continue
+				}
+				authed = true
+				conn.WriteJSON([]interface{}{"OK", evt.ID, true, ""})
+			case "EVENT":
+				var evt Event
+				json.Unmarshal(msg[1], &evt)
+				if authRequired && !authed {
+					conn.WriteJSON([]interface{}{"OK", evt.ID, false, "auth-required: we only accept events from known users"})
+					continue
+				}
+				ok, _ := evt.CheckSignature()
+				reason := ""
+				m.mutex.Lock()
+				if ok && m.rejectDuplicates {
+					for _, stored := range m.events {
+						if stored.ID == evt.ID {
+							ok, reason = false, "duplicate: already have this event"
+						}
+					}
+				}
+				if ok {
+					m.events = append(m.events, evt)
+				}
+				silent := m.silent
+				m.mutex.Unlock()
+				if silent {
+					continue
+				}
+				if !ok && reason == "" {
+					reason = "invalid: bad signature"
+				}
+				conn.WriteJSON([]interface{}{"OK", evt.ID, ok, reason})
+				m.mutex.Lock()
+				live := m.live
+				m.mutex.Unlock()
+				for id, filters := range subscriptions {
+					if ok && live && filters.Match(&evt) {
+						conn.WriteJSON([]interface{}{"EVENT", id, evt})
+					}
+				}
+			case "CLOSE":
+				var id string
+				json.Unmarshal(msg[1], &id)
+				m.mutex.Lock()
+				m.closed = append(m.closed, id)
+				m.mutex.Unlock()
+				delete(subscriptions, id)
+			case "REQ":
+				var id string
+				json.Unmarshal(msg[1], &id)
+				var filters Filters
+				for _, raw := range msg[2:] {
+					var f Filter
+					json.Unmarshal(raw, &f)
+					filters = append(filters, f)
+				}
+				subscriptions[id] = filters
+				m.mutex.Lock()
+				for _, evt := range m.events {
+					if filters.Match(&evt) {
+						conn.WriteJSON([]interface{}{"EVENT", id, evt})
+					}
+				}
+				noEOSE := m.noEOSE
+				m.mutex.Unlock()
+				if !noEOSE {
+					conn.WriteJSON([]interface{}{"EOSE", id})
+				}
+			case "COUNT":
+				m.mutex.Lock()
+				unsupported := m.countUnsupported
+				m.mutex.Unlock()
+				if unsupported {
+					conn.WriteJSON([]interface{}{"NOTICE", "unknown message type COUNT"})
+					continue
+				}
+				var id string
+				json.Unmarshal(msg[1], &id)
+				var filters Filters
+				for _, raw := range msg[2:] {
+					var f Filter
+					json.Unmarshal(raw, &f)
+					filters = append(filters, f)
+				}
+				var n int64
+				m.mutex.Lock()
+				for _, evt := range m.events {
+					if filters.Match(&evt) {
+						n++
+					}
+				}
+				batch := m.batchCounts
+				m.mutex.Unlock()
+				pendingCounts = append(pendingCounts, CountEnvelope{SubscriptionID: id, Count: &n})
+				if len(pendingCounts) < batch {
+					continue
+				}
+				if batch > 0 {
+					conn.WriteJSON([]interface{}{"NOTICE", "rate-limited: slow down"})
+				}
+				for _, count := range pendingCounts {
+					conn.WriteJSON(count)
+				}
+				pendingCounts = nil
+			case "NEG-OPEN", "NEG-MSG":
+				var id, message string
+				json.Unmarshal(msg[1], &id)
+				m.mutex.Lock()
+				refuse := m.negentropyClosed
+				m.mutex.Unlock()
+				if refuse {
+					conn.WriteJSON([]interface{}{"NOTICE", "unknown message type " + label})
+					conn.WriteJSON([]interface{}{"CLOSED", id, "unsupported: no negentropy here"})
+					continue
+				}
+				json.Unmarshal(msg[len(msg)-1], &message)
+				if label == "NEG-OPEN" {
+					var f Filter
+					json.Unmarshal(msg[2], &f)
+					var items []EventID
+					m.mutex.Lock()
+					for _, evt := range m.events {
+						if f.Matches(&evt) {
+							items = append(items, EventID{ID: evt.ID, CreatedAt: evt.CreatedAt})
+						}
+					}
+					m.mutex.Unlock()
+					negentropies[id], _ = NewNegentropy(items)
+				}
+				query, _ := hex.DecodeString(message)
+				next, _, _, err := negentropies[id].Reconcile(query)
+				if err != nil {
+					conn.WriteJSON(NegErrEnvelope{SubscriptionID: id, Reason: "error: " + err.Error()})
+					continue
+				}
+				conn.WriteJSON(NegMsgEnvelope{SubscriptionID: id, Message: hex.EncodeToString(next)})
+			case "HUGE":
+				// a message over any sane limit, then a normal one
+				conn.WriteJSON([]string{"NOTICE", strings.Repeat("x", 1<<20)})
+				conn.WriteJSON([]string{"NOTICE", "small"})
+			case "NEG-CLOSE":
+				var id string
+				json.Unmarshal(msg[1], &id)
+				delete(negentropies, id)
+			}
+		}
+	}))
+	return m
+}
+
+// dropConnections closes the websockets, which CloseClientConnections
+// doesn't track since they are hijacked.
+func (m *mockRelay) dropConnections() {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	for _, conn := range m.conns {
+		conn.Close()
+	}
+	m.conns = nil
+}
+
+func (m *mockRelay) URL() string {
+	return "ws" + strings.TrimPrefix(m.Server.URL, "http")
+}
+
+func TestRelayPublishAndSubscribe(t *testing.T) {
+	// some relays mishandle compression, check it doesn't break OK and EOSE
+	for _, compression := range []bool{true, false} {
+		t.Run("compression="+strconv.FormatBool(compression), func(t *testing.T) {
+			testRelayPublishAndSubscribe(t, WithCompression(compression))
+		})
+	}
+}
+
+func testRelayPublishAndSubscribe(t *testing.T, opts ...RelayOption) {
+	mock := newMockRelay(t)
+	defer mock.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	relay, err := Connect(ctx, mock.URL(), opts...)
+	if err != nil {
+		t.Fatalf("failed to connect: %v", err)
+	}
+	defer relay.Close()
+
+	sk := GeneratePrivateKey()
+	evt, _ := NewEvent(KindTextNote, "hello").SignWith(sk)
+
+	status, err := relay.Publish(ctx, evt)
+	if err != nil || status.Status != PublishStatusSucceeded {
+		t.Fatalf("publish failed: %v %v", status, err)
+	}
+
+	invalid := *evt
+	invalid.Content = "tampered"
+	if status, err := relay.Publish(ctx, &invalid); err == nil || status.Status != PublishStatusFailed ||
+		status.Accepted || status.Prefix() != OKReasonInvalid {
+		t.Errorf("publishing an invalid event should fail: %v %v", status, err)
+	}
+
+	sub, err := relay.Subscribe(ctx, Filters{{Authors: StringList{evt.PubKey}}})
+	if err != nil {
+		t.Fatalf("subscribe failed: %v", err)
+	}
+
+	select {
+	case em := <-sub.Events:
+		if em.Event.ID != evt.ID || em.Relay != relay.URL {
+			t.Errorf("got the wrong event: %v", em)
+		}
+	case <-ctx.Done():
+		t.Fatal("didn't get the event")
+	}
+
+	select {
+	case <-sub.EndOfStoredEvents:
+	case <-ctx.Done():
+		t.Fatal("didn't get EOSE")
+	}
+
+	sub.Unsub()
+	if _, ok := <-sub.Events; ok {
+		t.Error("events channel should be closed after Unsub")
+	}
+}
+
+func TestRelayConcurrentWrites(t *testing.T) {
+	mock := newMockRelay(t)
+	defer mock.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	relay, err := Connect(ctx, mock.URL())
+	if err != nil {
+		t.Fatalf("failed to connect: %v", err)
+	}
+	defer relay.Close()
+
+	sk := GeneratePrivateKey()
+	const n = 200
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			// big enough contents to need several writes each
+			evt, _ := NewEvent(KindTextNote, strings.Repeat(strconv.Itoa(i), 2000)).SignWith(sk)
+			if err := relay.PublishOK(ctx, evt); err != nil {
+				t.Errorf("publish %d failed: %v", i, err)
+			}
+			if i%10 == 0 {
+				sub, err := relay.Subscribe(ctx, Filters{{IDs: StringList{evt.ID}}})
+				if err != nil {
+					t.Errorf("subscribe %d failed: %v", i, err)
+					return
+				}
+				defer sub.Unsub()
+				select {
+				case em := <-sub.Events:
+					if em.Event.ID != evt.ID {
+						t.Errorf("subscription %d got the wrong event", i)
+					}
+				case <-ctx.Done():
+					t.Errorf("subscription %d got no event", i)
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	mock.mutex.Lock()
+	defer mock.mutex.Unlock()
+	if len(mock.events) != n {
+		t.Errorf("relay got %d valid events, expected %d", len(mock.events), n)
+	}
+}
+
+func TestRelayUnreadSubscription(t *testing.T) {
+	mock := newMockRelay(t)
+	defer mock.Close()
+	mock.live = true
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	relay, err := Connect(ctx, mock.URL())
+	if err != nil {
+		t.Fatalf("failed to connect: %v", err)
+	}
+	defer relay.Close()
+
+	// nothing reads this one while publishing, which mustn't keep the OKs,
+	// the EOSE or the events of other subscriptions from arriving
+	unread, err := relay.Subscribe(ctx, Filters{{Kinds: []int{KindTextNote}}})
+	if err != nil {
+		t.Fatalf("failed to subscribe: %v", err)
+	}
+	defer unread.Unsub()
+
+	sk := GeneratePrivateKey()
+	var ids []string
+	for i := 0; i < 5; i++ {
+		evt, _ := NewEvent(KindTextNote, strconv.Itoa(i)).SignWith(sk)
+		if err := relay.PublishOK(ctx, evt); err != nil {
+			t.Fatalf("publish %d failed: %v", i, err)
+		}
+		ids = append(ids, evt.ID)
+	}
+
+	other, err := relay.Subscribe(ctx, Filters{{IDs: StringList{ids[0]}}})
+	if err != nil {
+		t.Fatalf("failed to subscribe: %v", err)
+	}
+	defer other.Unsub()
+	select {
+	case em := <-other.Events:
+		if em.Event.ID != ids[0] {
+			t.Errorf("wrong event: %s", em.Event.ID)
+		}
+	case <-ctx.Done():
+		t.Fatal("other subscription got no event")
+	}
+
+	// the unread one still gets everything, in order
+	for i, id := range ids {
+		select {
+		case em := <-unread.Events:
+			if em.Event.ID != id {
+				t.Errorf("event %d out of order", i)
+			}
+		case <-ctx.Done():
+			t.Fatalf("event %d wasn't delivered", i)
+		}
+	}
+	select {
+	case <-unread.EndOfStoredEvents:
+	case <-ctx.Done():
+		t.Error("EOSE wasn't delivered")
+	}
+}
+
+func TestRelayContextCancellation(t *testing.T) {
+	defer goleak.VerifyNone(t, goleak.IgnoreCurrent())
+
+	mock := newMockRelay(t)
+	defer mock.Close()
+
+	relay, err := Connect(context.Background(), mock.URL())
+	if err != nil {
+		t.Fatalf("failed to connect: %v", err)
+	}
+	defer relay.Close()
+
+	cancelled, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := Connect(cancelled, mock.URL()); err == nil {
+		t.Error("connected with a cancelled context")
+	}
+	if _, err := relay.Subscribe(cancelled, Filters{{}}); !errors.Is(err, context.Canceled) {
+		t.Errorf("subscribed with a cancelled context: %v", err)
+	}
+
+	// Publish stops waiting for the OK
+	mock.mutex.Lock()
+	mock.silent = true
+	mock.mutex.Unlock()
+	sk := GeneratePrivateKey()
+	evt, _ := NewEvent(KindTextNote, "hello").SignWith(sk)
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	if status, err := relay.Publish(ctx, evt); status.Status != PublishStatusSent || !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected a timeout, got %v %v", status, err)
+	}
+
+	// Subscribe sends a CLOSE and closes Events
+	ctx, cancel = context.WithCancel(context.Background())
+	sub, err := relay.Subscribe(ctx, Filters{{Kinds: IntList{KindTextNote}}})
+	if err != nil {
+		t.Fatalf("subscribe failed: %v", err)
+	}
+	<-sub.Events
+	cancel()
+	for range sub.Events {
+	}
+	time.Sleep(50 * time.Millisecond)
+	mock.mutex.Lock()
+	if len(mock.closed) != 1 || mock.closed[0] != sub.channel {
+		t.Errorf("relay didn't get a CLOSE: %v", mock.closed)
+	}
+	mock.mutex.Unlock()
+
+	relay.Close()
+	<-relay.Closed
+}
+
+func TestRelayDialOptions(t *testing.T) {
+	mock := newUnstartedMockRelay(t)
+	mock.StartTLS()
+	defer mock.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if _, err := Connect(ctx, mock.URL(), WithReconnect(0, 0)); err == nil {
+		t.Error("connected to a relay with an untrusted certificate")
+	}
+
+	var dialed int32
+	tlsConfig := mock.Client().Transport.(*http.Transport).TLSClientConfig
+	relay, err := Connect(ctx, mock.URL(),
+		WithTLSConfig(tlsConfig),
+		WithDialer(dialerFunc(func(network, address string) (net.Conn, error) {
+			atomic.AddInt32(&dialed, 1)
+			return net.Dial(network, address)
+		})),
+		WithHeader(http.Header{"Authorization": {"Bearer xyz"}}),
+	)
+	if err != nil {
+		t.Fatalf("failed to connect with the server certificate: %v", err)
+	}
+	defer relay.Close()
+
+	if atomic.LoadInt32(&dialed) != 1 {
+		t.Error("custom dialer wasn't used")
+	}
+
+	sk := GeneratePrivateKey()
+	evt, _ := NewEvent(KindTextNote, "hello").SignWith(sk)
+	if status, err := relay.Publish(ctx, evt); status.Status != PublishStatusSucceeded {
+		t.Errorf("publish failed: %v %v", status, err)
+	}
+
+	// the NIP-11 document is fetched with the same options
+	mock.mutex.Lock()
+	mock.negentropyUnsupported = true
+	mock.mutex.Unlock()
+	if _, _, err := relay.Reconcile(ctx, Filter{}, nil); !errors.Is(err, ErrReconcileUnsupported) {
+		t.Errorf("expected ErrReconcileUnsupported, got %v", err)
+	}
+	if atomic.LoadInt32(&dialed) != 2 {
+		t.Error("custom dialer wasn't used for the NIP-11 request")
+	}
+
+	mock.mutex.Lock()
+	defer mock.mutex.Unlock()
+	if mock.header.Get("Authorization") != "Bearer xyz" {
+		t.Errorf("custom header wasn't sent: %v", mock.header)
+	}
+	if mock.infoHeader.Get("Authorization") != "Bearer xyz" {
+		t.Errorf("custom header wasn't sent with the NIP-11 request: %v", mock.infoHeader)
+	}
+}
+
+type dialerFunc func(network, address string) (net.Conn, error)
+
+func (f dialerFunc) Dial(network, address string) (net.Conn, error) { return f(network, address) }
+
+type contextDialerFunc func(ctx context.Context, network, address string) (net.Conn, error)
+
+func (f contextDialerFunc) Dial(network, address string) (net.Conn, error) {
+	return f(context.Background(), network, address)
+}
+
+func (f contextDialerFunc) DialContext(ctx context.Context, network, address string) (net.Conn, error) {
+	return f(ctx, network, address)
+}
+
+func TestRelayReconnect(t *testing.T) {
+	mock := newMockRelay(t)
+	defer mock.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	relay, err := Connect(ctx, mock.URL(), WithReconnect(50*time.Millisecond, time.Second))
+	if err != nil {
+		t.Fatalf("failed to connect: %v", err)
+	}
+
+	sk := GeneratePrivateKey()
+	evt, _ := NewEvent(KindTextNote, "hello").SignWith(sk)
+	relay.Publish(ctx, evt)
+
+	sub, _ := relay.Subscribe(ctx, Filters{{IDs: StringList{evt.ID}}})
+	<-sub.Events
+
+	mock.dropConnections()
+	for _, expected := range []ConnectionStatus{ConnectionStatusReconnecting, ConnectionStatusConnected} {
+		select {
+		case status := <-relay.StatusChanges:
+			if status != expected {
+				t.Fatalf("expected status %d, got %d", expected, status)
+			}
+		case <-ctx.Done():
+			t.Fatal("relay didn't reconnect")
+		}
+	}
+
+	// the REQ is sent again, so the stored event comes again
+	select {
+	case em := <-sub.Events:
+		if em.Event.ID != evt.ID {
+			t.Errorf("got the wrong event: %v", em)
+		}
+	case <-ctx.Done():
+		t.Fatal("subscription wasn't resumed")
+	}
+
+	relay.Close()
+	if status := <-relay.StatusChanges; status != ConnectionStatusClosed {
+		t.Errorf("expected closed status, got %d", status)
+	}
+	if _, ok := <-relay.StatusChanges; ok {
+		t.Error("status changes should be closed along with the relay")
+	}
+}
+
+func TestRelayDropWithoutReconnect(t *testing.T) {
+	mock := newMockRelay(t)
+	defer mock.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	relay, err := Connect(ctx, mock.URL())
+	if err != nil {
+		t.Fatalf("failed to connect: %v", err)
+	}
+	defer relay.Close()
+
+	mock.dropConnections()
+	select {
+	case <-relay.Closed:
+		if relay.ConnectionError == nil {
+			t.Error("closed without a ConnectionError")
+		}
+	case <-ctx.Done():
+		t.Fatal("relay wasn't closed when the connection dropped")
+	}
+}
+
+func TestRelayCloseWhileReconnecting(t *testing.T) {
+	mock := newMockRelay(t)
+	defer mock.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	// the first dial works, the ones to reconnect hang until aborted
+	var dials int32
+	relay, err := Connect(ctx, mock.URL(), WithReconnect(time.Millisecond, time.Millisecond),
+		WithDialer(contextDialerFunc(func(ctx context.Context, network, address string) (net.Conn, error) {
+			if atomic.AddInt32(&dials, 1) == 1 {
+				return net.Dial(network, address)
+			}
+			<-ctx.Done()
+			return nil, ctx.Err()
+		})))
+	if err != nil {
+		t.Fatalf("failed to connect: %v", err)
+	}
+
+	mock.dropConnections()
+	for atomic.LoadInt32(&dials) < 2 {
+		time.Sleep(time.Millisecond)
+	}
+	relay.Close()
+	select {
+	case <-relay.Closed:
+	case <-ctx.Done():
+		t.Fatal("Close didn't abort the reconnection")
+	}
+}
+
+func TestRelayCount(t *testing.T) {
+	mock := newMockRelay(t)
+	defer mock.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	relay, err := Connect(ctx, mock.URL())
+	if err != nil {
+		t.Fatalf("failed to connect: %v", err)
+	}
+	defer relay.Close()
+
+	sk := GeneratePrivateKey()
+	for i := 0; i < 3; i++ {
+		evt, _ := NewEvent(KindTextNote, "hello").WithTag("t", strconv.Itoa(i)).SignWith(sk)
+		relay.Publish(ctx, evt)
+	}
+
+	pk, _ := GetPublicKey(sk)
+	if count, err := relay.Count(ctx, Filters{{Authors: StringList{pk}}}); err != nil || count != 3 {
+		t.Errorf("wrong count: %d %v", count, err)
+	}
+
+	mock.mutex.Lock()
+	mock.countUnsupported = true
+	mock.mutex.Unlock()
+	if _, err := relay.Count(ctx, Filters{{Authors: StringList{pk}}}); !errors.Is(err, ErrCountUnsupported) {
+		t.Errorf("expected ErrCountUnsupported, got %v", err)
+	}
+	// a NOTICE while several COUNTs are waiting isn't taken as an answer
+	mock.mutex.Lock()
+	mock.countUnsupported = false
+	mock.batchCounts = 2
+	mock.mutex.Unlock()
+	counts := make(chan error, 2)
+	for i := 0; i < 2; i++ {
+		go func() {
+			count, err := relay.Count(ctx, Filters{{Authors: StringList{pk}}})
+			if err == nil && count != 3 {
+				err = fmt.Errorf("wrong count %d", count)
+			}
+			counts <- err
+		}()
+	}
+	for i := 0; i < 2; i++ {
+		if err := <-counts; err != nil {
+			t.Errorf("count failed because of an unrelated notice: %v", err)
+		}
+	}
+}
+
+func TestRelaySubscriptionIDs(t *testing.T) {
+	mock := newMockRelay(t)
+	defer mock.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	relay, err := Connect(ctx, mock.URL())
+	if err != nil {
+		t.Fatalf("failed to connect: %v", err)
+	}
+	defer relay.Close()
+
+	filters := Filters{{Kinds: IntList{KindTextNote}}}
+	ids := make(chan string, 20)
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			sub, err := relay.Subscribe(ctx, filters, WithLabel("feed-"))
+			if err != nil {
+				t.Errorf("subscribe failed: %v", err)
+				return
+			}
+			ids <- sub.ID()
+		}()
+	}
+	wg.Wait()
+	close(ids)
+	seen := make(map[string]bool)
+	for id := range ids {
+		if !strings.HasPrefix(id, "feed-") || seen[id] {
+			t.Errorf("bad or repeated subscription id '%s'", id)
+		}
+		seen[id] = true
+	}
+
+	sub, err := relay.Subscribe(ctx, filters, WithSubscriptionID("my-req"))
+	if err != nil || sub.ID() != "my-req" {
+		t.Fatalf("subscribe with a given id failed: %v", err)
+	}
+	if _, err := relay.Subscribe(ctx, filters, WithSubscriptionID("my-req")); err == nil {
+		t.Error("subscribing with an id in use should fail")
+	}
+
+	sub.Unsub()
+	again, err := relay.Subscribe(ctx, filters, WithSubscriptionID("my-req"))
+	if err != nil {
+		t.Fatalf("id wasn't freed after Unsub: %v", err)
+	}
+	sub.Unsub()
+	relay.mutex.Lock()
+	live := relay.subscriptions["my-req"] == again
+	relay.mutex.Unlock()
+	if !live {
+		t.Error("closing a subscription again removed the one reusing its id")
+	}
+}
+
+func TestRelayVerification(t *testing.T) {
+	mock := newMockRelay(t)
+	defer mock.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	relay, err := Connect(ctx, mock.URL())
+	if err != nil {
+		t.Fatalf("failed to connect: %v", err)
+	}
+	defer relay.Close()
+
+	sk := GeneratePrivateKey()
+	evt, _ := NewEvent(KindTextNote, "hello").SignWith(sk)
+	forged := *evt
+	forged.Content = "forged"
+	mock.mutex.Lock()
+	mock.events = append(mock.events, forged)
+	mock.mutex.Unlock()
+
+	received := func(opts ...SubscriptionOption) []string {
+		sub, err := relay.Subscribe(ctx, Filters{{Authors: StringList{evt.PubKey}}}, opts...)
+		if err != nil {
+			t.Fatalf("subscribe failed: %v", err)
+		}
+		defer sub.Unsub()
+
+		var contents []string
+		for {
+			select {
+			case em := <-sub.Events:
+				contents = append(contents, em.Event.Content)
+			case <-sub.EndOfStoredEvents:
+				return contents
+			case <-ctx.Done():
+				t.Fatal("didn't get EOSE")
+			}
+		}
+	}
+
+	if got := received(); len(got) != 0 {
+		t.Errorf("forged event was delivered: %v", got)
+	}
+	if got := received(WithSkipVerification(true)); len(got) != 1 || got[0] != "forged" {
+		t.Errorf("event should be delivered without verification: %v", got)
+	}
+
+	var checked int
+	relay.SetVerifier(func(evt *Event) error {
+		checked++
+		return nil
+	})
+	if got := received(); len(got) != 1 || checked != 1 {
+		t.Errorf("custom verifier wasn't used: %v %d", got, checked)
+	}
+}
+
+func TestRelaySeenCacheSignature(t *testing.T) {
+	mock := newMockRelay(t)
+	defer mock.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	// the event was checked elsewhere, but this relay has another signature
+	evt, _ := NewEvent(KindTextNote, "hello").SignWith(GeneratePrivateKey())
+	badSig := *evt
+	badSig.Sig = strings.Repeat("0", 128)
+	mock.events = append(mock.events, badSig)
+	seen := NewSeenCache(10)
+	seen.Add(verifiedKey(evt))
+
+	relay, err := Connect(ctx, mock.URL(), WithSeenCache(seen))
+	if err != nil {
+		t.Fatalf("failed to connect: %v", err)
+	}
+	defer relay.Close()
+
+	sub, err := relay.Subscribe(ctx, Filters{{IDs: StringList{evt.ID}}})
+	if err != nil {
+		t.Fatalf("subscribe failed: %v", err)
+	}
+	select {
+	case em := <-sub.Events:
+		t.Errorf("event with a bad signature was delivered: %s", em.Event.Sig)
+	case <-sub.EndOfStoredEvents:
+	case <-ctx.Done():
+		t.Fatal("didn't get EOSE")
+	}
+}
+
+func TestRelayRateLimit(t *testing.T) {
+	mock := newMockRelay(t)
+	defer mock.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	const interval = 50 * time.Millisecond
+	relay, err := Connect(ctx, mock.URL(), WithRateLimit(float64(time.Second/interval), 2))
+	if err != nil {
+		t.Fatalf("failed to connect: %v", err)
+	}
+	defer relay.Close()
+
+	sk := GeneratePrivateKey()
+	for i := 0; i < 4; i++ {
+		evt, _ := NewEvent(KindTextNote, strconv.Itoa(i)).SignWith(sk)
+		if _, err := relay.Publish(ctx, evt); err != nil {
+			t.Fatalf("publish failed: %v", err)
+		}
+	}
+	sub, err := relay.Subscribe(ctx, Filters{{Kinds: IntList{KindTextNote}}})
+	if err != nil {
+		t.Fatalf("subscribe failed: %v", err)
+	}
+	for eose := false; !eose; {
+		select {
+		case <-sub.Events:
+		case <-sub.EndOfStoredEvents:
+			eose = true
+		case <-ctx.Done():
+			t.Fatal("didn't get EOSE")
+		}
+	}
+
+	mock.mutex.Lock()
+	arrivals := mock.arrivals
+	mock.mutex.Unlock()
+	if len(arrivals) != 5 {
+		t.Fatalf("expected 5 messages, got %d", len(arrivals))
+	}
+	// the burst of 2 can go out at once, then one message per interval
+	const slack = 10 * time.Millisecond
+	for i := 2; i < len(arrivals); i++ {
+		if gap := arrivals[i].Sub(arrivals[i-2]); gap < interval-slack {
+			t.Errorf("messages %d and %d were only %v apart", i-2, i, gap)
+		}
+	}
+	if total := arrivals[4].Sub(arrivals[0]); total < 3*interval-slack {
+		t.Errorf("5 messages were sent in %v", total)
+	}
+
+	short, cancelShort := context.WithTimeout(ctx, interval/5)
+	defer cancelShort()
+	evt, _ := NewEvent(KindTextNote, "late").SignWith(sk)
+	if _, err := relay.Publish(short, evt); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected the deadline to be exceeded, got %v", err)
+	}
+}
+
+func TestRelayMaxMessageSize(t *testing.T) {
+	for _, disconnect := range []bool{false, true} {
+		t.Run("disconnect="+strconv.FormatBool(disconnect), func(t *testing.T) {
+			mock := newMockRelay(t)
+			defer mock.Close()
+
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+
+			relay, err := Connect(ctx, mock.URL(), WithMaxMessageSize(1024, disconnect))
+			if err != nil {
+				t.Fatalf("failed to connect: %v", err)
+			}
+			defer relay.Close()
+
+			if err := relay.Connection.WriteJSON([]string{"HUGE"}); err != nil {
+				t.Fatalf("failed to ask for the huge message: %v", err)
+			}
+
+			if disconnect {
+				select {
+				case <-relay.Closed:
+					if relay.ConnectionError != ErrMessageTooLarge {
+						t.Errorf("wrong connection error: %v", relay.ConnectionError)
+					}
+				case <-ctx.Done():
+					t.Fatal("relay wasn't closed")
+				}
+				return
+			}
+
+			select {
+			case notice := <-relay.Notices:
+				if notice != "small" {
+					t.Errorf("the huge message wasn't dropped: %.20s", notice)
+				}
+			case <-ctx.Done():
+				t.Fatal("connection stopped working after the huge message")
+			}
+			if err := <-relay.DroppedMessages; !errors.Is(err, ErrMessageTooLarge) {
+				t.Errorf("the drop wasn't reported: %v", err)
+			}
+		})
+	}
+
+	// by default huge messages are dropped too
+	mock := newMockRelay(t)
+	defer mock.Close()
+	relay, err := Connect(context.Background(), mock.URL())
+	if err != nil {
+		t.Fatalf("failed to connect: %v", err)
+	}
+	defer relay.Close()
+	relay.Connection.WriteJSON([]string{"HUGE"})
+	select {
+	case err := <-relay.DroppedMessages:
+		if !errors.Is(err, ErrMessageTooLarge) {
+			t.Errorf("wrong error: %v", err)
+		}
+	case <-relay.Closed:
+		t.Fatal("relay was closed for a huge message")
+	case <-time.After(5 * time.Second):
+		t.Fatal("the huge message wasn't reported")
+	}
+	if notice := <-relay.Notices; notice != "small" {
+		t.Errorf("connection stopped working after the huge message: %.20s", notice)
+	}
+}
+
+func TestRelayPublishOK(t *testing.T) {
+	mock := newMockRelay(t)
+	defer mock.Close()
+	mock.rejectDuplicates = true
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	relay, err := Connect(ctx, mock.URL())
+	if err != nil {
+		t.Fatalf("failed to connect: %v", err)
+	}
+	defer relay.Close()
+
+	evt, _ := NewEvent(KindTextNote, "hello").SignWith(GeneratePrivateKey())
+	if err := relay.PublishOK(ctx, evt); err != nil {
+		t.Errorf("publish failed: %v", err)
+	}
+
+	res, err := relay.Publish(ctx, evt)
+	if err == nil || res.Accepted || res.Prefix() != OKReasonDuplicate {
+		t.Errorf("expected a duplicate rejection, got %v %v", res, err)
+	}
+	if err := relay.PublishOK(ctx, evt); err != nil {
+		t.Errorf("duplicate should count as accepted: %v", err)
+	}
+
+	invalid := *evt
+	invalid.Content = "tampered"
+	if err := relay.PublishOK(ctx, &invalid); err == nil {
+		t.Error("invalid event should be rejected")
+	}
+}
+
+func TestParseOKReason(t *testing.T) {
+	for reason, expected := range map[string]OKReason{
+		"rate-limited: slow down":       OKReasonRateLimited,
+		"auth-required: who are you":    OKReasonAuthRequired,
+		"pow: difficulty 25 is too low": OKReasonPoW,
+		"duplicate:":                    OKReasonDuplicate,
+		"":                              "",
+		"nope: unknown prefix":          "",
+		"blocked":                       OKReasonBlocked,
+	} {
+		if got := ParseOKReason(reason); got != expected {
+			t.Errorf("wrong prefix for '%s': %s != %s", reason, got, expected)
+		}
+	}
+}
+
+func TestRelayAuth(t *testing.T) {
+	mock := newMockRelay(t)
+	defer mock.Close()
+	mock.authRequired = true
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	sk := GeneratePrivateKey()
+	signer := KeySigner{PrivateKey: sk}
+
+	relay, err := Connect(ctx, mock.URL(), WithAuthSigner(signer))
+	if err != nil {
+		t.Fatalf("failed to connect: %v", err)
+	}
+	defer relay.Close()
+	evt, _ := NewEvent(KindTextNote, "hello").SignWith(sk)
+	if status, err := relay.Publish(ctx, evt); status.Status != PublishStatusSucceeded {
+		t.Errorf("publish with auth signer failed: %v %v", status, err)
+	}
+
+	unauthenticated, err := Connect(ctx, mock.URL())
+	if err != nil {
+		t.Fatalf("failed to connect: %v", err)
+	}
+	defer unauthenticated.Close()
+	if _, err := unauthenticated.Publish(ctx, evt); err == nil || !strings.Contains(err.Error(), "auth-required:") {
+		t.Errorf("publish without auth should be rejected, got %v", err)
+	}
+
+	// set after connecting, when the challenge was already received
+	var calls int32
+	unauthenticated.OnAuth(func(challenge string) (*Event, error) {
+		atomic.AddInt32(&calls, 1)
+		evt := MakeAuthEvent(unauthenticated.URL, challenge)
+		return evt, evt.SignBy(signer)
+	})
+	if status, err := unauthenticated.Publish(ctx, evt); status.Status != PublishStatusSucceeded {
+		t.Errorf("publish after OnAuth failed: %v %v", status, err)
+	}
+
+	wrong, err := Connect(ctx, mock.URL())
+	if err != nil {
+		t.Fatalf("failed to connect: %v", err)
+	}
+	defer wrong.Close()
+	wrong.OnAuth(func(challenge string) (*Event, error) {
+		atomic.AddInt32(&calls, 1)
+		evt := MakeAuthEvent(wrong.URL, "not the challenge")
+		return evt, evt.SignBy(signer)
+	})
+	if status, err := wrong.Publish(ctx, evt); status.Status != PublishStatusFailed || err == nil {
+		t.Errorf("publish with a wrong auth should fail: %v %v", status, err)
+	}
+	if calls := atomic.LoadInt32(&calls); calls != 2 {
+		t.Errorf("expected one auth for each relay, got %d", calls)
+	}
+
+	empty, err := Connect(ctx, mock.URL())
+	if err != nil {
+		t.Fatalf("failed to connect: %v", err)
+	}
+	defer empty.Close()
+	empty.OnAuth(func(challenge string) (*Event, error) { return nil, nil })
+	if status, err := empty.Publish(ctx, evt); status.Status != PublishStatusFailed || err == nil {
+		t.Errorf("publish with a nil auth event should fail: %v %v", status, err)
+	}
+}
+
+func TestRelayReconcile(t *testing.T) {
+	mock := newMockRelay(t)
+	defer mock.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	relay, err := Connect(ctx, mock.URL())
+	if err != nil {
+		t.Fatalf("failed to connect: %v", err)
+	}
+	defer relay.Close()
+
+	// enough events for the ranges to be split by fingerprints
+	sk := GeneratePrivateKey()
+	var local []EventID
+	var wantNeed, wantHave []string
+	for i := 0; i < 60; i++ {
+		evt, _ := NewEvent(KindTextNote, strconv.Itoa(i)).SignWith(sk)
+		switch {
+		case i%10 == 0:
+			local = append(local, EventID{ID: evt.ID, CreatedAt: evt.CreatedAt})
+			wantHave = append(wantHave, evt.ID)
+			continue
+		case i%6 == 0:
+			wantNeed = append(wantNeed, evt.ID)
+		default:
+			local = append(local, EventID{ID: evt.ID, CreatedAt: evt.CreatedAt})
+		}
+		relay.Publish(ctx, evt)
+	}
+
+	need, have, err := relay.Reconcile(ctx, Filter{}, local)
+	if err != nil {
+		t.Fatalf("reconcile failed: %v", err)
+	}
+	if !sameIDs(need, wantNeed) {
+		t.Errorf("wrong need ids: %v", need)
+	}
+	if !sameIDs(have, wantHave) {
+		t.Errorf("wrong have ids: %v", have)
+	}
+
+	mock.mutex.Lock()
+	mock.negentropyClosed = true
+	mock.mutex.Unlock()
+	if _, _, err := relay.Reconcile(ctx, Filter{}, local); !errors.Is(err, ErrReconcileUnsupported) ||
+		!strings.Contains(err.Error(), "no negentropy here") {
+		t.Errorf("expected ErrReconcileUnsupported from the CLOSED, got %v", err)
+	}
+
+	mock.mutex.Lock()
+	mock.negentropyUnsupported = true
+	mock.mutex.Unlock()
+	if _, _, err := relay.Reconcile(ctx, Filter{}, local); !errors.Is(err, ErrReconcileUnsupported) {
+		t.Errorf("expected ErrReconcileUnsupported, got %v", err)
+	}
+}
+
+func TestRelayPool(t *testing.T) {
+	mock1 := newMockRelay(t)
+	defer mock1.Close()
+	mock2 := newMockRelay(t)
+	defer mock2.Close()
+
+	pool := NewRelayPool()
+	defer pool.Close()
+
+	for _, url := range []string{mock1.URL(), mock2.URL()} {
+		if err := pool.Add(url, nil); err != nil {
+			t.Fatalf("failed to add relay: %v", err)
+		}
+	}
+	if err := pool.Add("ws://127.0.0.1:1", nil); err == nil {
+		t.Error("adding a relay that is down should fail")
+	}
+
+	sk := GeneratePrivateKey()
+	pool.SecretKey = &sk
+
+	publish := func() map[Status]int {
+		_, statuses, err := pool.PublishEvent(NewEvent(KindTextNote, "hello"))
+		if err != nil {
+			t.Fatalf("failed to publish: %v", err)
+		}
+		counts := make(map[Status]int)
+		for status := range statuses {
+			counts[status.Status]++
+		}
+		return counts
+	}
+
+	if counts := publish(); counts[PublishStatusSent] != 2 || counts[PublishStatusSucceeded] != 2 {
+		t.Errorf("wrong publish statuses: %v", counts)
+	}
+
+	pk, _ := GetPublicKey(sk)
+	sub := pool.Sub(Filters{{Authors: StringList{pk}}})
+	select {
+	case evt := <-sub.UniqueEvents:
+		if evt.PubKey != pk {
+			t.Errorf("got the wrong event: %v", evt)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("didn't get the event")
+	}
+	select {
+	case evt := <-sub.UniqueEvents:
+		t.Errorf("got a duplicate event: %v", evt)
+	case <-time.After(200 * time.Millisecond):
+	}
+
+	// the pool reconnects to relays that drop the connection
+	mock1.dropConnections()
+	time.Sleep(2 * reconnectBaseDelay)
+	if counts := publish(); counts[PublishStatusSucceeded] != 2 {
+		t.Errorf("wrong publish statuses after reconnecting: %v", counts)
+	}
+}
+
+func TestRelayPoolUniqueEventsSize(t *testing.T) {
+	pool := NewRelayPool()
+	defer pool.Close()
+	pool.UniqueEventsSize = 2
+
+	sub := pool.Sub(Filters{{Kinds: IntList{KindTextNote}}})
+	defer sub.Unsub()
+	go func() {
+		for _, id := range []string{"a", "b", "b", "c", "a"} {
+			sub.Events <- EventMessage{Event: Event{ID: id}}
+		}
+	}()
+
+	// "a" is forgotten once two other ids were seen after it
+	var got []string
+	for len(got) < 4 {
+		select {
+		case evt := <-sub.UniqueEvents:
+			got = append(got, evt.ID)
+		case <-time.After(5 * time.Second):
+			t.Fatalf("only got %v", got)
+		}
+	}
+	if strings.Join(got, "") != "abca" {
+		t.Errorf("wrong unique events: %v", got)
+	}
+}
+
+func TestRelayPoolQueryStream(t *testing.T) {
+	mock1 := newMockRelay(t)
+	defer mock1.Close()
+	mock2 := newMockRelay(t)
+	defer mock2.Close()
+	mock1.live, mock2.live = true, true
+
+	pool := NewRelayPool()
+	defer pool.Close()
+	for _, url := range []string{mock1.URL(), mock2.URL()} {
+		if err := pool.Add(url, nil); err != nil {
+			t.Fatalf("failed to add relay: %v", err)
+		}
+	}
+
+	sk := GeneratePrivateKey()
+	pk, _ := GetPublicKey(sk)
+	publish := func(createdAt Timestamp) *Event {
+		evt := NewEvent(KindTextNote, "hello")
+		evt.CreatedAt = createdAt
+		evt.SignWith(sk)
+		_, statuses, _ := pool.PublishEvent(evt)
+		for range statuses {
+		}
+		return evt
+	}
+	for _, createdAt := range []Timestamp{1000, 3000, 2000} {
+		publish(createdAt)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	initial, live, err := pool.QueryStream(ctx, Filters{{Authors: StringList{pk}}})
+	if err != nil {
+		t.Fatalf("query failed: %v", err)
+	}
+	if len(initial) != 3 || initial[0].CreatedAt != 3000 || initial[2].CreatedAt != 1000 {
+		t.Errorf("wrong initial events: %v", initial)
+	}
+
+	newer := publish(4000)
+	select {
+	case evt := <-live:
+		if evt.ID != newer.ID {
+			t.Errorf("got the wrong live event: %v", evt)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("didn't get the live event")
+	}
+	select {
+	case evt := <-live:
+		t.Errorf("got a duplicate live event: %v", evt)
+	case <-time.After(200 * time.Millisecond):
+	}
+
+	cancel()
+	select {
+	case _, ok := <-live:
+		if ok {
+			t.Error("got an event after cancelling")
+		}
+	case <-time.After(5 * time.Second):
+		t.Error("live wasn't closed after cancelling")
+	}
+}
+
+func TestRelayPoolQueryStreamStored(t *testing.T) {
+	mock := newMockRelay(t)
+	defer mock.Close()
+
+	// a literal pool, without EOSETimeout
+	pool := &RelayPool{
+		PublishTimeout: 5 * time.Second,
+		Relays:         make(map[string]RelayPoolPolicy),
+		relays:         make(map[string]*Relay),
+		subscriptions:  make(map[string]*Subscription),
+		seen:           NewSeenCache(defaultSeenCacheSize),
+		relayLists:     make(map[string][]RelayEntry),
+		outbox:         make(map[string]*Relay),
+		Notices:        make(chan *NoticeMessage),
+		closed:         make(chan struct{}),
+	}
+	defer pool.Close()
+	if err := pool.Add(mock.URL(), nil); err != nil {
+		t.Fatalf("failed to add relay: %v", err)
+	}
+
+	sk := GeneratePrivateKey()
+	pk, _ := GetPublicKey(sk)
+	for i := 0; i < 50; i++ {
+		evt, _ := NewEvent(KindTextNote, strconv.Itoa(i)).SignWith(sk)
+		mock.mutex.Lock()
+		mock.events = append(mock.events, *evt)
+		mock.mutex.Unlock()
+	}
+
+	// stored events must never be left on their way to live
+	for i := 0; i < 20; i++ {
+		ctx, cancel := context.WithCancel(context.Background())
+		initial, live, err := pool.QueryStream(ctx, Filters{{Authors: StringList{pk}}})
+		if err != nil || len(initial) != 50 {
+			t.Fatalf("got %d initial events, %v", len(initial), err)
+		}
+		select {
+		case evt := <-live:
+			t.Fatalf("stored event delivered as live: %v", evt)
+		case <-time.After(10 * time.Millisecond):
+		}
+		cancel()
+	}
+}
+
+func TestRelayPoolOutbox(t *testing.T) {
+	writeRelay := newMockRelay(t)
+	defer writeRelay.Close()
+	readRelay := newMockRelay(t)
+	defer readRelay.Close()
+	defaultRelay := newMockRelay(t)
+	defer defaultRelay.Close()
+
+	pool := NewRelayPool()
+	defer pool.Close()
+	pool.DefaultRelays = []string{defaultRelay.URL()}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	stored := func(m *mockRelay) int {
+		m.mutex.Lock()
+		defer m.mutex.Unlock()
+		return len(m.events)
+	}
+	publish := func(evt *Event, relayList []RelayEntry) {
+		statuses, err := pool.PublishToWriteRelays(ctx, evt, relayList)
+		if err != nil {
+			t.Fatalf("publish failed: %v", err)
+		}
+		for status := range statuses {
+			if status.Status == PublishStatusFailed {
+				t.Errorf("publish to '%s' failed: %s", status.Relay, status.Reason)
+			}
+		}
+	}
+
+	alice := GeneratePrivateKey()
+	alicePK, _ := GetPublicKey(alice)
+	pool.SetRelayList(alicePK, []RelayEntry{
+		{URL: writeRelay.URL(), Write: true},
+		{URL: readRelay.URL(), Read: true},
+	})
+	fromAlice, _ := NewEvent(KindTextNote, "from alice").SignWith(alice)
+	publish(fromAlice, nil)
+	if stored(writeRelay) != 1 || stored(readRelay) != 0 || stored(defaultRelay) != 0 {
+		t.Error("event wasn't routed to the write relay only")
+	}
+
+	bob := GeneratePrivateKey()
+	bobPK, _ := GetPublicKey(bob)
+	fromBob, _ := NewEvent(KindTextNote, "from bob").SignWith(bob)
+	publish(fromBob, nil)
+	if stored(defaultRelay) != 1 {
+		t.Error("event of an unknown pubkey wasn't sent to the default relays")
+	}
+	publish(fromBob, []RelayEntry{{URL: readRelay.URL(), Read: true, Write: true}})
+	if stored(readRelay) != 1 {
+		t.Error("event wasn't sent to the given relay list")
+	}
+
+	// a zero EOSETimeout is the default one, not an immediate timeout
+	pool.EOSETimeout = 0
+	events, err := pool.QueryWriteRelays(ctx, Filter{Authors: StringList{alicePK, bobPK}})
+	if err != nil {
+		t.Fatalf("query failed: %v", err)
+	}
+	if len(events) != 2 {
+		t.Errorf("expected the events of alice and bob, got %v", events)
+	}
+
+	// without an EOSE the query waits for EOSETimeout, and the subscription
+	// closing then doesn't add empty events
+	writeRelay.mutex.Lock()
+	writeRelay.noEOSE = true
+	writeRelay.mutex.Unlock()
+	pool.EOSETimeout = 200 * time.Millisecond
+	events, err = pool.QueryWriteRelays(ctx, Filter{Authors: StringList{alicePK, bobPK}})
+	if err != nil {
+		t.Fatalf("query failed: %v", err)
+	}
+	if len(events) != 2 || events[0].ID == "" || events[1].ID == "" {
+		t.Errorf("expected the events of alice and bob, got %v", events)
+	}
+}
+
+// countingConn counts the bytes read from the connection.
+type countingConn struct {
+	net.Conn
+	read *int64
+}
+
+func (c countingConn) Read(p []byte) (int, error) {
+	n, err := c.Conn.Read(p)
+	atomic.AddInt64(c.read, int64(n))
+	return n, err
+}
+
+// BenchmarkRelaySyncCompression reports the bytes read from the connection to
+// fetch 3000 short notes, with and without permessage-deflate, from a relay
+// that compresses at zlib's default level.
+func BenchmarkRelaySyncCompression(b *testing.B) {
+	mock := newMockRelay(b)
+	defer mock.Close()
+	mock.compressionLevel = flate.DefaultCompression
+	sk := GeneratePrivateKey()
+	for i := 0; i < 3000; i++ {
+		evt, _ := NewEvent(KindTextNote, fmt.Sprintf("gm, this is short note number %d", i)).SignWith(sk)
+		mock.events = append(mock.events, *evt)
+	}
+
+	for _, compression := range []bool{false, true} {
+		b.Run("compression="+strconv.FormatBool(compression), func(b *testing.B) {
+			var read int64
+			dial := dialerFunc(func(network, address string) (net.Conn, error) {
+				conn, err := net.Dial(network, address)
+				if err != nil {
+					return nil, err
+				}
+				return countingConn{conn, &read}, nil
+			})
+
+			for i := 0; i < b.N; i++ {
+				relay, err := Connect(context.Background(), mock.URL(), WithCompression(compression), WithDialer(dial))
+				if err != nil {
+					b.Fatalf("failed to connect: %v", err)
+				}
+				sub, err := relay.Subscribe(context.Background(), Filters{{Kinds: IntList{KindTextNote}}})
+				if err != nil {
+					b.Fatalf("failed to subscribe: %v", err)
+				}
+				for n := 0; n < 3000; n++ {
+					<-sub.Events
+				}
+				<-sub.EndOfStoredEvents
+				relay.Close()
+			}
+			b.ReportMetric(float64(atomic.LoadInt64(&read))/float64(b.N), "bytes/sync")
+		})
+	}
+}
@@ -1,37 +1,65 @@
 package nostr
 
 import (
-	"crypto/rand"
-	"encoding/hex"
-	"encoding/json"
+	"context"
 	"errors"
 	"fmt"
 	"log"
+	"sort"
+	"sync"
 	"time"
-
-	"github.com/fiatjaf/bip340"
-	"github.com/gorilla/websocket"
 )
 
-const (
-	PublishStatusSent      = 0
-	PublishStatusFailed    = -1
-	PublishStatusSucceeded = 1
-)
+const defaultEOSETimeout = 10 * time.Second
 
 type PublishStatus struct {
 	Relay  string
-	Status int
+	Status Status
+
+	// Reason is what the relay answered along with PublishStatusSucceeded or
+	// PublishStatusFailed, if anything.
+	Reason string
 }
 
 type RelayPool struct {
 	SecretKey *string
 
+	// PublishTimeout is how long PublishEvent waits for each relay to confirm
+	// an event.
+	PublishTimeout time.Duration
+
+	// EOSETimeout is how long QueryStream waits for all relays to send their
+	// stored events, 10 seconds if zero.
+	EOSETimeout time.Duration
+
+	// UniqueEventsSize is how many event ids the UniqueEvents of the
+	// subscriptions created by Sub, and QueryStream, remember to drop
+	// duplicates, 10000 if zero. A duplicate of an event that was forgotten
+	// is delivered again; all ids used to be kept, which made long-lived
+	// subscriptions grow without bound.
+	UniqueEventsSize int
+
+	// SkipVerification makes the subscriptions created by Sub deliver events
+	// without checking their signatures, see WithSkipVerification for the
+	// risks. It is false by default.
+	SkipVerification bool
+
+	mutex         sync.Mutex
 	Relays        map[string]RelayPoolPolicy
-	websockets    map[string]*Connection
+	relays        map[string]*Relay
 	subscriptions map[string]*Subscription
+	seen          *SeenCache
+
+	// DefaultRelays are used by the outbox methods, like PublishToWriteRelays,
+	// for the pubkeys whose relay list isn't known.
+	DefaultRelays []string
+	relayLists    map[string][]RelayEntry
+	outbox        map[string]*Relay
 
 	Notices chan *NoticeMessage
+
+	closed chan struct{}
+	once   sync.Once
 }
 
 type RelayPoolPolicy interface {
@@ -60,16 +88,26 @@ type NoticeMessage struct {
 // New creates a new RelayPool with no relays in it
 func NewRelayPool() *RelayPool {
 	return &RelayPool{
+		PublishTimeout:   5 * time.Second,
+		EOSETimeout:      defaultEOSETimeout,
+		UniqueEventsSize: defaultSeenCacheSize,
+
 		Relays:        make(map[string]RelayPoolPolicy),
-		websockets:    make(map[string]*Connection),
+		relays:        make(map[string]*Relay),
 		subscriptions: make(map[string]*Subscription),
+		seen:          NewSeenCache(defaultSeenCacheSize),
+		relayLists:    make(map[string][]RelayEntry),
+		outbox:        make(map[string]*Relay),
 
 		Notices: make(chan *NoticeMessage),
+
+		closed: make(chan struct{}),
 	}
 }
 
 // Add adds a new relay to the pool, if policy is nil, it will be a simple
-// read+write policy.
+// read+write policy. If the connection later drops the relay reconnects with
+// exponential backoff, until it is removed.
 func (r *RelayPool) Add(url string, policy RelayPoolPolicy) error {
 	if policy == nil {
 		policy = SimplePolicy{Read: true, Write: true}
@@ -80,182 +118,272 @@ func (r *RelayPool) Add(url string, policy RelayPoolPolicy) error {
 		return fmt.Errorf("invalid relay URL '%s'", url)
 	}
 
-	socket, _, err := websocket.DefaultDialer.Dial(NormalizeURL(url), nil)
+	relay, err := Connect(context.Background(), nm, WithSeenCache(r.seen),
+		WithReconnect(reconnectBaseDelay, reconnectMaxDelay))
 	if err != nil {
-		return fmt.Errorf("error opening websocket to '%s': %w", nm, err)
+		return err
 	}
 
-	conn := NewConnection(socket)
-
+	r.mutex.Lock()
 	r.Relays[nm] = policy
-	r.websockets[nm] = conn
+	r.relays[nm] = relay
+	r.mutex.Unlock()
 
-	for _, sub := range r.subscriptions {
-		sub.addRelay(nm, conn)
-	}
-
-	go func() {
-		for {
-			typ, message, err := conn.socket.ReadMessage()
-			if err != nil {
-				log.Println("read error: ", err)
-				return
-			}
-			if typ == websocket.PingMessage {
-				conn.WriteMessage(websocket.PongMessage, nil)
-			}
-
-			if typ != websocket.TextMessage || len(message) == 0 || message[0] != '[' {
-				continue
-			}
-
-			var jsonMessage []json.RawMessage
-			err = json.Unmarshal(message, &jsonMessage)
-			if err != nil {
-				continue
-			}
+	r.attach(relay)
 
-			if len(jsonMessage) < 2 {
-				continue
-			}
-
-			var label string
-			json.Unmarshal(jsonMessage[0], &label)
+	return nil
+}
 
-			switch label {
-			case "NOTICE":
-				var content string
-				json.Unmarshal(jsonMessage[1], &content)
-				r.Notices <- &NoticeMessage{
-					Relay:   nm,
-					Message: content,
-				}
-			case "EVENT":
-				if len(jsonMessage) < 3 {
-					continue
-				}
+// attach adds a connected relay to all subscriptions and forwards its notices.
+func (r *RelayPool) attach(relay *Relay) {
+	r.mutex.Lock()
+	for id, sub := range r.subscriptions {
+		if sub.isStopped() {
+			delete(r.subscriptions, id)
+			continue
+		}
+		if r.Relays[relay.URL].ShouldRead(sub.filters) {
+			sub.addRelay(relay)
+		}
+	}
+	r.mutex.Unlock()
 
-				var channel string
-				json.Unmarshal(jsonMessage[1], &channel)
-				if subscription, ok := r.subscriptions[channel]; ok {
-					var event Event
-					json.Unmarshal(jsonMessage[2], &event)
-
-					// check signature of all received events, ignore invalid
-					ok, _ := event.CheckSignature()
-					if !ok {
-						continue
-					}
-
-					// check if the event matches the desired filter, ignore otherwise
-					if !subscription.filters.Match(&event) {
-						continue
-					}
-
-					if !subscription.stopped {
-						subscription.Events <- EventMessage{
-							Relay: nm,
-							Event: event,
-						}
-					}
-				}
+	go func() {
+		for notice := range relay.Notices {
+			select {
+			case r.Notices <- &NoticeMessage{
+				Relay:   relay.URL,
+				Message: notice,
+			}:
+			case <-r.closed:
 			}
 		}
 	}()
-
-	return nil
 }
 
 // Remove removes a relay from the pool.
 func (r *RelayPool) Remove(url string) {
 	nm := NormalizeURL(url)
 
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
 	for _, sub := range r.subscriptions {
 		sub.removeRelay(nm)
 	}
-	if conn, ok := r.websockets[nm]; ok {
-		conn.Close()
+	if relay, ok := r.relays[nm]; ok {
+		relay.Close()
 	}
 
 	delete(r.Relays, nm)
-	delete(r.websockets, nm)
+	delete(r.relays, nm)
+}
+
+// Close closes all subscriptions and connections, the pool can't be used
+// afterwards.
+func (r *RelayPool) Close() {
+	r.once.Do(func() {
+		close(r.closed)
+
+		r.mutex.Lock()
+		subscriptions := r.subscriptions
+		relays := r.relays
+		outbox := r.outbox
+		r.subscriptions = make(map[string]*Subscription)
+		r.relays = make(map[string]*Relay)
+		r.outbox = make(map[string]*Relay)
+		r.mutex.Unlock()
+
+		for _, sub := range subscriptions {
+			sub.Unsub()
+		}
+		for _, relay := range relays {
+			relay.Close()
+		}
+		for _, relay := range outbox {
+			relay.Close()
+		}
+	})
 }
 
 func (r *RelayPool) Sub(filters Filters) *Subscription {
-	random := make([]byte, 7)
-	rand.Read(random)
+	return r.subscribe(filters, true)
+}
+
+// subscribe is Sub, without UniqueEvents unless unique is true.
+func (r *RelayPool) subscribe(filters Filters, unique bool) *Subscription {
+	subscription := newSubscription(filters, WithSkipVerification(r.SkipVerification))
 
-	subscription := Subscription{filters: filters}
-	subscription.channel = hex.EncodeToString(random)
-	subscription.relays = make(map[string]*Connection)
-	for relay, policy := range r.Relays {
+	r.mutex.Lock()
+	for url, policy := range r.Relays {
 		if policy.ShouldRead(filters) {
-			ws := r.websockets[relay]
-			subscription.relays[relay] = ws
+			subscription.relays[url] = r.relays[url]
 		}
 	}
-	subscription.Events = make(chan EventMessage)
-	subscription.UniqueEvents = make(chan Event)
-	r.subscriptions[subscription.channel] = &subscription
+	r.subscriptions[subscription.channel] = subscription
+	r.mutex.Unlock()
 
-	subscription.Sub()
-	return &subscription
+	if unique {
+		subscription.UniqueEvents = make(chan Event)
+		subscription.uniqueSize = r.uniqueEventsSize()
+	}
+
+	if err := subscription.Sub(); err != nil {
+		log.Printf("error opening subscription: %s", err.Error())
+	}
+	return subscription
 }
 
-func (r *RelayPool) PublishEvent(evt *Event) (*Event, chan PublishStatus, error) {
-	status := make(chan PublishStatus, 1)
+func (r *RelayPool) uniqueEventsSize() int {
+	if r.UniqueEventsSize <= 0 {
+		return defaultSeenCacheSize
+	}
+	return r.UniqueEventsSize
+}
+
+// QueryStream subscribes to the filters on all relays with a read policy and
+// returns the stored events they send until all of them have sent an EOSE, or
+// until EOSETimeout, newest first and without duplicates. The events that
+// arrive afterwards are delivered on live, which is closed when ctx is done.
+func (r *RelayPool) QueryStream(ctx context.Context, filters Filters) (initial []*Event, live <-chan *Event, err error) {
+	if err := ctx.Err(); err != nil {
+		return nil, nil, err
+	}
+
+	// Events is read directly, instead of UniqueEvents, so nothing is in
+	// flight between the relays and here: each relay hands over its stored
+	// events before its EOSE is recorded, so once EndOfStoredEvents is closed
+	// all of them were received and anything else is live
+	sub := r.subscribe(filters, false)
+	seen := NewSeenCache(r.uniqueEventsSize())
+
+	eoseTimeout := r.EOSETimeout
+	if eoseTimeout <= 0 {
+		eoseTimeout = defaultEOSETimeout
+	}
+	timeout := time.NewTimer(eoseTimeout)
+	defer timeout.Stop()
+
+stored:
+	for {
+		select {
+		case em, ok := <-sub.Events:
+			if !ok {
+				break stored
+			}
+			if !seen.SeenOrAdd(em.Event.ID) {
+				evt := em.Event
+				initial = append(initial, &evt)
+			}
+		case <-sub.EndOfStoredEvents:
+			break stored
+		case <-timeout.C:
+			break stored
+		case <-ctx.Done():
+			sub.Unsub()
+			return nil, nil, ctx.Err()
+		}
+	}
+
+	sort.SliceStable(initial, func(i, j int) bool {
+		return initial[i].CreatedAt > initial[j].CreatedAt
+	})
+
+	events := make(chan *Event)
+	go func() {
+		defer close(events)
+		defer sub.Unsub()
+		for {
+			select {
+			case em, ok := <-sub.Events:
+				if !ok {
+					return
+				}
+				if seen.SeenOrAdd(em.Event.ID) {
+					continue
+				}
+				evt := em.Event
+				select {
+				case events <- &evt:
+				case <-ctx.Done():
+					return
+				}
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	return initial, events, nil
+}
 
+// PublishEvent signs the event with the pool's SecretKey if needed and sends
+// it to all relays with a write policy. The returned channel gets a
+// PublishStatusSent status for each relay the event was sent to, followed by
+// PublishStatusSucceeded or PublishStatusFailed as they answer, and is closed
+// when all of them have answered or timed out.
+func (r *RelayPool) PublishEvent(evt *Event) (*Event, chan PublishStatus, error) {
 	if r.SecretKey == nil && (evt.PubKey == "" || evt.Sig == "") {
-		return nil, status, errors.New("PublishEvent needs either a signed event to publish or to have been configured with a .SecretKey.")
+		return nil, nil, errors.New("PublishEvent needs either a signed event to publish or to have been configured with a .SecretKey.")
 	}
 
 	if evt.PubKey == "" {
-		secretKeyN, err := bip340.ParsePrivateKey(*r.SecretKey)
+		pubkey, err := GetPublicKey(*r.SecretKey)
 		if err != nil {
-			return nil, status, fmt.Errorf("The pool's global SecretKey is invalid: %w", err)
+			return nil, nil, fmt.Errorf("The pool's global SecretKey is invalid: %w", err)
 		}
-		evt.PubKey = fmt.Sprintf("%x", bip340.GetPublicKey(secretKeyN))
+		evt.PubKey = pubkey
 	}
 
 	if evt.Sig == "" {
 		err := evt.Sign(*r.SecretKey)
 		if err != nil {
-			return nil, status, fmt.Errorf("Error signing event: %w", err)
+			return nil, nil, fmt.Errorf("Error signing event: %w", err)
 		}
 	}
 
-	for relay, conn := range r.websockets {
-		if !r.Relays[relay].ShouldWrite(evt) {
-			continue
+	r.mutex.Lock()
+	relays := make([]*Relay, 0, len(r.relays))
+	for url, relay := range r.relays {
+		if r.Relays[url].ShouldWrite(evt) {
+			relays = append(relays, relay)
 		}
+	}
+	r.mutex.Unlock()
 
-		go func(relay string, conn *Connection) {
-			err := conn.WriteJSON([]interface{}{"EVENT", evt})
-			if err != nil {
-				log.Printf("error sending event to '%s': %s", relay, err.Error())
-				status <- PublishStatus{relay, PublishStatusFailed}
-			}
-			status <- PublishStatus{relay, PublishStatusSent}
+	status := make(chan PublishStatus, len(relays)*2)
 
-			subscription := r.Sub(Filters{Filter{IDs: []string{evt.ID}}})
-			for {
-				select {
-				case event := <-subscription.UniqueEvents:
-					if event.ID == evt.ID {
-						status <- PublishStatus{relay, PublishStatusSucceeded}
-						break
-					} else {
-						continue
-					}
-				case <-time.After(5 * time.Second):
-					break
-				}
-				break
-			}
-			subscription.Unsub()
-			close(status)
-		}(relay, conn)
+	var wg sync.WaitGroup
+	wg.Add(len(relays))
+	for _, relay := range relays {
+		go func(relay *Relay) {
+			defer wg.Done()
+			r.publishTo(context.Background(), relay, evt, status)
+		}(relay)
 	}
 
+	go func() {
+		wg.Wait()
+		close(status)
+	}()
+
 	return evt, status, nil
 }
+
+// publishTo publishes the event to a relay, waiting up to PublishTimeout, and
+// sends the statuses to status, which must have room for two of them.
+func (r *RelayPool) publishTo(ctx context.Context, relay *Relay, evt *Event, status chan<- PublishStatus) {
+	ctx, cancel := context.WithTimeout(ctx, r.PublishTimeout)
+	defer cancel()
+
+	result, err := relay.Publish(ctx, evt)
+	if err != nil && result.Status != PublishStatusSent {
+		log.Printf("error sending event to '%s': %s", relay.URL, err.Error())
+	}
+	if result.Status != PublishStatusFailed {
+		status <- PublishStatus{Relay: relay.URL, Status: PublishStatusSent}
+	}
+	if result.Status != PublishStatusSent {
+		status <- PublishStatus{Relay: relay.URL, Status: result.Status, Reason: result.Reason}
+	}
+}
@@ -0,0 +1,75 @@
+package nostr
+
+import (
+	"container/list"
+	"sync"
+)
+
+// defaultSeenCacheSize is the size of the SeenCache of pools and of each
+// subscription's UniqueEvents.
+const defaultSeenCacheSize = 10000
+
+// SeenCache records the ids of recently seen events, forgetting the least
+// recently seen ones when it is full. It is safe for concurrent use.
+type SeenCache struct {
+	mutex sync.Mutex
+	size  int
+	ids   map[string]*list.Element
+	order *list.List // front is the most recently seen
+}
+
+// NewSeenCache creates a SeenCache that keeps up to size ids, at least one.
+func NewSeenCache(size int) *SeenCache {
+	if size < 1 {
+		size = 1
+	}
+	return &SeenCache{
+		size:  size,
+		ids:   make(map[string]*list.Element, size),
+		order: list.New(),
+	}
+}
+
+// Seen checks if the id is in the cache, marking it as recently seen if so.
+func (c *SeenCache) Seen(id string) bool {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if el, ok := c.ids[id]; ok {
+		c.order.MoveToFront(el)
+		return true
+	}
+	return false
+}
+
+// Add records the id, evicting the least recently seen one if needed.
+func (c *SeenCache) Add(id string) {
+	c.SeenOrAdd(id)
+}
+
+// SeenOrAdd checks if the id is in the cache and adds it if not, in a single
+// step, so only one of many concurrent callers with the same id gets false.
+func (c *SeenCache) SeenOrAdd(id string) bool {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if el, ok := c.ids[id]; ok {
+		c.order.MoveToFront(el)
+		return true
+	}
+
+	if c.order.Len() >= c.size {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.ids, oldest.Value.(string))
+	}
+	c.ids[id] = c.order.PushFront(id)
+	return false
+}
+
+// Len returns the number of ids in the cache.
+func (c *SeenCache) Len() int {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.order.Len()
+}
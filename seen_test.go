@@ -0,0 +1,50 @@
+package nostr
+
+import (
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+func TestSeenCache(t *testing.T) {
+	cache := NewSeenCache(2)
+	if cache.SeenOrAdd("a") || cache.SeenOrAdd("b") {
+		t.Error("new ids reported as seen")
+	}
+	if !cache.Seen("a") {
+		t.Error("'a' should have been seen")
+	}
+
+	// "b" is now the least recently seen
+	cache.Add("c")
+	if cache.Seen("b") {
+		t.Error("'b' should have been evicted")
+	}
+	if !cache.Seen("a") || !cache.Seen("c") || cache.Len() != 2 {
+		t.Error("'a' and 'c' should have been kept")
+	}
+}
+
+func TestSeenCacheConcurrent(t *testing.T) {
+	cache := NewSeenCache(1000)
+
+	var firsts int64
+	var wg sync.WaitGroup
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 500; i++ {
+				if !cache.SeenOrAdd(strconv.Itoa(i)) {
+					atomic.AddInt64(&firsts, 1)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	if firsts != 500 {
+		t.Errorf("expected each id to be new exactly once, got %d", firsts)
+	}
+}
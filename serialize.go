@@ -0,0 +1,81 @@
+package nostr
+
+import "strconv"
+
+// appendSerialized appends the NIP-01 serialization of the event, the
+// [0,<pubkey>,<created_at>,<kind>,<tags>,<content>] array that is hashed to
+// get its id.
+func appendSerialized(dst []byte, evt *Event) []byte {
+	dst = append(dst, "[0,"...)
+	dst = appendJSONString(dst, evt.PubKey)
+	dst = append(dst, ',')
+	dst = strconv.AppendInt(dst, int64(evt.CreatedAt), 10)
+	dst = append(dst, ',')
+	dst = strconv.AppendInt(dst, int64(evt.Kind), 10)
+	dst = append(dst, ',')
+	dst = appendTags(dst, evt.Tags)
+	dst = append(dst, ',')
+	dst = appendJSONString(dst, evt.Content)
+	dst = append(dst, ']')
+	return dst
+}
+
+func appendTags(dst []byte, tags Tags) []byte {
+	dst = append(dst, '[')
+	for i, tag := range tags {
+		if i > 0 {
+			dst = append(dst, ',')
+		}
+		dst = append(dst, '[')
+		for j, item := range tag {
+			if j > 0 {
+				dst = append(dst, ',')
+			}
+			dst = appendJSONString(dst, item)
+		}
+		dst = append(dst, ']')
+	}
+	dst = append(dst, ']')
+	return dst
+}
+
+// appendJSONString appends s as a JSON string escaped the way NIP-01 and
+// JSON.stringify do it, which is what other implementations hash: only
+// quotes, backslashes and control characters are escaped, everything else,
+// including "<", ">", "&", U+2028 and U+2029, is written verbatim.
+func appendJSONString(dst []byte, s string) []byte {
+	const hex = "0123456789abcdef"
+
+	dst = append(dst, '"')
+	start := 0
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if c >= 0x20 && c != '"' && c != '\\' {
+			continue
+		}
+
+		dst = append(dst, s[start:i]...)
+		switch c {
+		case '"':
+			dst = append(dst, '\\', '"')
+		case '\\':
+			dst = append(dst, '\\', '\\')
+		case '\n':
+			dst = append(dst, '\\', 'n')
+		case '\r':
+			dst = append(dst, '\\', 'r')
+		case '\t':
+			dst = append(dst, '\\', 't')
+		case '\b':
+			dst = append(dst, '\\', 'b')
+		case '\f':
+			dst = append(dst, '\\', 'f')
+		default:
+			dst = append(dst, '\\', 'u', '0', '0', hex[c>>4], hex[c&0xf])
+		}
+		start = i + 1
+	}
+	dst = append(dst, s[start:]...)
+	dst = append(dst, '"')
+	return dst
+}
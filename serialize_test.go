@@ -0,0 +1,52 @@
+package nostr
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+// the ids were computed with nostr-tools, which hashes
+// JSON.stringify([0, pubkey, created_at, kind, tags, content])
+func TestSerializeEscaping(t *testing.T) {
+	for _, v := range []struct {
+		name    string
+		tags    Tags
+		content string
+		id      string
+	}{
+		{"emoji", Tags{}, "gm 🌅🫡 nostr",
+			"e983d83436079c054e68fdbd3263cf5ae803574131d7d66c0454eb7fbe8eb69a"},
+		{"newlines", Tags{}, "line1\nline2\r\nline3\ttabbed",
+			"278a900421450e4e503bed1826841412ce4f4384b504ada2e2da8808fe3a1e2c"},
+		{"quotes", Tags{{"t", "\"quoted\""}}, "she said \"hi\" and left",
+			"78a5019bd922f2b9e3e459012cdb5308fbd4e8a10b0f40163c4c5de1d8c29d1a"},
+		{"backslashes", Tags{}, "C:\\path\\to\\file \\n not a newline",
+			"9780b4924aa8f5614db2e512d8b060318379c73eecbbf8bbbdf0d2683d45b82b"},
+		{"separators", Tags{}, "a\u2028b\u2029c",
+			"8981b492ec269409acc7605512a8fd07fd579dfedec36e8067b8cfe98a6ec4ed"},
+		{"html", Tags{{"r", "https://example.com/?a=1&b=<2>"}}, "<script>alert('&')</script>",
+			"276a1f1fba447323ba30d83a9d21ba86c85f947b5ef1aa9e095f993715e5060f"},
+		{"control", Tags{}, "bell\u0007 null\u0000 esc\u001b del\u007f bs\b ff\f",
+			"3357a6eaf0e81c788934ed729b6580f35c9304b38564e826efccebcfa2583e01"},
+		{"mixed", Tags{{"e", "abc", ""}, {"p", "def", "wss://relay.example.com", "\u2028"}}, "\"\\\n\u2028🍕<&>\u0001",
+			"62535c12e536d0b62188fb4243afbd1b9a7013fbea615307e6d15cba0a169302"},
+	} {
+		evt := Event{
+			PubKey:    "79be667ef9dcbbac55a06295ce870b07029bfcdb2dce28d959f2815b16f81798",
+			CreatedAt: 1700000000,
+			Kind:      KindTextNote,
+			Tags:      v.tags,
+			Content:   v.content,
+		}
+		if id := evt.GetID(); id != v.id {
+			t.Errorf("%s: id %s doesn't match %s, serialized as %s", v.name, id, v.id, evt.Serialize())
+		}
+
+		// the wire format must be valid json that parses back to the same event
+		j, _ := json.Marshal(evt)
+		var parsed Event
+		if err := json.Unmarshal(j, &parsed); err != nil || parsed.GetID() != v.id {
+			t.Errorf("%s: event didn't roundtrip through json: %s %v", v.name, j, err)
+		}
+	}
+}
@@ -0,0 +1,37 @@
+package nostr
+
+import (
+	"fmt"
+)
+
+// Signer signs events with a key that may be held elsewhere.
+type Signer interface {
+	// Sign sets the event ID and Sig, and PubKey if needed.
+	Sign(evt *Event) error
+	PublicKey() (string, error)
+}
+
+// KeySigner is a Signer for a hex private key.
+type KeySigner struct {
+	PrivateKey string
+}
+
+func (ks KeySigner) Sign(evt *Event) error {
+	_, err := evt.SignWith(ks.PrivateKey)
+	return err
+}
+
+func (ks KeySigner) PublicKey() (string, error) {
+	return GetPublicKey(ks.PrivateKey)
+}
+
+// SignBy sets PubKey to the signer public key and has it sign the event.
+func (evt *Event) SignBy(s Signer) error {
+	pubkey, err := s.PublicKey()
+	if err != nil {
+		return fmt.Errorf("failed to get signer public key: %w", err)
+	}
+
+	evt.PubKey = pubkey
+	return s.Sign(evt)
+}
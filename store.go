@@ -0,0 +1,182 @@
+package nostr
+
+import (
+	"sort"
+	"sync"
+)
+
+// Store is a place to keep events and query them back.
+type Store interface {
+	Save(*Event) error
+	Query(Filter) ([]*Event, error)
+	Delete(id string) error
+}
+
+var _ Store = (*MemStore)(nil)
+
+// MemStore is a Store that keeps events in memory, indexed by id, author and
+// kind. Only the latest version of replaceable and parameterized replaceable
+// events is kept.
+type MemStore struct {
+	mutex       sync.RWMutex
+	byID        map[string]*Event
+	byAuthor    map[string]map[string]*Event
+	byKind      map[int]map[string]*Event
+	replaceable map[string]*Event
+}
+
+func NewMemStore() *MemStore {
+	return &MemStore{
+		byID:        make(map[string]*Event),
+		byAuthor:    make(map[string]map[string]*Event),
+		byKind:      make(map[int]map[string]*Event),
+		replaceable: make(map[string]*Event),
+	}
+}
+
+// Save stores the event. Saving an event that is already stored, or one that
+// is older than the stored version of the same replaceable event, does
+// nothing.
+func (s *MemStore) Save(evt *Event) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if _, ok := s.byID[evt.ID]; ok {
+		return nil
+	}
+
+	if key := evt.ReplaceableKey(); key != "" {
+		if existing, ok := s.replaceable[key]; ok {
+			if !isNewer(evt, existing) {
+				return nil
+			}
+			s.remove(existing)
+		}
+		s.replaceable[key] = evt
+	}
+
+	s.byID[evt.ID] = evt
+	if s.byAuthor[evt.PubKey] == nil {
+		s.byAuthor[evt.PubKey] = make(map[string]*Event)
+	}
+	s.byAuthor[evt.PubKey][evt.ID] = evt
+	if s.byKind[evt.Kind] == nil {
+		s.byKind[evt.Kind] = make(map[string]*Event)
+	}
+	s.byKind[evt.Kind][evt.ID] = evt
+
+	return nil
+}
+
+// Query returns the events matching the filter, newest first, up to
+// filter.Limit events if it is set, none if LimitZero is set instead.
+func (s *MemStore) Query(filter Filter) ([]*Event, error) {
+	if filter.LimitZero && filter.Limit == 0 {
+		return nil, nil
+	}
+
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	var results []*Event
+	for _, candidates := range s.candidates(filter) {
+		for _, evt := range candidates {
+			if filter.Matches(evt) {
+				results = append(results, evt)
+			}
+		}
+	}
+
+	sort.Slice(results, func(i, j int) bool { return isNewer(results[i], results[j]) })
+	if filter.Limit > 0 && len(results) > filter.Limit {
+		results = results[:filter.Limit]
+	}
+
+	return results, nil
+}
+
+// Delete removes the event with the given id, if it is stored.
+func (s *MemStore) Delete(id string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if evt, ok := s.byID[id]; ok {
+		s.remove(evt)
+	}
+	return nil
+}
+
+// candidates picks the smallest index that can answer the filter.
+func (s *MemStore) candidates(filter Filter) []map[string]*Event {
+	if len(filter.IDs) > 0 && allFullKeys(filter.IDs) {
+		found := make(map[string]*Event, len(filter.IDs))
+		for _, id := range filter.IDs {
+			if evt, ok := s.byID[id]; ok {
+				found[id] = evt
+			}
+		}
+		return []map[string]*Event{found}
+	}
+
+	if len(filter.Authors) > 0 && allFullKeys(filter.Authors) {
+		// repeated values would return their events once for each
+		sets := make([]map[string]*Event, 0, len(filter.Authors))
+		seen := make(map[string]bool, len(filter.Authors))
+		for _, author := range filter.Authors {
+			if !seen[author] {
+				seen[author] = true
+				sets = append(sets, s.byAuthor[author])
+			}
+		}
+		return sets
+	}
+
+	if len(filter.Kinds) > 0 {
+		sets := make([]map[string]*Event, 0, len(filter.Kinds))
+		seen := make(map[int]bool, len(filter.Kinds))
+		for _, kind := range filter.Kinds {
+			if !seen[kind] {
+				seen[kind] = true
+				sets = append(sets, s.byKind[kind])
+			}
+		}
+		return sets
+	}
+
+	return []map[string]*Event{s.byID}
+}
+
+func (s *MemStore) remove(evt *Event) {
+	delete(s.byID, evt.ID)
+	delete(s.byAuthor[evt.PubKey], evt.ID)
+	if len(s.byAuthor[evt.PubKey]) == 0 {
+		delete(s.byAuthor, evt.PubKey)
+	}
+	delete(s.byKind[evt.Kind], evt.ID)
+	if len(s.byKind[evt.Kind]) == 0 {
+		delete(s.byKind, evt.Kind)
+	}
+	if key := evt.ReplaceableKey(); key != "" && s.replaceable[key] == evt {
+		delete(s.replaceable, key)
+	}
+}
+
+// isNewer checks if a comes before b in newest-first order, ties are broken
+// by the lowest id as NIP-01 does for replaceable events.
+func isNewer(a *Event, b *Event) bool {
+	if a.CreatedAt != b.CreatedAt {
+		return a.CreatedAt > b.CreatedAt
+	}
+	return a.ID < b.ID
+}
+
+// allFullKeys checks if none of the values are prefixes, so they can be
+// looked up directly.
+func allFullKeys(values StringList) bool {
+	for _, v := range values {
+		if len(v) != 64 {
+			return false
+		}
+	}
+	return true
+}
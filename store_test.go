@@ -0,0 +1,67 @@
+package nostr
+
+import "testing"
+
+func TestMemStore(t *testing.T) {
+	store := NewMemStore()
+	sk := GeneratePrivateKey()
+	pk, _ := GetPublicKey(sk)
+
+	var notes []*Event
+	for i := 0; i < 5; i++ {
+		evt := NewEvent(KindTextNote, "note")
+		evt.CreatedAt = Timestamp(1000 + i)
+		evt.SignWith(sk)
+		notes = append(notes, evt)
+		if err := store.Save(evt); err != nil {
+			t.Fatalf("failed to save: %v", err)
+		}
+	}
+	store.Save(notes[2])
+
+	results, _ := store.Query(Filter{Kinds: IntList{KindTextNote}, Limit: 3})
+	if len(results) != 3 || results[0].ID != notes[4].ID || results[2].ID != notes[2].ID {
+		t.Errorf("wrong query results: %v", results)
+	}
+
+	if results, _ := store.Query(Filter{Authors: StringList{pk}}); len(results) != 5 {
+		t.Errorf("saving the same event twice should be idempotent, got %d events", len(results))
+	}
+	if results, _ := store.Query(Filter{Authors: StringList{pk}, LimitZero: true}); len(results) != 0 {
+		t.Errorf("a zero limit should return nothing, got %d events", len(results))
+	}
+
+	if results, _ := store.Query(Filter{Authors: StringList{pk, pk}, Limit: 5}); len(results) != 5 || results[1].ID != notes[3].ID {
+		t.Errorf("repeated authors should return each event once, got %v", results)
+	}
+	if results, _ := store.Query(Filter{Kinds: IntList{KindTextNote, KindTextNote}}); len(results) != 5 {
+		t.Errorf("repeated kinds should return each event once, got %d events", len(results))
+	}
+
+	store.Delete(notes[4].ID)
+	if results, _ := store.Query(Filter{IDs: StringList{notes[4].ID}}); len(results) != 0 {
+		t.Error("deleted event was returned")
+	}
+
+	// only the newest version of replaceable events is kept
+	for i, createdAt := range []Timestamp{2000, 3000, 2500} {
+		evt := NewEvent(KindContactList, "")
+		evt.CreatedAt = createdAt
+		evt.Content = string(rune('a' + i))
+		evt.SignWith(sk)
+		store.Save(evt)
+	}
+	results, _ = store.Query(Filter{Kinds: IntList{KindContactList}})
+	if len(results) != 1 || results[0].CreatedAt != 3000 {
+		t.Errorf("wrong replaceable events: %v", results)
+	}
+
+	for _, d := range []string{"x", "y", "x"} {
+		evt := NewEvent(30023, "").WithTag("d", d)
+		evt.SignWith(sk)
+		store.Save(evt)
+	}
+	if results, _ := store.Query(Filter{Kinds: IntList{30023}}); len(results) != 2 {
+		t.Errorf("expected one event per d tag, got %d", len(results))
+	}
+}
@@ -1,16 +1,59 @@
 package nostr
 
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"sync"
+)
+
 type Subscription struct {
-	channel string
-	relays  map[string]*Connection
+	channel  string
+	label    string
+	customID bool
+
+	skipVerification bool
+
+	relaysMutex sync.Mutex
+	relays      map[string]*Relay
 
 	filters Filters
 	Events  chan EventMessage
 
-	started      bool
+	started bool
+	// UniqueEvents gets the events from Events without duplicates among the
+	// last RelayPool.UniqueEventsSize ones, it is only fed for subscriptions
+	// created with RelayPool.Sub, in which case Events shouldn't be read
+	// directly.
 	UniqueEvents chan Event
+	uniqueSize   int
 
+	// EndOfStoredEvents is closed once all relays have sent an EOSE, meaning
+	// the stored events are over and only new ones will arrive from now on.
+	EndOfStoredEvents chan struct{}
+	eose              map[string]bool
+	eoseOnce          sync.Once
+
+	mutex   sync.RWMutex
 	stopped bool
+	done    chan struct{}
+	once    sync.Once
+
+	// queue has what the relays sent and isn't delivered yet, handed over in
+	// order by deliver, so a subscription nobody reads doesn't hold up the
+	// connection it came from
+	queueMutex  sync.Mutex
+	queue       []queuedMessage
+	queued      chan struct{}
+	deliverOnce sync.Once
+}
+
+// queuedMessage is an event to deliver or, if eose isn't empty, the url of a
+// relay that sent an EOSE after the events before it.
+type queuedMessage struct {
+	event EventMessage
+	eose  string
 }
 
 type EventMessage struct {
@@ -18,74 +61,256 @@ type EventMessage struct {
 	Relay string
 }
 
-func (subscription Subscription) Unsub() {
-	for _, conn := range subscription.relays {
-		conn.WriteJSON([]interface{}{
-			"CLOSE",
-			subscription.channel,
-		})
+// SubscriptionOption configures a subscription, it is passed to
+// Relay.Subscribe.
+type SubscriptionOption func(*Subscription)
+
+// WithLabel prefixes the random subscription id with label, which makes it
+// easier to tell subscriptions apart in relay logs.
+func WithLabel(label string) SubscriptionOption {
+	return func(subscription *Subscription) {
+		subscription.label = label
 	}
+}
 
-	subscription.stopped = true
-	if subscription.Events != nil {
-		close(subscription.Events)
+// WithSubscriptionID uses id as the subscription id instead of a random one,
+// to match a REQ sent elsewhere. Subscribing fails if the id is in use.
+func WithSubscriptionID(id string) SubscriptionOption {
+	return func(subscription *Subscription) {
+		subscription.channel = id
+		subscription.customID = true
 	}
-	if subscription.UniqueEvents != nil {
-		close(subscription.UniqueEvents)
+}
+
+// WithSkipVerification delivers the events of the subscription without
+// checking their signatures when skip is true. Anyone able to write to the
+// connection can then forge events from any pubkey, so only use it for
+// relays that are trusted and reached through a secure channel, like an
+// upstream of your own.
+func WithSkipVerification(skip bool) SubscriptionOption {
+	return func(subscription *Subscription) {
+		subscription.skipVerification = skip
 	}
 }
 
-func (subscription Subscription) Sub() {
-	for _, conn := range subscription.relays {
-		message := []interface{}{
-			"REQ",
-			subscription.channel,
-		}
-		for _, filter := range subscription.filters {
-			message = append(message, filter)
+func newSubscription(filters Filters, opts ...SubscriptionOption) *Subscription {
+	subscription := &Subscription{
+		relays:  make(map[string]*Relay),
+		filters: filters,
+		Events:  make(chan EventMessage),
+		done:    make(chan struct{}),
+		queued:  make(chan struct{}, 1),
+
+		EndOfStoredEvents: make(chan struct{}),
+		eose:              make(map[string]bool),
+	}
+	for _, opt := range opts {
+		opt(subscription)
+	}
+	if !subscription.customID {
+		subscription.channel = subscription.generateID()
+	}
+	return subscription
+}
+
+// generateID returns a random id, prefixed with the label.
+func (subscription *Subscription) generateID() string {
+	random := make([]byte, 7)
+	rand.Read(random)
+	return subscription.label + hex.EncodeToString(random)
+}
+
+// ID returns the subscription id sent to relays.
+func (subscription *Subscription) ID() string {
+	return subscription.channel
+}
+
+// Unsub sends a CLOSE to all relays and closes the Events channel.
+func (subscription *Subscription) Unsub() {
+	subscription.once.Do(func() {
+		subscription.relaysMutex.Lock()
+		for _, relay := range subscription.relays {
+			relay.removeSubscription(subscription)
+			relay.Connection.WriteJSON(CloseEnvelope{subscription.channel})
 		}
+		subscription.relaysMutex.Unlock()
+
+		// unblock pending deliveries before closing the channel they send to
+		close(subscription.done)
+		subscription.mutex.Lock()
+		subscription.stopped = true
+		close(subscription.Events)
+		subscription.mutex.Unlock()
+	})
+}
 
-		conn.WriteJSON(message)
+// Sub sends the REQ to all relays.
+func (subscription *Subscription) Sub() error {
+	return subscription.sub(context.Background())
+}
+
+func (subscription *Subscription) sub(ctx context.Context) error {
+	subscription.relaysMutex.Lock()
+	defer subscription.relaysMutex.Unlock()
+
+	// a relay failing doesn't prevent the others from getting the REQ
+	var err error
+	for url, relay := range subscription.relays {
+		relay.addSubscription(subscription)
+		if werr := relay.Connection.WriteJSONContext(ctx, subscription.reqMessage()); werr != nil && err == nil {
+			err = fmt.Errorf("error sending subscription to '%s': %w", url, werr)
+		}
 	}
 
-	if !subscription.started {
+	if !subscription.started && subscription.UniqueEvents != nil {
+		subscription.started = true
 		go subscription.startHandlingUnique()
 	}
+
+	return err
 }
 
-func (subscription Subscription) startHandlingUnique() {
-	seen := make(map[string]struct{})
-	for em := range subscription.Events {
-		if _, ok := seen[em.Event.ID]; ok {
-			continue
+func (subscription *Subscription) reqMessage() ReqEnvelope {
+	return ReqEnvelope{
+		SubscriptionID: subscription.channel,
+		Filters:        subscription.filters,
+	}
+}
+
+// dispatch queues an event to be delivered to Events, unless the subscription
+// is stopped. It doesn't wait for Events to be read.
+func (subscription *Subscription) dispatch(em EventMessage) {
+	subscription.enqueue(queuedMessage{event: em})
+}
+
+// dispatchEOSE queues the EOSE of a relay, which is recorded after the events
+// it sent before are delivered.
+func (subscription *Subscription) dispatchEOSE(url string) {
+	subscription.enqueue(queuedMessage{eose: url})
+}
+
+func (subscription *Subscription) enqueue(msg queuedMessage) {
+	if subscription.isStopped() {
+		return
+	}
+
+	subscription.queueMutex.Lock()
+	subscription.queue = append(subscription.queue, msg)
+	subscription.queueMutex.Unlock()
+
+	select {
+	case subscription.queued <- struct{}{}:
+	default:
+	}
+	subscription.deliverOnce.Do(func() {
+		go subscription.deliver()
+	})
+}
+
+// deliver hands the queued messages over in order until the subscription is
+// stopped.
+func (subscription *Subscription) deliver() {
+	for {
+		subscription.queueMutex.Lock()
+		queue := subscription.queue
+		subscription.queue = nil
+		subscription.queueMutex.Unlock()
+
+		if len(queue) == 0 {
+			select {
+			case <-subscription.queued:
+				continue
+			case <-subscription.done:
+				return
+			}
 		}
-		seen[em.Event.ID] = struct{}{}
-		if !subscription.stopped {
-			subscription.UniqueEvents <- em.Event
+
+		for _, msg := range queue {
+			if msg.eose != "" {
+				subscription.markEOSE(msg.eose)
+			} else if !subscription.send(msg.event) {
+				return
+			}
 		}
 	}
 }
 
-func (subscription Subscription) removeRelay(relay string) {
-	if conn, ok := subscription.relays[relay]; ok {
-		delete(subscription.relays, relay)
-		conn.WriteJSON([]interface{}{
-			"CLOSE",
-			subscription.channel,
-		})
+// send delivers an event to Events, returning false if the subscription was
+// stopped instead.
+func (subscription *Subscription) send(em EventMessage) bool {
+	subscription.mutex.RLock()
+	defer subscription.mutex.RUnlock()
+
+	if subscription.stopped {
+		return false
+	}
+
+	select {
+	case subscription.Events <- em:
+		return true
+	case <-subscription.done:
+		return false
+	}
+}
+
+// markEOSE records an EOSE from a relay, closing EndOfStoredEvents when all
+// of them have sent it.
+func (subscription *Subscription) markEOSE(url string) {
+	subscription.relaysMutex.Lock()
+	defer subscription.relaysMutex.Unlock()
+
+	subscription.eose[url] = true
+	for url := range subscription.relays {
+		if !subscription.eose[url] {
+			return
+		}
 	}
+	subscription.eoseOnce.Do(func() {
+		close(subscription.EndOfStoredEvents)
+	})
 }
 
-func (subscription Subscription) addRelay(relay string, conn *Connection) {
-	subscription.relays[relay] = conn
+func (subscription *Subscription) isStopped() bool {
+	subscription.mutex.RLock()
+	defer subscription.mutex.RUnlock()
+	return subscription.stopped
+}
+
+func (subscription *Subscription) startHandlingUnique() {
+	defer close(subscription.UniqueEvents)
 
-	message := []interface{}{
-		"REQ",
-		subscription.channel,
+	size := subscription.uniqueSize
+	if size <= 0 {
+		size = defaultSeenCacheSize
 	}
-	for _, filter := range subscription.filters {
-		message = append(message, filter)
+	seen := NewSeenCache(size)
+	for em := range subscription.Events {
+		if seen.SeenOrAdd(em.Event.ID) {
+			continue
+		}
+		select {
+		case subscription.UniqueEvents <- em.Event:
+		case <-subscription.done:
+		}
+	}
+}
+
+func (subscription *Subscription) removeRelay(url string) {
+	subscription.relaysMutex.Lock()
+	defer subscription.relaysMutex.Unlock()
+
+	if relay, ok := subscription.relays[url]; ok {
+		delete(subscription.relays, url)
+		relay.removeSubscription(subscription)
+		relay.Connection.WriteJSON(CloseEnvelope{subscription.channel})
 	}
+}
+
+func (subscription *Subscription) addRelay(relay *Relay) {
+	subscription.relaysMutex.Lock()
+	defer subscription.relaysMutex.Unlock()
 
-	conn.WriteJSON(message)
+	subscription.relays[relay.URL] = relay
+	relay.addSubscription(subscription)
+	relay.Connection.WriteJSON(subscription.reqMessage())
 }
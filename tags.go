@@ -0,0 +1,152 @@
+package nostr
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/valyala/fastjson"
+)
+
+type Tag []string
+type Tags []Tag
+
+func (t *Tags) Scan(src interface{}) error {
+	var jtags []byte = make([]byte, 0)
+
+	switch v := src.(type) {
+	case []byte:
+		jtags = v
+	case string:
+		jtags = []byte(v)
+	default:
+		return errors.New("couldn't scan tags, it's not a json string")
+	}
+
+	json.Unmarshal(jtags, &t)
+	return nil
+}
+
+// MarshalJSON outputs the tags as an array of arrays of strings, nil tags
+// included, which are an empty array.
+func (tags Tags) MarshalJSON() ([]byte, error) {
+	return appendTags(make([]byte, 0, 64*len(tags)+2), tags), nil
+}
+
+// UnmarshalJSON parses an array of arrays of strings, failing if any of the
+// items isn't a string. Empty tags are kept.
+func (tags *Tags) UnmarshalJSON(payload []byte) error {
+	var fastjsonParser fastjson.Parser
+	parsed, err := fastjsonParser.ParseBytes(payload)
+	if err != nil {
+		return fmt.Errorf("failed to parse tags: %w", err)
+	}
+	if parsed.Type() == fastjson.TypeNull {
+		*tags = nil
+		return nil
+	}
+
+	result, err := fastjsonArrayToTags(parsed)
+	if err != nil {
+		return fmt.Errorf("invalid tags: %w", err)
+	}
+	*tags = result
+	return nil
+}
+
+// ContainsAny checks if there is a tag with the given name whose first value
+// is any of the given values.
+func (tags Tags) ContainsAny(tagName string, values ...string) bool {
+	for _, tag := range tags {
+		if len(tag) < 2 {
+			continue
+		}
+
+		if tag[0] != tagName {
+			continue
+		}
+
+		if StringList(values).Contains(tag[1]) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// GetFirst returns the first tag with the given name, or nil if there is none.
+func (tags Tags) GetFirst(tagName string) *Tag {
+	for i, tag := range tags {
+		if len(tag) >= 1 && tag[0] == tagName {
+			return &tags[i]
+		}
+	}
+	return nil
+}
+
+// GetLast returns the last tag with the given name, or nil if there is none.
+func (tags Tags) GetLast(tagName string) *Tag {
+	for i := len(tags) - 1; i >= 0; i-- {
+		if len(tags[i]) >= 1 && tags[i][0] == tagName {
+			return &tags[i]
+		}
+	}
+	return nil
+}
+
+// GetAll returns all the tags with the given name. It allocates a new slice,
+// for hot paths see Each.
+func (tags Tags) GetAll(tagName string) Tags {
+	result := make(Tags, 0, len(tags))
+	for _, tag := range tags {
+		if len(tag) >= 1 && tag[0] == tagName {
+			result = append(result, tag)
+		}
+	}
+	return result
+}
+
+// Each calls fn for each tag with the given name, in order, until it returns
+// false. Unlike GetAll it allocates nothing, which matters when scanning the
+// tags of many events, like the "p" tags of contact lists.
+func (tags Tags) Each(tagName string, fn func(Tag) bool) {
+	for _, tag := range tags {
+		if len(tag) >= 1 && tag[0] == tagName {
+			if !fn(tag) {
+				return
+			}
+		}
+	}
+}
+
+// normalizedTagOrder is the order in which Normalize puts the tags it knows,
+// the identifier of parameterized replaceable events first and then the
+// NIP-23 metadata.
+var normalizedTagOrder = map[string]int{
+	"d":            1,
+	"title":        2,
+	"summary":      3,
+	"image":        4,
+	"published_at": 5,
+}
+
+// Normalize reorders the tags in place so that the ones with these names come
+// first, in this order: "d", "title", "summary", "image", "published_at". The
+// others keep their relative order after them, as it matters for "e" and "p"
+// tags. The order is part of the signed id, so only call it while building an
+// event, see Event.NormalizeTags.
+func (tags Tags) Normalize() {
+	sort.SliceStable(tags, func(i, j int) bool {
+		return tagRank(tags[i]) < tagRank(tags[j])
+	})
+}
+
+func tagRank(tag Tag) int {
+	if len(tag) >= 1 {
+		if rank, ok := normalizedTagOrder[tag[0]]; ok {
+			return rank
+		}
+	}
+	return len(normalizedTagOrder) + 1
+}
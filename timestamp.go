@@ -0,0 +1,16 @@
+package nostr
+
+import "time"
+
+// Timestamp is a unix timestamp in seconds, as used in created_at.
+type Timestamp int64
+
+// Now returns the current time as a Timestamp.
+func Now() Timestamp {
+	return Timestamp(time.Now().Unix())
+}
+
+// Time converts the Timestamp into a time.Time.
+func (t Timestamp) Time() time.Time {
+	return time.Unix(int64(t), 0)
+}
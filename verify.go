@@ -0,0 +1,57 @@
+package nostr
+
+import (
+	"fmt"
+	"runtime"
+	"sync"
+)
+
+// VerifyBatch checks the signatures of all events using the given number of
+// goroutines (runtime.NumCPU() if workers <= 0). The returned slice has one
+// entry for each event, in the same order, which is nil when the signature
+// is valid.
+func VerifyBatch(events []*Event, workers int) []error {
+	if workers <= 0 {
+		workers = runtime.NumCPU()
+	}
+	if workers > len(events) {
+		workers = len(events)
+	}
+
+	errs := make([]error, len(events))
+	indexes := make(chan int)
+
+	var wg sync.WaitGroup
+	wg.Add(workers)
+	for w := 0; w < workers; w++ {
+		go func() {
+			defer wg.Done()
+			for i := range indexes {
+				errs[i] = verifyOne(events[i])
+			}
+		}()
+	}
+
+	for i := range events {
+		indexes <- i
+	}
+	close(indexes)
+	wg.Wait()
+
+	return errs
+}
+
+func verifyOne(evt *Event) error {
+	if evt == nil {
+		return fmt.Errorf("%w: nil event", ErrInvalidSignature)
+	}
+
+	ok, err := evt.CheckSignature()
+	if err != nil {
+		return fmt.Errorf("%w: %s", ErrInvalidSignature, err)
+	}
+	if !ok {
+		return ErrInvalidSignature
+	}
+	return nil
+}